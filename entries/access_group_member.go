@@ -89,6 +89,23 @@ func bulkInsertAccessGroupMemberEntry(entries []*lib.StateChangeEntry, db bun.ID
 		pgEntrySlice[ii] = &PGAccessGroupMemberEntry{AccessGroupMemberEntry: AccessGroupMemberEncoderToPGStruct(entry.Encoder.(*lib.AccessGroupMemberEntry), entry.KeyBytes, params)}
 	}
 
+	// Look up which members already exist, so that updating a member isn't recorded as adding them.
+	// Members can't already exist during the initial sync, so we skip the lookup there.
+	existingMemberKeys := make(map[string]bool)
+	if operationType == lib.DbOperationTypeUpsert {
+		var existingEntries []*PGAccessGroupMemberEntry
+		if err := db.NewSelect().
+			Model(&existingEntries).
+			Column("badger_key").
+			Where("badger_key IN (?)", bun.In(consumer.KeysToDelete(uniqueEntries))).
+			Scan(context.Background()); err != nil {
+			return errors.Wrapf(err, "entries.bulkInsertAccessGroupMemberEntry: Error getting existing entries")
+		}
+		for _, existingEntry := range existingEntries {
+			existingMemberKeys[string(existingEntry.BadgerKey)] = true
+		}
+	}
+
 	// Execute the insert query.
 	query := db.NewInsert().Model(&pgEntrySlice)
 
@@ -99,9 +116,36 @@ func bulkInsertAccessGroupMemberEntry(entries []*lib.StateChangeEntry, db bun.ID
 	if _, err := query.Returning("").Exec(context.Background()); err != nil {
 		return errors.Wrapf(err, "entries.bulkInsertAccessGroupMemberEntry: Error inserting entries")
 	}
+
+	pgEventSlice := make([]*PGAccessGroupMembershipEvent, len(uniqueEntries))
+	for ii, entry := range uniqueEntries {
+		eventType := AccessGroupMembershipEventTypeAdd
+		if existingMemberKeys[string(entry.KeyBytes)] {
+			eventType = AccessGroupMembershipEventTypeUpdate
+		}
+		pgEventSlice[ii] = accessGroupMemberEntryToMembershipEvent(&pgEntrySlice[ii].AccessGroupMemberEntry, entry.BlockHeight, eventType)
+	}
+	if err := bulkInsertAccessGroupMembershipEvents(pgEventSlice, db); err != nil {
+		return errors.Wrapf(err, "entries.bulkInsertAccessGroupMemberEntry: Error inserting membership events")
+	}
 	return nil
 }
 
+func accessGroupMemberEntryToMembershipEvent(
+	accessGroupMemberEntry *AccessGroupMemberEntry,
+	blockHeight uint64,
+	eventType string,
+) *PGAccessGroupMembershipEvent {
+	return &PGAccessGroupMembershipEvent{AccessGroupMembershipEvent: AccessGroupMembershipEvent{
+		AccessGroupOwnerPublicKey:  accessGroupMemberEntry.AccessGroupOwnerPublicKey,
+		AccessGroupKeyName:         accessGroupMemberEntry.AccessGroupKeyName,
+		AccessGroupMemberPublicKey: accessGroupMemberEntry.AccessGroupMemberPublicKey,
+		BlockHeight:                blockHeight,
+		AccessGroupMemberKeyName:   accessGroupMemberEntry.AccessGroupMemberKeyName,
+		EventType:                  eventType,
+	}}
+}
+
 // bulkDeletePostEntry deletes a batch of access_group_member entries from the database.
 func bulkDeleteAccessGroupMemberEntry(entries []*lib.StateChangeEntry, db bun.IDB, operationType lib.StateSyncerOperationType) error {
 	// Track the unique entries we've inserted so we don't insert the same entry twice.
@@ -110,6 +154,15 @@ func bulkDeleteAccessGroupMemberEntry(entries []*lib.StateChangeEntry, db bun.ID
 	// Transform the entries into a list of keys to delete.
 	keysToDelete := consumer.KeysToDelete(uniqueEntries)
 
+	// Look up the members being deleted, so that we can record them as removed.
+	var prevEntries []*PGAccessGroupMemberEntry
+	if err := db.NewSelect().
+		Model(&prevEntries).
+		Where("badger_key IN (?)", bun.In(keysToDelete)).
+		Scan(context.Background()); err != nil {
+		return errors.Wrapf(err, "entries.bulkDeleteAccessGroupMemberEntry: Error getting previous entries")
+	}
+
 	// Execute the delete query.
 	if _, err := db.NewDelete().
 		Model(&PGAccessGroupMemberEntry{}).
@@ -119,5 +172,18 @@ func bulkDeleteAccessGroupMemberEntry(entries []*lib.StateChangeEntry, db bun.ID
 		return errors.Wrapf(err, "entries.bulkDeleteAccessGroupMemberEntry: Error deleting entries")
 	}
 
+	blockHeightsByKey := make(map[string]uint64)
+	for _, entry := range uniqueEntries {
+		blockHeightsByKey[string(entry.KeyBytes)] = entry.BlockHeight
+	}
+	pgEventSlice := make([]*PGAccessGroupMembershipEvent, len(prevEntries))
+	for ii, prevEntry := range prevEntries {
+		pgEventSlice[ii] = accessGroupMemberEntryToMembershipEvent(
+			&prevEntry.AccessGroupMemberEntry, blockHeightsByKey[string(prevEntry.BadgerKey)], AccessGroupMembershipEventTypeRemove)
+	}
+	if err := bulkInsertAccessGroupMembershipEvents(pgEventSlice, db); err != nil {
+		return errors.Wrapf(err, "entries.bulkDeleteAccessGroupMemberEntry: Error inserting membership events")
+	}
+
 	return nil
 }
@@ -0,0 +1,202 @@
+package entries
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/deso-protocol/core/lib"
+	"github.com/deso-protocol/state-consumer/consumer"
+	"github.com/pkg/errors"
+	"github.com/uptrace/bun"
+)
+
+const (
+	AccessGroupMembershipEventTypeAdd    = "add"
+	AccessGroupMembershipEventTypeRemove = "remove"
+	AccessGroupMembershipEventTypeUpdate = "update"
+)
+
+// AccessGroupMembershipEvent records a member being added to, removed from or updated in an access group at a given
+// block height. Rows are written by the access group member batch operation when member entries are inserted or
+// deleted, and by the utxo operation parser, which attributes the event to the transaction that caused it.
+type AccessGroupMembershipEvent struct {
+	AccessGroupOwnerPublicKey  string    `pg:",pk,use_zero"`
+	AccessGroupKeyName         string    `pg:",pk,use_zero"`
+	AccessGroupMemberPublicKey string    `pg:",pk,use_zero"`
+	BlockHeight                uint64    `pg:",pk,use_zero"`
+	AccessGroupMemberKeyName   string    `bun:",nullzero"`
+	EventType                  string    `pg:",use_zero"`
+	ActorPublicKey             string    `bun:",nullzero"`
+	BlockHash                  string    `bun:",nullzero"`
+	TxnHash                    string    `bun:",nullzero"`
+	Timestamp                  time.Time `bun:",nullzero"`
+}
+
+type PGAccessGroupMembershipEvent struct {
+	bun.BaseModel `bun:"table:access_group_membership_event"`
+	AccessGroupMembershipEvent
+}
+
+// AccessGroupMemberCountDelta is the net change in the number of members of an access group.
+type AccessGroupMemberCountDelta struct {
+	AccessGroupOwnerPublicKey string
+	AccessGroupKeyName        string
+	MemberCountDelta          int64
+}
+
+// accessGroupMembershipEventKey returns the primary key of a membership event, used to dedupe events in a batch.
+func accessGroupMembershipEventKey(event *AccessGroupMembershipEvent) string {
+	return fmt.Sprintf("%v:%v:%v:%v", event.AccessGroupOwnerPublicKey, event.AccessGroupKeyName,
+		event.AccessGroupMemberPublicKey, event.BlockHeight)
+}
+
+// bulkInsertAccessGroupMembershipEvents records a batch of membership changes, and applies them to the member counts
+// of each group.
+func bulkInsertAccessGroupMembershipEvents(pgEventSlice []*PGAccessGroupMembershipEvent, db bun.IDB) error {
+	if len(pgEventSlice) == 0 {
+		return nil
+	}
+	// A single insert can't update the same row twice, so only keep the latest event for each row.
+	pgEventIndex := make(map[string]int)
+	var uniquePgEventSlice []*PGAccessGroupMembershipEvent
+	deltaIndex := make(map[string]int)
+	var deltas []*AccessGroupMemberCountDelta
+	for _, pgEvent := range pgEventSlice {
+		eventKey := accessGroupMembershipEventKey(&pgEvent.AccessGroupMembershipEvent)
+		if ii, ok := pgEventIndex[eventKey]; ok {
+			uniquePgEventSlice[ii] = pgEvent
+		} else {
+			pgEventIndex[eventKey] = len(uniquePgEventSlice)
+			uniquePgEventSlice = append(uniquePgEventSlice, pgEvent)
+		}
+
+		if pgEvent.EventType == AccessGroupMembershipEventTypeUpdate {
+			continue
+		}
+		groupKey := fmt.Sprintf("%v:%v", pgEvent.AccessGroupOwnerPublicKey, pgEvent.AccessGroupKeyName)
+		if _, ok := deltaIndex[groupKey]; !ok {
+			deltaIndex[groupKey] = len(deltas)
+			deltas = append(deltas, &AccessGroupMemberCountDelta{
+				AccessGroupOwnerPublicKey: pgEvent.AccessGroupOwnerPublicKey,
+				AccessGroupKeyName:        pgEvent.AccessGroupKeyName,
+			})
+		}
+		if pgEvent.EventType == AccessGroupMembershipEventTypeAdd {
+			deltas[deltaIndex[groupKey]].MemberCountDelta++
+		} else {
+			deltas[deltaIndex[groupKey]].MemberCountDelta--
+		}
+	}
+
+	// A member that's added and then updated within the same block height is still recorded as added.
+	if _, err := db.NewInsert().
+		Model(&uniquePgEventSlice).
+		On("CONFLICT (access_group_owner_public_key, access_group_key_name, access_group_member_public_key, block_height) DO UPDATE").
+		Set("event_type = CASE WHEN ?TableAlias.event_type = ? AND EXCLUDED.event_type = ? THEN ?TableAlias.event_type ELSE EXCLUDED.event_type END",
+			AccessGroupMembershipEventTypeAdd, AccessGroupMembershipEventTypeUpdate).
+		Set("access_group_member_key_name = EXCLUDED.access_group_member_key_name").
+		Returning("").
+		Exec(context.Background()); err != nil {
+		return errors.Wrapf(err, "entries.bulkInsertAccessGroupMembershipEvents: Error inserting entries")
+	}
+
+	if err := applyAccessGroupMemberCountDeltas(deltas, db); err != nil {
+		return errors.Wrapf(err, "entries.bulkInsertAccessGroupMembershipEvents: Error updating member counts")
+	}
+	return nil
+}
+
+// applyAccessGroupMemberCountDeltas adds member count changes to the member counts of each group.
+func applyAccessGroupMemberCountDeltas(deltas []*AccessGroupMemberCountDelta, db bun.IDB) error {
+	if len(deltas) == 0 {
+		return nil
+	}
+	if _, err := db.NewRaw(`
+		WITH _data (access_group_owner_public_key, access_group_key_name, member_count_delta) AS (?)
+		INSERT INTO access_group_member_count (access_group_owner_public_key, access_group_key_name, member_count)
+		SELECT access_group_owner_public_key, access_group_key_name, GREATEST(member_count_delta, 0)
+		FROM _data
+		ON CONFLICT (access_group_owner_public_key, access_group_key_name) DO UPDATE SET
+			member_count = GREATEST(access_group_member_count.member_count + (
+				SELECT _data.member_count_delta FROM _data
+				WHERE _data.access_group_owner_public_key = EXCLUDED.access_group_owner_public_key
+				AND _data.access_group_key_name = EXCLUDED.access_group_key_name
+			), 0)
+	`, db.NewValues(&deltas)).Exec(context.Background()); err != nil {
+		return errors.Wrapf(err, "entries.applyAccessGroupMemberCountDeltas: Error updating member counts")
+	}
+	return nil
+}
+
+// AccessGroupMembershipAttributionsFromUtxoOps returns the membership events caused by an access group members
+// transaction, one for each member in the transaction.
+func AccessGroupMembershipAttributionsFromUtxoOps(
+	txn *lib.MsgDeSoTxn,
+	utxoOps []*lib.UtxoOperation,
+	transaction *PGTransactionEntry,
+	params *lib.DeSoParams,
+) []*PGAccessGroupMembershipEvent {
+	accessGroupMembersMetadata, ok := txn.TxnMeta.(*lib.AccessGroupMembersMetadata)
+	if !ok || consumer.GetUtxoOpByOperationType(utxoOps, lib.OperationTypeAccessGroupMembers) == nil {
+		return nil
+	}
+	var eventType string
+	switch accessGroupMembersMetadata.AccessGroupMemberOperationType {
+	case lib.AccessGroupMemberOperationTypeAdd:
+		eventType = AccessGroupMembershipEventTypeAdd
+	case lib.AccessGroupMemberOperationTypeRemove:
+		eventType = AccessGroupMembershipEventTypeRemove
+	case lib.AccessGroupMemberOperationTypeUpdate:
+		eventType = AccessGroupMembershipEventTypeUpdate
+	default:
+		return nil
+	}
+
+	pgEventSlice := make([]*PGAccessGroupMembershipEvent, 0, len(accessGroupMembersMetadata.AccessGroupMembersList))
+	for _, accessGroupMember := range accessGroupMembersMetadata.AccessGroupMembersList {
+		pgEventSlice = append(pgEventSlice, &PGAccessGroupMembershipEvent{AccessGroupMembershipEvent: AccessGroupMembershipEvent{
+			AccessGroupOwnerPublicKey:  consumer.PublicKeyBytesToBase58Check(accessGroupMembersMetadata.AccessGroupOwnerPublicKey, params),
+			AccessGroupKeyName:         string(accessGroupMembersMetadata.AccessGroupKeyName),
+			AccessGroupMemberPublicKey: consumer.PublicKeyBytesToBase58Check(accessGroupMember.AccessGroupMemberPublicKey, params),
+			BlockHeight:                transaction.BlockHeight,
+			AccessGroupMemberKeyName:   string(accessGroupMember.AccessGroupMemberKeyName),
+			EventType:                  eventType,
+			ActorPublicKey:             transaction.PublicKey,
+			BlockHash:                  transaction.BlockHash,
+			TxnHash:                    transaction.TransactionHash,
+			Timestamp:                  transaction.Timestamp,
+		}})
+	}
+	return pgEventSlice
+}
+
+// bulkInsertAccessGroupMembershipAttributions links membership events to the block and transaction that caused them.
+// Only events already recorded by the access group member batch operation are attributed, as a members transaction
+// doesn't always change the member entries, e.g. when it adds a member who is already in the group.
+func bulkInsertAccessGroupMembershipAttributions(attributions []*PGAccessGroupMembershipEvent, db bun.IDB) error {
+	if len(attributions) == 0 {
+		return nil
+	}
+	pgEventSlice := latestAttributions(attributions, func(attribution *PGAccessGroupMembershipEvent) string {
+		return accessGroupMembershipEventKey(&attribution.AccessGroupMembershipEvent)
+	}, nil)
+
+	// The event type is left to the access group member batch operation, which sees the net result of the block.
+	if _, err := db.NewUpdate().
+		With("_data", db.NewValues(&pgEventSlice)).
+		Model((*PGAccessGroupMembershipEvent)(nil)).
+		TableExpr("_data").
+		Set("actor_public_key = _data.actor_public_key").
+		Set("block_hash = _data.block_hash").
+		Set("txn_hash = _data.txn_hash").
+		Set("timestamp = _data.timestamp").
+		Where("pg_access_group_membership_event.access_group_owner_public_key = _data.access_group_owner_public_key").
+		Where("pg_access_group_membership_event.access_group_key_name = _data.access_group_key_name").
+		Where("pg_access_group_membership_event.access_group_member_public_key = _data.access_group_member_public_key").
+		Where("pg_access_group_membership_event.block_height = _data.block_height").
+		Exec(context.Background()); err != nil {
+		return errors.Wrapf(err, "entries.bulkInsertAccessGroupMembershipAttributions: Error updating entries")
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package entries
+
+import (
+	"testing"
+	"time"
+
+	"github.com/deso-protocol/core/lib"
+	"github.com/deso-protocol/state-consumer/consumer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccessGroupMembershipAttributionsFromUtxoOps(t *testing.T) {
+	params := &lib.DeSoMainnetParams
+	ownerPublicKey := testPublicKeyBytes(1)
+	memberPublicKeys := [][]byte{testPublicKeyBytes(2), testPublicKeyBytes(3)}
+	transaction := &PGTransactionEntry{TransactionEntry: TransactionEntry{
+		TransactionHash: "txn",
+		PublicKey:       consumer.PublicKeyBytesToBase58Check(ownerPublicKey, params),
+		BlockHash:       "block",
+		BlockHeight:     100,
+		Timestamp:       time.Unix(1700000000, 0),
+	}}
+	utxoOps := []*lib.UtxoOperation{{Type: lib.OperationTypeAccessGroupMembers}}
+	accessGroupMembersTxn := func(operationType lib.AccessGroupMemberOperationType) *lib.MsgDeSoTxn {
+		return &lib.MsgDeSoTxn{TxnMeta: &lib.AccessGroupMembersMetadata{
+			AccessGroupOwnerPublicKey: ownerPublicKey,
+			AccessGroupKeyName:        []byte("group"),
+			AccessGroupMembersList: []*lib.AccessGroupMember{
+				{AccessGroupMemberPublicKey: memberPublicKeys[0], AccessGroupMemberKeyName: []byte("default-key")},
+				{AccessGroupMemberPublicKey: memberPublicKeys[1], AccessGroupMemberKeyName: []byte("default-key")},
+			},
+			AccessGroupMemberOperationType: operationType,
+		}}
+	}
+
+	for _, testCase := range []struct {
+		operationType     lib.AccessGroupMemberOperationType
+		expectedEventType string
+	}{
+		{lib.AccessGroupMemberOperationTypeAdd, AccessGroupMembershipEventTypeAdd},
+		{lib.AccessGroupMemberOperationTypeRemove, AccessGroupMembershipEventTypeRemove},
+		{lib.AccessGroupMemberOperationTypeUpdate, AccessGroupMembershipEventTypeUpdate},
+	} {
+		t.Run(testCase.expectedEventType, func(t *testing.T) {
+			pgEventSlice := AccessGroupMembershipAttributionsFromUtxoOps(
+				accessGroupMembersTxn(testCase.operationType), utxoOps, transaction, params)
+			require.Len(t, pgEventSlice, 2)
+			for ii, pgEvent := range pgEventSlice {
+				require.Equal(t, AccessGroupMembershipEvent{
+					AccessGroupOwnerPublicKey:  transaction.PublicKey,
+					AccessGroupKeyName:         "group",
+					AccessGroupMemberPublicKey: consumer.PublicKeyBytesToBase58Check(memberPublicKeys[ii], params),
+					BlockHeight:                100,
+					AccessGroupMemberKeyName:   "default-key",
+					EventType:                  testCase.expectedEventType,
+					ActorPublicKey:             transaction.PublicKey,
+					BlockHash:                  "block",
+					TxnHash:                    "txn",
+					Timestamp:                  transaction.Timestamp,
+				}, pgEvent.AccessGroupMembershipEvent)
+			}
+		})
+	}
+
+	// A transaction that didn't change any member entries isn't attributed.
+	require.Nil(t, AccessGroupMembershipAttributionsFromUtxoOps(
+		accessGroupMembersTxn(lib.AccessGroupMemberOperationTypeAdd),
+		[]*lib.UtxoOperation{{Type: lib.OperationTypeSpendBalance}}, transaction, params))
+}
+
+func TestAccessGroupMembershipEventKey(t *testing.T) {
+	event := &AccessGroupMembershipEvent{
+		AccessGroupOwnerPublicKey:  "owner",
+		AccessGroupKeyName:         "group",
+		AccessGroupMemberPublicKey: "member",
+		BlockHeight:                100,
+		EventType:                  AccessGroupMembershipEventTypeAdd,
+	}
+	updatedEvent := *event
+	updatedEvent.EventType = AccessGroupMembershipEventTypeUpdate
+	require.Equal(t, accessGroupMembershipEventKey(event), accessGroupMembershipEventKey(&updatedEvent))
+
+	laterEvent := *event
+	laterEvent.BlockHeight = 101
+	require.NotEqual(t, accessGroupMembershipEventKey(event), accessGroupMembershipEventKey(&laterEvent))
+}
@@ -0,0 +1,128 @@
+package entries
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+	"github.com/uptrace/bun"
+)
+
+// AssociationCountDelta is the net change in the number of associations of a given type and value on a target.
+type AssociationCountDelta struct {
+	Target           string
+	AssociationType  string
+	AssociationValue string
+	AppPKID          string
+	Delta            int64
+}
+
+// associationCountDeltas sums up association count changes by target, type, value and app.
+type associationCountDeltas struct {
+	deltaIndex map[string]int
+	deltas     []*AssociationCountDelta
+}
+
+func newAssociationCountDeltas() *associationCountDeltas {
+	return &associationCountDeltas{deltaIndex: make(map[string]int)}
+}
+
+func (deltas *associationCountDeltas) add(target string, associationType string, associationValue string, appPKID string, delta int64) {
+	deltaKey := fmt.Sprintf("%q:%q:%q:%q", target, associationType, associationValue, appPKID)
+	ii, ok := deltas.deltaIndex[deltaKey]
+	if !ok {
+		ii = len(deltas.deltas)
+		deltas.deltaIndex[deltaKey] = ii
+		deltas.deltas = append(deltas.deltas, &AssociationCountDelta{
+			Target:           target,
+			AssociationType:  associationType,
+			AssociationValue: associationValue,
+			AppPKID:          appPKID,
+		})
+	}
+	deltas.deltas[ii].Delta += delta
+}
+
+// nonZero returns the deltas that change a count.
+func (deltas *associationCountDeltas) nonZero() []*AssociationCountDelta {
+	var nonZeroDeltas []*AssociationCountDelta
+	for _, delta := range deltas.deltas {
+		if delta.Delta != 0 {
+			nonZeroDeltas = append(nonZeroDeltas, delta)
+		}
+	}
+	return nonZeroDeltas
+}
+
+// associationCountDrift is a count that a batch of deltas would take below zero.
+type associationCountDrift struct {
+	Target           string
+	AssociationType  string
+	AssociationValue string
+	AppPKID          string
+	Count            int64
+}
+
+// applyAssociationCountDeltas adds association count changes to a count table, whose target is identified by
+// targetColumn. Counts that drop to zero are deleted. A count that would drop below zero means the table has drifted
+// from the associations it counts, so it's logged and stored as zero.
+func applyAssociationCountDeltas(deltas *associationCountDeltas, tableName string, targetColumn string, db bun.IDB) error {
+	nonZeroDeltas := deltas.nonZero()
+	if len(nonZeroDeltas) == 0 {
+		return nil
+	}
+
+	var drifts []*associationCountDrift
+	if err := db.NewRaw(`
+		WITH _data (target, association_type, association_value, app_pkid, delta) AS (?)
+		SELECT _data.target, _data.association_type, _data.association_value, _data.app_pkid,
+			COALESCE(counts.count, 0) + _data.delta AS count
+		FROM _data
+		LEFT JOIN ? AS counts ON counts.? = _data.target
+			AND counts.association_type = _data.association_type
+			AND counts.association_value = _data.association_value
+			AND counts.app_pkid = _data.app_pkid
+		WHERE COALESCE(counts.count, 0) + _data.delta < 0
+	`, db.NewValues(&nonZeroDeltas), bun.Ident(tableName), bun.Ident(targetColumn)).
+		Scan(context.Background(), &drifts); err != nil {
+		return errors.Wrapf(err, "entries.applyAssociationCountDeltas: Error checking %v for drift", tableName)
+	}
+	for _, drift := range drifts {
+		glog.Warningf("entries.applyAssociationCountDeltas: %v count for %v %v, type %q, value %q and app %q "+
+			"would drop to %v, storing it as zero", tableName, targetColumn, drift.Target, drift.AssociationType,
+			drift.AssociationValue, drift.AppPKID, drift.Count)
+	}
+
+	if _, err := db.NewRaw(`
+		WITH _data (target, association_type, association_value, app_pkid, delta) AS (?)
+		INSERT INTO ? AS counts (?, association_type, association_value, app_pkid, count)
+		SELECT target, association_type, association_value, app_pkid, GREATEST(delta, 0)
+		FROM _data
+		ON CONFLICT (?, association_type, association_value, app_pkid) DO UPDATE SET
+			count = GREATEST(counts.count + (
+				SELECT _data.delta FROM _data
+				WHERE _data.target = EXCLUDED.?
+				AND _data.association_type = EXCLUDED.association_type
+				AND _data.association_value = EXCLUDED.association_value
+				AND _data.app_pkid = EXCLUDED.app_pkid
+			), 0)
+	`, db.NewValues(&nonZeroDeltas), bun.Ident(tableName), bun.Ident(targetColumn), bun.Ident(targetColumn),
+		bun.Ident(targetColumn)).Exec(context.Background()); err != nil {
+		return errors.Wrapf(err, "entries.applyAssociationCountDeltas: Error updating %v", tableName)
+	}
+
+	if _, err := db.NewRaw(`
+		WITH _data (target, association_type, association_value, app_pkid, delta) AS (?)
+		DELETE FROM ? AS counts
+		USING _data
+		WHERE counts.? = _data.target
+		AND counts.association_type = _data.association_type
+		AND counts.association_value = _data.association_value
+		AND counts.app_pkid = _data.app_pkid
+		AND counts.count = 0
+	`, db.NewValues(&nonZeroDeltas), bun.Ident(tableName), bun.Ident(targetColumn)).Exec(context.Background()); err != nil {
+		return errors.Wrapf(err, "entries.applyAssociationCountDeltas: Error deleting empty counts from %v", tableName)
+	}
+	return nil
+}
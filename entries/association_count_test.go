@@ -0,0 +1,29 @@
+package entries
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAssociationCountDeltas(t *testing.T) {
+	require.Nil(t, newAssociationCountDeltas().nonZero())
+
+	deltas := newAssociationCountDeltas()
+	deltas.add("post", "REACTION", "LIKE", "", 1)
+	deltas.add("post", "REACTION", "LOVE", "", 1)
+	deltas.add("post", "REACTION", "LIKE", "app", 1)
+	deltas.add("post", "REACTION", "LIKE", "", 1)
+	// An association that's created and deleted in the same batch doesn't change the count.
+	deltas.add("post", "REACTION", "LOVE", "", -1)
+	// Keys are quoted, so values containing the separator don't collide.
+	deltas.add("post", "REACTION", "A:B", "", 1)
+	deltas.add("post", "REACTION:A", "B", "", 1)
+
+	require.Equal(t, []*AssociationCountDelta{
+		{Target: "post", AssociationType: "REACTION", AssociationValue: "LIKE", Delta: 2},
+		{Target: "post", AssociationType: "REACTION", AssociationValue: "LIKE", AppPKID: "app", Delta: 1},
+		{Target: "post", AssociationType: "REACTION", AssociationValue: "A:B", Delta: 1},
+		{Target: "post", AssociationType: "REACTION:A", AssociationValue: "B", Delta: 1},
+	}, deltas.nonZero())
+}
@@ -0,0 +1,22 @@
+package entries
+
+// latestAttributions keeps a single attribution for each row of a history table, as a single insert or update can't
+// change the same row twice. Attributions are in the order their transactions were applied, so a later attribution
+// for a row replaces an earlier one, unless replaces is given and reports that it shouldn't. The kept attributions
+// stay in the order their rows were first seen.
+func latestAttributions[T any](attributions []T, rowKey func(T) string, replaces func(prev T, next T) bool) []T {
+	rowIndex := make(map[string]int)
+	var latest []T
+	for _, attribution := range attributions {
+		key := rowKey(attribution)
+		if ii, exists := rowIndex[key]; exists {
+			if replaces == nil || replaces(latest[ii], attribution) {
+				latest[ii] = attribution
+			}
+			continue
+		}
+		rowIndex[key] = len(latest)
+		latest = append(latest, attribution)
+	}
+	return latest
+}
@@ -0,0 +1,33 @@
+package entries
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testAttribution struct {
+	row     string
+	txnHash string
+}
+
+func TestLatestAttributions(t *testing.T) {
+	rowKey := func(attribution *testAttribution) string {
+		return attribution.row
+	}
+	first := &testAttribution{row: "a", txnHash: "1"}
+	second := &testAttribution{row: "b", txnHash: "2"}
+	third := &testAttribution{row: "a", txnHash: "3"}
+	restake := &testAttribution{row: "b"}
+
+	require.Empty(t, latestAttributions(nil, rowKey, nil))
+	require.Equal(t, []*testAttribution{third, restake},
+		latestAttributions([]*testAttribution{first, second, third, restake}, rowKey, nil))
+
+	// An attribution without a transaction doesn't replace one with a transaction.
+	keepTxnAttributions := func(prev *testAttribution, next *testAttribution) bool {
+		return next.txnHash != "" || prev.txnHash == ""
+	}
+	require.Equal(t, []*testAttribution{third, second},
+		latestAttributions([]*testAttribution{first, second, third, restake}, rowKey, keepTxnAttributions))
+}
@@ -31,6 +31,23 @@ type PGBalanceEntryUtxoOps struct {
 	UtxoOperation
 }
 
+// BalanceHistoryEntry records a holder's balance of a creator or DAO coin as of a given block height. The block hash
+// is set once the utxo operations of the block are processed, so that snapshots from orphaned blocks can be removed.
+type BalanceHistoryEntry struct {
+	HodlerPkid   string      `pg:",pk,use_zero"`
+	CreatorPkid  string      `pg:",pk,use_zero"`
+	IsDaoCoin    bool        `pg:",pk,use_zero"`
+	BlockHeight  uint64      `pg:",pk,use_zero"`
+	BalanceNanos *bunbig.Int `pg:",use_zero"`
+	HasPurchased bool        `pg:",use_zero"`
+	BlockHash    string      `bun:",nullzero"`
+}
+
+type PGBalanceHistoryEntry struct {
+	bun.BaseModel `bun:"table:balance_history"`
+	BalanceHistoryEntry
+}
+
 // Convert the DeSo encoder to the postgres struct used by bun.
 func BalanceEntryEncoderToPGStruct(balanceEntry *lib.BalanceEntry, keyBytes []byte, params *lib.DeSoParams) BalanceEntry {
 	return BalanceEntry{
@@ -84,6 +101,17 @@ func bulkInsertBalanceEntry(entries []*lib.StateChangeEntry, db bun.IDB, operati
 	if _, err := query.Returning("").Exec(context.Background()); err != nil {
 		return errors.Wrapf(err, "entries.bulkInsertBalanceEntry: Error inserting entries")
 	}
+
+	// Snapshot the new balances in the balance history.
+	pgHistorySlice := make([]*PGBalanceHistoryEntry, len(uniqueEntries))
+	for ii, entry := range uniqueEntries {
+		pgHistorySlice[ii] = &PGBalanceHistoryEntry{
+			BalanceHistoryEntry: BalanceEntryToHistoryEntry(&pgEntrySlice[ii].BalanceEntry, entry.BlockHeight),
+		}
+	}
+	if err := bulkInsertBalanceHistoryEntry(pgHistorySlice, db); err != nil {
+		return errors.Wrapf(err, "entries.bulkInsertBalanceEntry: Error inserting balance history")
+	}
 	return nil
 }
 
@@ -95,6 +123,15 @@ func bulkDeleteBalanceEntry(entries []*lib.StateChangeEntry, db bun.IDB, operati
 	// Transform the entries into a list of keys to delete.
 	keysToDelete := consumer.KeysToDelete(uniqueEntries)
 
+	// Look up the balances being deleted, so that we can record their removal in the balance history.
+	var prevEntries []*PGBalanceEntry
+	if err := db.NewSelect().
+		Model(&prevEntries).
+		Where("badger_key IN (?)", bun.In(keysToDelete)).
+		Scan(context.Background()); err != nil {
+		return errors.Wrapf(err, "entries.bulkDeleteBalanceEntry: Error getting previous balances")
+	}
+
 	// Execute the delete query.
 	if _, err := db.NewDelete().
 		Model(&PGBalanceEntry{}).
@@ -104,5 +141,74 @@ func bulkDeleteBalanceEntry(entries []*lib.StateChangeEntry, db bun.IDB, operati
 		return errors.Wrapf(err, "entries.bulkDeleteBalanceEntry: Error deleting entries")
 	}
 
+	// Record each deleted balance as a zero balance at the height it was deleted.
+	blockHeightsByKey := make(map[string]uint64)
+	for _, entry := range uniqueEntries {
+		blockHeightsByKey[string(entry.KeyBytes)] = entry.BlockHeight
+	}
+	pgHistorySlice := make([]*PGBalanceHistoryEntry, len(prevEntries))
+	for ii, prevEntry := range prevEntries {
+		pgHistoryEntry := BalanceEntryToHistoryEntry(&prevEntry.BalanceEntry, blockHeightsByKey[string(prevEntry.BadgerKey)])
+		pgHistoryEntry.BalanceNanos = bunbig.FromInt64(0)
+		pgHistorySlice[ii] = &PGBalanceHistoryEntry{BalanceHistoryEntry: pgHistoryEntry}
+	}
+	if err := bulkInsertBalanceHistoryEntry(pgHistorySlice, db); err != nil {
+		return errors.Wrapf(err, "entries.bulkDeleteBalanceEntry: Error inserting balance history")
+	}
+
+	return nil
+}
+
+// BalanceEntryToHistoryEntry converts a balance entry to a snapshot of that balance at the given block height.
+func BalanceEntryToHistoryEntry(balanceEntry *BalanceEntry, blockHeight uint64) BalanceHistoryEntry {
+	return BalanceHistoryEntry{
+		HodlerPkid:   balanceEntry.HodlerPkid,
+		CreatorPkid:  balanceEntry.CreatorPkid,
+		IsDaoCoin:    balanceEntry.IsDaoCoin,
+		BlockHeight:  blockHeight,
+		BalanceNanos: balanceEntry.BalanceNanos,
+		HasPurchased: balanceEntry.HasPurchased,
+	}
+}
+
+// bulkInsertBalanceHistoryEntry inserts a batch of balance snapshots into the balance history. If a balance changes
+// more than once at the same block height, only the latest snapshot is kept.
+func bulkInsertBalanceHistoryEntry(pgHistorySlice []*PGBalanceHistoryEntry, db bun.IDB) error {
+	if len(pgHistorySlice) == 0 {
+		return nil
+	}
+	if _, err := db.NewInsert().
+		Model(&pgHistorySlice).
+		On("CONFLICT (hodler_pkid, creator_pkid, is_dao_coin, block_height) DO UPDATE").
+		Set("balance_nanos = EXCLUDED.balance_nanos").
+		Set("has_purchased = EXCLUDED.has_purchased").
+		Returning("").
+		Exec(context.Background()); err != nil {
+		return errors.Wrapf(err, "entries.bulkInsertBalanceHistoryEntry: Error inserting entries")
+	}
+	return nil
+}
+
+// BalanceHistoryBlock is a block whose utxo operations were processed, to be linked to the balance snapshots taken
+// at its height.
+type BalanceHistoryBlock struct {
+	BlockHeight uint64
+	BlockHash   string
+}
+
+// attributeBalanceHistoryToBlocks links the balance snapshots at the height of each block to that block.
+func attributeBalanceHistoryToBlocks(blocks []*BalanceHistoryBlock, db bun.IDB) error {
+	if len(blocks) == 0 {
+		return nil
+	}
+	if _, err := db.NewRaw(`
+		WITH _data (block_height, block_hash) AS (?)
+		UPDATE balance_history
+		SET block_hash = _data.block_hash
+		FROM _data
+		WHERE balance_history.block_height = _data.block_height AND balance_history.block_hash IS NULL
+	`, db.NewValues(&blocks)).Exec(context.Background()); err != nil {
+		return errors.Wrapf(err, "entries.attributeBalanceHistoryToBlocks: Error updating balance history")
+	}
 	return nil
 }
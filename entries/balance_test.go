@@ -0,0 +1,27 @@
+package entries
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun/extra/bunbig"
+)
+
+func TestBalanceEntryToHistoryEntry(t *testing.T) {
+	balanceNanos := bunbig.FromInt64(1000)
+	require.Equal(t, BalanceHistoryEntry{
+		HodlerPkid:   "hodler",
+		CreatorPkid:  "creator",
+		IsDaoCoin:    true,
+		BlockHeight:  100,
+		BalanceNanos: balanceNanos,
+		HasPurchased: true,
+	}, BalanceEntryToHistoryEntry(&BalanceEntry{
+		HodlerPkid:   "hodler",
+		CreatorPkid:  "creator",
+		BalanceNanos: balanceNanos,
+		HasPurchased: true,
+		IsDaoCoin:    true,
+		BadgerKey:    []byte("key"),
+	}, 100))
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/deso-protocol/state-consumer/consumer"
 	"github.com/pkg/errors"
 	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/extra/bunbig"
 )
 
 type BlockEntry struct {
@@ -25,6 +26,7 @@ type BlockEntry struct {
 	ProposerRandomSeedSignature  string `pg:",use_zero"`
 	ProposedInView               uint64
 	ProposerVotePartialSignature string `pg:",use_zero"`
+	ProposerPKID                 string `bun:",nullzero"`
 	// TODO: Quorum Certificates. Separate entry.
 
 	BadgerKey []byte `pg:",use_zero"`
@@ -38,6 +40,9 @@ type PGBlockEntry struct {
 type BlockSigner struct {
 	BlockHash   string
 	SignerIndex uint64
+	SignerPKID  string `bun:",nullzero"`
+	// The signer's stake in the validator set the quorum certificate was signed by.
+	StakeAmountNanos *bunbig.Int `bun:",nullzero"`
 }
 
 type PGBlockSigner struct {
@@ -153,6 +158,10 @@ func bulkInsertBlockEntry(entries []*lib.StateChangeEntry, db bun.IDB, operation
 		}
 	}
 
+	if err := resolveBlockValidators(db, pgBlockEntrySlice, pgBlockSignersEntrySlice); err != nil {
+		return errors.Wrapf(err, "entries.bulkInsertBlock: Error resolving block validators")
+	}
+
 	blockQuery := db.NewInsert().Model(&pgBlockEntrySlice)
 
 	if operationType == lib.DbOperationTypeUpsert {
@@ -180,6 +189,17 @@ func bulkInsertBlockEntry(entries []*lib.StateChangeEntry, db bun.IDB, operation
 		}
 	}
 
+	// Record the derived keys that expire with these blocks. Initial sync blocks returned early above, so this only
+	// runs for blocks upserted once the initial sync is done.
+	if err := bulkInsertDerivedKeyExpirationEvents(pgBlockEntrySlice, db); err != nil {
+		return errors.Wrapf(err, "entries.bulkInsertBlockEntry: Error inserting derived key expiration events")
+	}
+
+	// The first block of an epoch carries the last quorum certificate of the previous epoch.
+	if err := upsertValidatorPerformanceForBlocks(db, pgBlockEntrySlice); err != nil {
+		return errors.Wrapf(err, "entries.bulkInsertBlockEntry: Error upserting validator performance")
+	}
+
 	return nil
 }
 
@@ -197,6 +217,16 @@ func bulkDeleteBlockEntry(entries []*lib.StateChangeEntry, db bun.IDB, operation
 // bulkDeleteBlockEntriesFromKeysToDelete deletes a batch of block entries from the database.
 // It also deletes any transactions and utxo operations associated with the block.
 func bulkDeleteBlockEntriesFromKeysToDelete(db bun.IDB, keysToDelete [][]byte) error {
+	// Look up the heights of the blocks before they're deleted, so that history tables keyed by height can be pruned.
+	var blockHeightsToDelete []uint64
+	if err := db.NewSelect().
+		Model((*PGBlockEntry)(nil)).
+		Column("height").
+		Where("badger_key IN (?)", bun.In(keysToDelete)).
+		Scan(context.Background(), &blockHeightsToDelete); err != nil {
+		return errors.Wrapf(err, "entries.bulkDeleteBlockEntry: Error getting block heights")
+	}
+
 	// Execute the delete query on the blocks table.
 	if _, err := db.NewDelete().
 		Model(&PGBlockEntry{}).
@@ -246,6 +276,75 @@ func bulkDeleteBlockEntriesFromKeysToDelete(db bun.IDB, keysToDelete [][]byte) e
 		Exec(context.Background()); err != nil {
 		return errors.Wrapf(err, "entries.bulkDeleteBlockEntry: Error deleting stake rewards")
 	}
+
+	// Delete any nft sales associated with the block.
+	if _, err := db.NewDelete().
+		Model(&PGNftSale{}).
+		Where("block_hash IN (?)", bun.In(blockHashHexesToDelete)).
+		Returning("").
+		Exec(context.Background()); err != nil {
+		return errors.Wrapf(err, "entries.bulkDeleteBlockEntry: Error deleting nft sales")
+	}
+
+	// Delete any creator coin trades associated with the block.
+	if _, err := db.NewDelete().
+		Model(&PGCreatorCoinTrade{}).
+		Where("block_hash IN (?)", bun.In(blockHashHexesToDelete)).
+		Returning("").
+		Exec(context.Background()); err != nil {
+		return errors.Wrapf(err, "entries.bulkDeleteBlockEntry: Error deleting creator coin trades")
+	}
+
+	// Delete any validator key rotations associated with the block.
+	if _, err := db.NewDelete().
+		Model(&PGValidatorKeyRotation{}).
+		Where("block_hash IN (?)", bun.In(blockHashHexesToDelete)).
+		Returning("").
+		Exec(context.Background()); err != nil {
+		return errors.Wrapf(err, "entries.bulkDeleteBlockEntry: Error deleting validator key rotations")
+	}
+
+	// Delete any unstakes made in the block, and revert any unlocks made in it.
+	if err := deleteUnstakeLifecyclesForBlocks(db, blockHashHexesToDelete); err != nil {
+		return errors.Wrapf(err, "entries.bulkDeleteBlockEntry: Error deleting unstake lifecycles")
+	}
+
+	// Delete any history rows that were attributed to the block. Rows that haven't been attributed to a block yet
+	// are pruned by height.
+	blockAttributedModels := []struct {
+		model interface{}
+		name  string
+	}{
+		{&PGDesoBalanceHistoryEntry{}, "deso balance history"},
+		{&PGBalanceHistoryEntry{}, "balance history"},
+		{&PGProfileHistoryEntry{}, "profile history"},
+		{&PGFollowEvent{}, "follow events"},
+		{&PGPostRevision{}, "post revisions"},
+		{&PGAccessGroupMembershipEvent{}, "access group membership events"},
+		{&PGDerivedKeyExpirationEvent{}, "derived key expiration events"},
+		{&PGStakePositionHistoryEntry{}, "stake position history"},
+	}
+	for _, blockAttributedModel := range blockAttributedModels {
+		if err := deleteBlockAttributedRows(
+			db, blockAttributedModel.model, blockHashHexesToDelete, blockHeightsToDelete); err != nil {
+			return errors.Wrapf(err, "entries.bulkDeleteBlockEntry: Error deleting %v", blockAttributedModel.name)
+		}
+	}
+	return nil
+}
+
+// deleteBlockAttributedRows deletes the rows of a history table that belong to the given blocks. These tables are
+// keyed by block height, and are only linked to a block hash once the utxo operations of the block are processed.
+func deleteBlockAttributedRows(db bun.IDB, model interface{}, blockHashes []string, blockHeights []uint64) error {
+	query := db.NewDelete().
+		Model(model).
+		Where("block_hash IN (?)", bun.In(blockHashes))
+	if len(blockHeights) > 0 {
+		query = query.WhereOr("block_hash IS NULL AND block_height IN (?)", bun.In(blockHeights))
+	}
+	if _, err := query.Returning("").Exec(context.Background()); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -0,0 +1,210 @@
+package entries
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"github.com/uptrace/bun"
+)
+
+// validatorPKIDTieOrder breaks stake ties between validators the way the core validator set does, by descending PKID
+// bytes. PKIDs are stored base58 encoded with the same prefix, and the base58 alphabet is in byte order, so longer
+// encodings are larger and equal length encodings compare bytewise.
+const validatorPKIDTieOrder = `length(validator_pkid) DESC, validator_pkid COLLATE "C" DESC`
+
+// resolveBlockValidators sets the PKID of each block's proposer, and the PKID and stake of each block signer.
+// Blocks are proposed by a validator of their own epoch, found through the epoch's snapshot of voting public keys.
+// A block's quorum certificate signs its parent, so signer indexes refer to the validator set of the parent's epoch,
+// ordered by stake. Blocks whose epoch or snapshots aren't stored yet are left unresolved, and are resolved by
+// resolveUnresolvedBlockValidators once those arrive.
+func resolveBlockValidators(db bun.IDB, pgBlockEntrySlice []*PGBlockEntry, pgBlockSignerSlice []*PGBlockSigner) error {
+	if len(pgBlockEntrySlice) == 0 {
+		return nil
+	}
+	blockHeights := make(map[string]uint64)
+	minHeight, maxHeight := pgBlockEntrySlice[0].Height, pgBlockEntrySlice[0].Height
+	for _, pgBlockEntry := range pgBlockEntrySlice {
+		blockHeights[pgBlockEntry.BlockHash] = pgBlockEntry.Height
+		if pgBlockEntry.Height < minHeight {
+			minHeight = pgBlockEntry.Height
+		}
+		if pgBlockEntry.Height > maxHeight {
+			maxHeight = pgBlockEntry.Height
+		}
+	}
+	if minHeight > 0 {
+		minHeight--
+	}
+
+	// Find the snapshot used at each height, from the epochs covering the blocks and their parents.
+	var epochEntries []*PGEpochEntry
+	if err := db.NewSelect().
+		Model(&epochEntries).
+		Column("initial_block_height", "final_block_height", "snapshot_at_epoch_number").
+		Where("final_block_height >= ?", minHeight).
+		Where("initial_block_height <= ?", maxHeight).
+		Scan(context.Background()); err != nil {
+		return errors.Wrapf(err, "entries.resolveBlockValidators: Error getting epoch entries")
+	}
+	if len(epochEntries) == 0 {
+		return nil
+	}
+	snapshotAtHeight := func(height uint64) (uint64, bool) {
+		for _, epochEntry := range epochEntries {
+			if epochEntry.InitialBlockHeight <= height && height <= epochEntry.FinalBlockHeight {
+				return epochEntry.SnapshotAtEpochNumber, true
+			}
+		}
+		return 0, false
+	}
+	snapshotAtEpochNumbers := make([]uint64, len(epochEntries))
+	for ii, epochEntry := range epochEntries {
+		snapshotAtEpochNumbers[ii] = epochEntry.SnapshotAtEpochNumber
+	}
+
+	// Resolve the proposers from their voting public keys.
+	proposerVotingPublicKeys := make([]string, 0, len(pgBlockEntrySlice))
+	for _, pgBlockEntry := range pgBlockEntrySlice {
+		if pgBlockEntry.ProposerVotingPublicKey != "" {
+			proposerVotingPublicKeys = append(proposerVotingPublicKeys, pgBlockEntry.ProposerVotingPublicKey)
+		}
+	}
+	if len(proposerVotingPublicKeys) > 0 {
+		var blsPkidPairs []*PGBLSPublicKeyPKIDPairSnapshotEntry
+		if err := db.NewSelect().
+			Model(&blsPkidPairs).
+			Column("pkid", "bls_public_key", "snapshot_at_epoch_number").
+			Where("snapshot_at_epoch_number IN (?)", bun.In(snapshotAtEpochNumbers)).
+			Where("bls_public_key IN (?)", bun.In(proposerVotingPublicKeys)).
+			Scan(context.Background()); err != nil {
+			return errors.Wrapf(err, "entries.resolveBlockValidators: Error getting bls public key pkid pairs")
+		}
+		proposerPkids := make(map[uint64]map[string]string)
+		for _, blsPkidPair := range blsPkidPairs {
+			if _, exists := proposerPkids[blsPkidPair.SnapshotAtEpochNumber]; !exists {
+				proposerPkids[blsPkidPair.SnapshotAtEpochNumber] = make(map[string]string)
+			}
+			proposerPkids[blsPkidPair.SnapshotAtEpochNumber][blsPkidPair.BLSPublicKey] = blsPkidPair.PKID
+		}
+		for _, pgBlockEntry := range pgBlockEntrySlice {
+			if snapshotAtEpochNumber, exists := snapshotAtHeight(pgBlockEntry.Height); exists {
+				pgBlockEntry.ProposerPKID = proposerPkids[snapshotAtEpochNumber][pgBlockEntry.ProposerVotingPublicKey]
+			}
+		}
+	}
+
+	// Resolve the signers from their positions in the validator set.
+	if len(pgBlockSignerSlice) == 0 {
+		return nil
+	}
+	var validatorEntries []*PGSnapshotValidatorEntry
+	if err := db.NewSelect().
+		Model(&validatorEntries).
+		Column("validator_pkid", "total_stake_amount_nanos", "snapshot_at_epoch_number").
+		Where("snapshot_at_epoch_number IN (?)", bun.In(snapshotAtEpochNumbers)).
+		Order("snapshot_at_epoch_number", "total_stake_amount_nanos DESC").
+		OrderExpr(validatorPKIDTieOrder).
+		Scan(context.Background()); err != nil {
+		return errors.Wrapf(err, "entries.resolveBlockValidators: Error getting snapshot validator entries")
+	}
+	validatorSets := make(map[uint64][]*PGSnapshotValidatorEntry)
+	for _, validatorEntry := range validatorEntries {
+		validatorSets[validatorEntry.SnapshotAtEpochNumber] = append(
+			validatorSets[validatorEntry.SnapshotAtEpochNumber], validatorEntry)
+	}
+	for _, pgBlockSigner := range pgBlockSignerSlice {
+		height, exists := blockHeights[pgBlockSigner.BlockHash]
+		if !exists || height == 0 {
+			continue
+		}
+		snapshotAtEpochNumber, exists := snapshotAtHeight(height - 1)
+		if !exists {
+			continue
+		}
+		validatorSet := validatorSets[snapshotAtEpochNumber]
+		if pgBlockSigner.SignerIndex >= uint64(len(validatorSet)) {
+			continue
+		}
+		pgBlockSigner.SignerPKID = validatorSet[pgBlockSigner.SignerIndex].ValidatorPKID
+		pgBlockSigner.StakeAmountNanos = validatorSet[pgBlockSigner.SignerIndex].TotalStakeAmountNanos
+	}
+	return nil
+}
+
+// resolveUnresolvedBlockValidators resolves the proposers and signers of stored blocks that were left unresolved
+// because the epoch or snapshots they depend on hadn't been stored yet. It's run whenever epochs, validator snapshots
+// or voting public key snapshots are stored, for the snapshots they belong to. Completed epochs with newly resolved
+// blocks are measured again, since their performance depends on the proposers and signers.
+func resolveUnresolvedBlockValidators(db bun.IDB, snapshotAtEpochNumbers []uint64) error {
+	if len(snapshotAtEpochNumbers) == 0 {
+		return nil
+	}
+
+	proposersResult, err := db.NewRaw(`
+		UPDATE block
+		SET proposer_pkid = bls.pkid
+		FROM epoch_entry, bls_public_key_pkid_pair_snapshot_entry bls
+		WHERE block.proposer_pkid IS NULL
+		AND epoch_entry.snapshot_at_epoch_number IN (?)
+		AND block.height BETWEEN epoch_entry.initial_block_height AND epoch_entry.final_block_height
+		AND bls.snapshot_at_epoch_number = epoch_entry.snapshot_at_epoch_number
+		AND bls.bls_public_key = block.proposer_voting_public_key
+	`, bun.In(snapshotAtEpochNumbers)).Exec(context.Background())
+	if err != nil {
+		return errors.Wrapf(err, "entries.resolveUnresolvedBlockValidators: Error resolving block proposers")
+	}
+
+	signersResult, err := db.NewRaw(`
+		WITH validator_set AS (
+			SELECT
+				snapshot_at_epoch_number,
+				validator_pkid,
+				total_stake_amount_nanos,
+				row_number() OVER (
+					PARTITION BY snapshot_at_epoch_number ORDER BY total_stake_amount_nanos DESC, `+validatorPKIDTieOrder+`
+				) - 1 AS signer_index
+			FROM snapshot_validator_entry
+			WHERE snapshot_at_epoch_number IN (?0)
+		), signer AS (
+			SELECT block_signer.block_hash, block_signer.signer_index, validator_set.validator_pkid, validator_set.total_stake_amount_nanos
+			FROM block_signer
+			JOIN block ON block.block_hash = block_signer.block_hash
+			JOIN epoch_entry ON block.height - 1 BETWEEN epoch_entry.initial_block_height AND epoch_entry.final_block_height
+			JOIN validator_set ON validator_set.snapshot_at_epoch_number = epoch_entry.snapshot_at_epoch_number
+				AND validator_set.signer_index = block_signer.signer_index
+			WHERE block_signer.signer_pkid IS NULL
+			AND epoch_entry.snapshot_at_epoch_number IN (?0)
+		)
+		UPDATE block_signer
+		SET signer_pkid = signer.validator_pkid, stake_amount_nanos = signer.total_stake_amount_nanos
+		FROM signer
+		WHERE block_signer.block_hash = signer.block_hash AND block_signer.signer_index = signer.signer_index
+	`, bun.In(snapshotAtEpochNumbers)).Exec(context.Background())
+	if err != nil {
+		return errors.Wrapf(err, "entries.resolveUnresolvedBlockValidators: Error resolving block signers")
+	}
+
+	numProposers, err := proposersResult.RowsAffected()
+	if err != nil {
+		return errors.Wrapf(err, "entries.resolveUnresolvedBlockValidators: Error getting resolved proposers")
+	}
+	numSigners, err := signersResult.RowsAffected()
+	if err != nil {
+		return errors.Wrapf(err, "entries.resolveUnresolvedBlockValidators: Error getting resolved signers")
+	}
+	if numProposers == 0 && numSigners == 0 {
+		return nil
+	}
+	var epochNumbers []uint64
+	if err = db.NewSelect().
+		Model((*PGEpochEntry)(nil)).
+		Column("epoch_number").
+		Where("snapshot_at_epoch_number IN (?)", bun.In(snapshotAtEpochNumbers)).
+		Scan(context.Background(), &epochNumbers); err != nil {
+		return errors.Wrapf(err, "entries.resolveUnresolvedBlockValidators: Error getting epoch numbers")
+	}
+	if err = upsertValidatorPerformance(db, epochNumbers); err != nil {
+		return errors.Wrapf(err, "entries.resolveUnresolvedBlockValidators: Error upserting performance")
+	}
+	return nil
+}
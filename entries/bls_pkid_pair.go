@@ -147,6 +147,15 @@ func bulkInsertBLSPkidPairEntry(
 		if _, err := query.Returning("").Exec(context.Background()); err != nil {
 			return errors.Wrapf(err, "entries.bulkInsertBLSPkidPairEntry: Error inserting snapshot entries")
 		}
+
+		// Block proposers stored before their voting public key snapshot can now be resolved.
+		snapshotAtEpochNumbers := make([]uint64, len(pgBLSPkidPairSnapshotEntrySlice))
+		for ii, pgSnapshotEntry := range pgBLSPkidPairSnapshotEntrySlice {
+			snapshotAtEpochNumbers[ii] = pgSnapshotEntry.SnapshotAtEpochNumber
+		}
+		if err := resolveUnresolvedBlockValidators(db, snapshotAtEpochNumbers); err != nil {
+			return errors.Wrapf(err, "entries.bulkInsertBLSPkidPairEntry: Error resolving block validators")
+		}
 	}
 
 	// Update the cache with the new entries.
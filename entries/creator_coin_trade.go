@@ -0,0 +1,162 @@
+package entries
+
+import (
+	"bytes"
+	"math/big"
+	"time"
+
+	"github.com/deso-protocol/core/lib"
+	"github.com/deso-protocol/state-consumer/consumer"
+	"github.com/uptrace/bun"
+)
+
+const (
+	CreatorCoinTradeDirectionBuy  = "buy"
+	CreatorCoinTradeDirectionSell = "sell"
+)
+
+// CreatorCoinTrade is a record of a single creator coin buy or sell, along with the state of the coin before and
+// after the trade was executed. The DeSo amount is what the trader spent on a buy, or received from a sell. The
+// trading fee is the DeSo that was burned rather than locked in the coin or paid out, and the founder reward is the
+// DeSo paid to the creator on a buy.
+type CreatorCoinTrade struct {
+	TransactionHash         string `pg:",pk,use_zero"`
+	TraderPublicKey         string `pg:",use_zero"`
+	CreatorPublicKey        string `pg:",use_zero"`
+	Direction               string `pg:",use_zero"`
+	DesoAmountNanos         uint64 `pg:",use_zero"`
+	CreatorCoinAmountNanos  uint64 `pg:",use_zero"`
+	FounderRewardNanos      uint64 `pg:",use_zero"`
+	TradingFeeNanos         uint64 `pg:",use_zero"`
+	PriceBeforeNanos        float64
+	PriceAfterNanos         float64
+	DesoLockedNanosBefore   uint64    `pg:",use_zero"`
+	DesoLockedNanosAfter    uint64    `pg:",use_zero"`
+	CoinsInCirculationNanos uint64    `pg:",use_zero"`
+	BlockHash               string    `pg:",use_zero"`
+	BlockHeight             uint64    `pg:",use_zero"`
+	Timestamp               time.Time `pg:",use_zero"`
+}
+
+type PGCreatorCoinTrade struct {
+	bun.BaseModel `bun:"table:creator_coin_trade"`
+	CreatorCoinTrade
+}
+
+// CreatorCoinUtxoOpToPGStruct converts a creator coin transaction and its utxo operations to the CreatorCoinTrade
+// struct used by bun. The DeSo amounts are taken from the utxo operations: the locked DeSo from the creator coin
+// operation, and the founder reward and sale proceeds from the DeSo the transaction paid out. The second return
+// value is false if the transaction isn't a buy or a sell.
+func CreatorCoinUtxoOpToPGStruct(
+	creatorCoinMetadata *lib.CreatorCoinMetadataa,
+	utxoOps []*lib.UtxoOperation,
+	transaction *PGTransactionEntry,
+	params *lib.DeSoParams,
+) (CreatorCoinTrade, bool) {
+	creatorCoinUtxoOp := consumer.GetUtxoOpByOperationType(utxoOps, lib.OperationTypeCreatorCoin)
+	if creatorCoinUtxoOp == nil || creatorCoinUtxoOp.PrevCoinEntry == nil {
+		return CreatorCoinTrade{}, false
+	}
+	prevCoinEntry := creatorCoinUtxoOp.PrevCoinEntry
+	desoLockedNanosDiff := creatorCoinUtxoOp.CreatorCoinDESOLockedNanosDiff
+
+	creatorCoinTrade := CreatorCoinTrade{
+		TransactionHash:       transaction.TransactionHash,
+		TraderPublicKey:       transaction.PublicKey,
+		CreatorPublicKey:      consumer.PublicKeyBytesToBase58Check(creatorCoinMetadata.ProfilePublicKey, params),
+		DesoLockedNanosBefore: prevCoinEntry.DeSoLockedNanos,
+		DesoLockedNanosAfter:  uint64(int64(prevCoinEntry.DeSoLockedNanos) + desoLockedNanosDiff),
+		BlockHash:             transaction.BlockHash,
+		BlockHeight:           transaction.BlockHeight,
+		Timestamp:             transaction.Timestamp,
+	}
+	coinsInCirculationNanosBefore := prevCoinEntry.CoinsInCirculationNanos.Uint64()
+
+	switch creatorCoinMetadata.OperationType {
+	case lib.CreatorCoinOperationTypeBuy:
+		creatorCoinTrade.Direction = CreatorCoinTradeDirectionBuy
+		creatorCoinTrade.DesoAmountNanos = creatorCoinMetadata.DeSoToSellNanos
+		// The founder reward is paid in DeSo from the DeSo founder reward fork on. Before it, no DeSo is paid to
+		// the creator, and their reward is minted as creator coins instead.
+		creatorCoinTrade.FounderRewardNanos = creatorCoinDesoPaidNanos(
+			utxoOps, creatorCoinMetadata.ProfilePublicKey, lib.UtxoTypeCreatorCoinFounderReward)
+		creatorCoinTrade.TradingFeeNanos = subtractOrZero(creatorCoinMetadata.DeSoToSellNanos,
+			uint64(desoLockedNanosDiff)+creatorCoinTrade.FounderRewardNanos)
+		// The creator coin operation doesn't record the coins it minted, so they're computed from the DeSo it locked
+		// with the same function consensus uses.
+		creatorCoinTrade.CreatorCoinAmountNanos = lib.CalculateCreatorCoinToMint(
+			uint64(desoLockedNanosDiff), coinsInCirculationNanosBefore, prevCoinEntry.DeSoLockedNanos, params)
+		creatorCoinTrade.CoinsInCirculationNanos = coinsInCirculationNanosBefore + creatorCoinTrade.CreatorCoinAmountNanos
+	case lib.CreatorCoinOperationTypeSell:
+		creatorCoinTrade.Direction = CreatorCoinTradeDirectionSell
+		traderPublicKeyBytes, _, err := lib.Base58CheckDecode(transaction.PublicKey)
+		if err != nil {
+			return CreatorCoinTrade{}, false
+		}
+		creatorCoinTrade.DesoAmountNanos = creatorCoinDesoPaidNanos(
+			utxoOps, traderPublicKeyBytes, lib.UtxoTypeCreatorCoinSale)
+		creatorCoinTrade.TradingFeeNanos = subtractOrZero(uint64(-desoLockedNanosDiff), creatorCoinTrade.DesoAmountNanos)
+		creatorCoinTrade.CreatorCoinAmountNanos = creatorCoinMetadata.CreatorCoinToSellNanos
+		// A sell that would leave the trader with less than the auto sell threshold sells their whole balance.
+		if prevBalanceEntry := creatorCoinUtxoOp.PrevTransactorBalanceEntry; prevBalanceEntry != nil {
+			prevBalanceNanos := prevBalanceEntry.BalanceNanos.Uint64()
+			if subtractOrZero(prevBalanceNanos, creatorCoinTrade.CreatorCoinAmountNanos) < params.CreatorCoinAutoSellThresholdNanos {
+				creatorCoinTrade.CreatorCoinAmountNanos = prevBalanceNanos
+			}
+		}
+		creatorCoinTrade.CoinsInCirculationNanos = subtractOrZero(
+			coinsInCirculationNanosBefore, creatorCoinTrade.CreatorCoinAmountNanos)
+	default:
+		return CreatorCoinTrade{}, false
+	}
+
+	creatorCoinTrade.PriceBeforeNanos = creatorCoinPriceNanos(
+		creatorCoinTrade.DesoLockedNanosBefore, coinsInCirculationNanosBefore, params)
+	creatorCoinTrade.PriceAfterNanos = creatorCoinPriceNanos(
+		creatorCoinTrade.DesoLockedNanosAfter, creatorCoinTrade.CoinsInCirculationNanos, params)
+	return creatorCoinTrade, true
+}
+
+// creatorCoinDesoPaidNanos returns the DeSo a creator coin transaction paid out to a public key: the founder reward
+// of a buy, or the proceeds of a sell. Creator coin payouts are the transaction's last DeSo additions, after the DeSo
+// it spent. Under the balance model they're balance additions, and under the UTXO model they're outputs of the given
+// UTXO type.
+func creatorCoinDesoPaidNanos(utxoOps []*lib.UtxoOperation, publicKey []byte, utxoType lib.UtxoType) uint64 {
+	paidNanos := uint64(0)
+	for _, utxoOp := range utxoOps {
+		switch utxoOp.Type {
+		case lib.OperationTypeSpendBalance, lib.OperationTypeSpendUtxo:
+			paidNanos = 0
+		case lib.OperationTypeAddBalance:
+			if bytes.Equal(utxoOp.BalancePublicKey, publicKey) {
+				paidNanos = utxoOp.BalanceAmountNanos
+			}
+		case lib.OperationTypeAddUtxo:
+			if utxoOp.Entry != nil && utxoOp.Entry.UtxoType == utxoType && bytes.Equal(utxoOp.Entry.PublicKey, publicKey) {
+				paidNanos = utxoOp.Entry.AmountNanos
+			}
+		}
+	}
+	return paidNanos
+}
+
+func subtractOrZero(a uint64, b uint64) uint64 {
+	if a < b {
+		return 0
+	}
+	return a - b
+}
+
+// creatorCoinPriceNanos returns the bancor spot price, in DeSo nanos, of one whole creator coin.
+func creatorCoinPriceNanos(desoLockedNanos uint64, coinsInCirculationNanos uint64, params *lib.DeSoParams) float64 {
+	if coinsInCirculationNanos == 0 {
+		return 0
+	}
+	// price = desoLocked / (coinsInCirculation * reserveRatio), scaled up from coin nanos to whole coins.
+	denominator := new(big.Float).Mul(
+		new(big.Float).SetUint64(coinsInCirculationNanos), params.CreatorCoinReserveRatio)
+	price := new(big.Float).Quo(new(big.Float).SetUint64(desoLockedNanos), denominator)
+	price.Mul(price, new(big.Float).SetUint64(lib.NanosPerUnit))
+	priceFloat, _ := price.Float64()
+	return priceFloat
+}
@@ -0,0 +1,176 @@
+package entries
+
+import (
+	"testing"
+
+	"github.com/deso-protocol/core/lib"
+	"github.com/deso-protocol/state-consumer/consumer"
+	"github.com/deso-protocol/uint256"
+	"github.com/stretchr/testify/require"
+)
+
+func testPublicKeyBytes(seed byte) []byte {
+	publicKey := make([]byte, 33)
+	publicKey[0] = 0x02
+	for ii := 1; ii < len(publicKey); ii++ {
+		publicKey[ii] = seed
+	}
+	return publicKey
+}
+
+func testCreatorCoinTransaction(traderPublicKey []byte, params *lib.DeSoParams) *PGTransactionEntry {
+	return &PGTransactionEntry{TransactionEntry: TransactionEntry{
+		TransactionHash: "txn",
+		PublicKey:       consumer.PublicKeyBytesToBase58Check(traderPublicKey, params),
+		BlockHash:       "block",
+		BlockHeight:     100,
+	}}
+}
+
+func TestCreatorCoinUtxoOpToPGStructBuy(t *testing.T) {
+	params := &lib.DeSoMainnetParams
+	traderPublicKey := testPublicKeyBytes(1)
+	creatorPublicKey := testPublicKeyBytes(2)
+	creatorCoinUtxoOp := &lib.UtxoOperation{
+		Type: lib.OperationTypeCreatorCoin,
+		PrevCoinEntry: &lib.CoinEntry{
+			DeSoLockedNanos:         5000,
+			CoinsInCirculationNanos: *uint256.NewInt(1e9),
+		},
+		CreatorCoinDESOLockedNanosDiff: 890,
+	}
+
+	for _, testCase := range []struct {
+		name     string
+		utxoOps  []*lib.UtxoOperation
+		expected uint64
+	}{
+		{
+			name: "balance model",
+			utxoOps: []*lib.UtxoOperation{
+				// A balance added to the creator before the transaction spends its DeSo isn't a founder reward.
+				{Type: lib.OperationTypeAddBalance, BalancePublicKey: creatorPublicKey, BalanceAmountNanos: 7},
+				{Type: lib.OperationTypeSpendBalance, BalancePublicKey: traderPublicKey, BalanceAmountNanos: 1010},
+				{Type: lib.OperationTypeAddBalance, BalancePublicKey: creatorPublicKey, BalanceAmountNanos: 100},
+				creatorCoinUtxoOp,
+			},
+			expected: 100,
+		},
+		{
+			name: "utxo model",
+			utxoOps: []*lib.UtxoOperation{
+				{Type: lib.OperationTypeSpendUtxo, Entry: &lib.UtxoEntry{PublicKey: traderPublicKey, AmountNanos: 1010}},
+				{Type: lib.OperationTypeAddUtxo, Entry: &lib.UtxoEntry{
+					PublicKey: creatorPublicKey, AmountNanos: 3, UtxoType: lib.UtxoTypeOutput}},
+				{Type: lib.OperationTypeAddUtxo, Entry: &lib.UtxoEntry{
+					PublicKey: creatorPublicKey, AmountNanos: 100, UtxoType: lib.UtxoTypeCreatorCoinFounderReward}},
+				creatorCoinUtxoOp,
+			},
+			expected: 100,
+		},
+		{
+			name:     "no founder reward",
+			utxoOps:  []*lib.UtxoOperation{creatorCoinUtxoOp},
+			expected: 0,
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			creatorCoinTrade, ok := CreatorCoinUtxoOpToPGStruct(&lib.CreatorCoinMetadataa{
+				ProfilePublicKey: creatorPublicKey,
+				OperationType:    lib.CreatorCoinOperationTypeBuy,
+				DeSoToSellNanos:  1000,
+			}, testCase.utxoOps, testCreatorCoinTransaction(traderPublicKey, params), params)
+			require.True(t, ok)
+
+			coinsMinted := lib.CalculateCreatorCoinToMint(890, 1e9, 5000, params)
+			require.Equal(t, CreatorCoinTradeDirectionBuy, creatorCoinTrade.Direction)
+			require.Equal(t, consumer.PublicKeyBytesToBase58Check(creatorPublicKey, params), creatorCoinTrade.CreatorPublicKey)
+			require.Equal(t, uint64(1000), creatorCoinTrade.DesoAmountNanos)
+			require.Equal(t, testCase.expected, creatorCoinTrade.FounderRewardNanos)
+			require.Equal(t, 1000-890-testCase.expected, creatorCoinTrade.TradingFeeNanos)
+			require.Equal(t, uint64(5000), creatorCoinTrade.DesoLockedNanosBefore)
+			require.Equal(t, uint64(5890), creatorCoinTrade.DesoLockedNanosAfter)
+			require.Equal(t, coinsMinted, creatorCoinTrade.CreatorCoinAmountNanos)
+			require.Equal(t, 1e9+coinsMinted, creatorCoinTrade.CoinsInCirculationNanos)
+			require.Less(t, creatorCoinTrade.PriceBeforeNanos, creatorCoinTrade.PriceAfterNanos)
+		})
+	}
+}
+
+func TestCreatorCoinUtxoOpToPGStructSell(t *testing.T) {
+	params := &lib.DeSoMainnetParams
+	traderPublicKey := testPublicKeyBytes(1)
+	creatorPublicKey := testPublicKeyBytes(2)
+
+	for _, testCase := range []struct {
+		name                   string
+		prevBalanceNanos       uint64
+		expectedCoinsSoldNanos uint64
+	}{
+		{name: "partial sell", prevBalanceNanos: 5e8, expectedCoinsSoldNanos: 1e8},
+		{
+			name:                   "auto sell",
+			prevBalanceNanos:       1e8 + params.CreatorCoinAutoSellThresholdNanos - 1,
+			expectedCoinsSoldNanos: 1e8 + params.CreatorCoinAutoSellThresholdNanos - 1,
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			utxoOps := []*lib.UtxoOperation{
+				{Type: lib.OperationTypeSpendBalance, BalancePublicKey: traderPublicKey, BalanceAmountNanos: 5},
+				{
+					Type: lib.OperationTypeCreatorCoin,
+					PrevCoinEntry: &lib.CoinEntry{
+						DeSoLockedNanos:         5000,
+						CoinsInCirculationNanos: *uint256.NewInt(1e9),
+					},
+					PrevTransactorBalanceEntry:     &lib.BalanceEntry{BalanceNanos: *uint256.NewInt(testCase.prevBalanceNanos)},
+					CreatorCoinDESOLockedNanosDiff: -1000,
+				},
+				{Type: lib.OperationTypeAddBalance, BalancePublicKey: traderPublicKey, BalanceAmountNanos: 990},
+			}
+			creatorCoinTrade, ok := CreatorCoinUtxoOpToPGStruct(&lib.CreatorCoinMetadataa{
+				ProfilePublicKey:       creatorPublicKey,
+				OperationType:          lib.CreatorCoinOperationTypeSell,
+				CreatorCoinToSellNanos: 1e8,
+			}, utxoOps, testCreatorCoinTransaction(traderPublicKey, params), params)
+			require.True(t, ok)
+
+			require.Equal(t, CreatorCoinTradeDirectionSell, creatorCoinTrade.Direction)
+			require.Equal(t, uint64(990), creatorCoinTrade.DesoAmountNanos)
+			require.Equal(t, uint64(10), creatorCoinTrade.TradingFeeNanos)
+			require.Equal(t, uint64(0), creatorCoinTrade.FounderRewardNanos)
+			require.Equal(t, uint64(4000), creatorCoinTrade.DesoLockedNanosAfter)
+			require.Equal(t, testCase.expectedCoinsSoldNanos, creatorCoinTrade.CreatorCoinAmountNanos)
+			require.Equal(t, 1e9-testCase.expectedCoinsSoldNanos, creatorCoinTrade.CoinsInCirculationNanos)
+		})
+	}
+}
+
+func TestCreatorCoinUtxoOpToPGStructNotATrade(t *testing.T) {
+	params := &lib.DeSoMainnetParams
+	traderPublicKey := testPublicKeyBytes(1)
+	transaction := testCreatorCoinTransaction(traderPublicKey, params)
+	creatorCoinUtxoOp := &lib.UtxoOperation{
+		Type:          lib.OperationTypeCreatorCoin,
+		PrevCoinEntry: &lib.CoinEntry{CoinsInCirculationNanos: *uint256.NewInt(0)},
+	}
+
+	_, ok := CreatorCoinUtxoOpToPGStruct(&lib.CreatorCoinMetadataa{
+		ProfilePublicKey: testPublicKeyBytes(2),
+		OperationType:    lib.CreatorCoinOperationTypeAddDeSo,
+	}, []*lib.UtxoOperation{creatorCoinUtxoOp}, transaction, params)
+	require.False(t, ok)
+
+	_, ok = CreatorCoinUtxoOpToPGStruct(&lib.CreatorCoinMetadataa{
+		ProfilePublicKey: testPublicKeyBytes(2),
+		OperationType:    lib.CreatorCoinOperationTypeBuy,
+		DeSoToSellNanos:  1000,
+	}, []*lib.UtxoOperation{{Type: lib.OperationTypeSpendBalance}}, transaction, params)
+	require.False(t, ok)
+}
+
+func TestSubtractOrZero(t *testing.T) {
+	require.Equal(t, uint64(2), subtractOrZero(5, 3))
+	require.Equal(t, uint64(0), subtractOrZero(3, 3))
+	require.Equal(t, uint64(0), subtractOrZero(3, 5))
+}
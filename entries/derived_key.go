@@ -97,6 +97,11 @@ func bulkInsertDerivedKeyEntry(entries []*lib.StateChangeEntry, db bun.IDB, oper
 	if _, err := query.Returning("").Exec(context.Background()); err != nil {
 		return errors.Wrapf(err, "entries.bulkInsertDerivedKeyEntry: Error inserting entries")
 	}
+
+	// Keep the spending limit tables in sync with each derived key's current spending limits.
+	if err := replaceDerivedKeySpendingLimits(pgEntrySlice, db, operationType == lib.DbOperationTypeUpsert); err != nil {
+		return errors.Wrapf(err, "entries.bulkInsertDerivedKeyEntry: Error replacing spending limits")
+	}
 	return nil
 }
 
@@ -108,6 +113,11 @@ func bulkDeleteDerivedKeyEntry(entries []*lib.StateChangeEntry, db bun.IDB, oper
 	// Transform the entries into a list of keys to delete.
 	keysToDelete := consumer.KeysToDelete(uniqueEntries)
 
+	// Delete the spending limits of the derived keys, which are looked up from the entries being deleted.
+	if err := deleteDerivedKeySpendingLimits(keysToDelete, db); err != nil {
+		return errors.Wrapf(err, "entries.bulkDeleteDerivedKeyEntry: Error deleting spending limits")
+	}
+
 	// Execute the delete query.
 	if _, err := db.NewDelete().
 		Model(&PGDerivedKeyEntry{}).
@@ -0,0 +1,418 @@
+package entries
+
+import (
+	"context"
+
+	"github.com/deso-protocol/backend/routes"
+	"github.com/pkg/errors"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/extra/bunbig"
+)
+
+const (
+	DerivedKeyStakeLimitOperationStake       = "stake"
+	DerivedKeyStakeLimitOperationUnstake     = "unstake"
+	DerivedKeyStakeLimitOperationUnlockStake = "unlock_stake"
+)
+
+// The tables below expand a derived key's transaction spending limits into one row per limit, so that derived keys
+// can be filtered by what they're allowed to do. Each row is identified by the derived key's owner and derived public
+// keys. An empty public key, post hash or key name matches any, as in the spending limit itself.
+
+// DerivedKeyTxnCountLimit is the number of transactions of a given type a derived key can submit.
+type DerivedKeyTxnCountLimit struct {
+	OwnerPublicKey   string `pg:",pk,use_zero"`
+	DerivedPublicKey string `pg:",pk,use_zero"`
+	TxnType          string `pg:",pk,use_zero"`
+	OpCount          uint64 `pg:",use_zero"`
+}
+
+type PGDerivedKeyTxnCountLimit struct {
+	bun.BaseModel `bun:"table:derived_key_txn_count_limit"`
+	DerivedKeyTxnCountLimit
+}
+
+// DerivedKeyCreatorCoinLimit is the number of creator coin operations a derived key can perform on a creator's coin.
+type DerivedKeyCreatorCoinLimit struct {
+	OwnerPublicKey   string `pg:",pk,use_zero"`
+	DerivedPublicKey string `pg:",pk,use_zero"`
+	CreatorPublicKey string `pg:",pk,use_zero"`
+	Operation        string `pg:",pk,use_zero"`
+	OpCount          uint64 `pg:",use_zero"`
+}
+
+type PGDerivedKeyCreatorCoinLimit struct {
+	bun.BaseModel `bun:"table:derived_key_creator_coin_limit"`
+	DerivedKeyCreatorCoinLimit
+}
+
+// DerivedKeyDaoCoinLimit is the number of DAO coin operations a derived key can perform on a creator's DAO coin.
+type DerivedKeyDaoCoinLimit struct {
+	OwnerPublicKey   string `pg:",pk,use_zero"`
+	DerivedPublicKey string `pg:",pk,use_zero"`
+	CreatorPublicKey string `pg:",pk,use_zero"`
+	Operation        string `pg:",pk,use_zero"`
+	OpCount          uint64 `pg:",use_zero"`
+}
+
+type PGDerivedKeyDaoCoinLimit struct {
+	bun.BaseModel `bun:"table:derived_key_dao_coin_limit"`
+	DerivedKeyDaoCoinLimit
+}
+
+// DerivedKeyNftLimit is the number of NFT operations a derived key can perform on an NFT serial number.
+type DerivedKeyNftLimit struct {
+	OwnerPublicKey   string `pg:",pk,use_zero"`
+	DerivedPublicKey string `pg:",pk,use_zero"`
+	NftPostHash      string `pg:",pk,use_zero"`
+	SerialNumber     uint64 `pg:",pk,use_zero"`
+	Operation        string `pg:",pk,use_zero"`
+	OpCount          uint64 `pg:",use_zero"`
+}
+
+type PGDerivedKeyNftLimit struct {
+	bun.BaseModel `bun:"table:derived_key_nft_limit"`
+	DerivedKeyNftLimit
+}
+
+// DerivedKeyDaoCoinLimitOrderLimit is the number of DAO coin limit orders a derived key can place for a coin pair.
+type DerivedKeyDaoCoinLimitOrderLimit struct {
+	OwnerPublicKey                 string `pg:",pk,use_zero"`
+	DerivedPublicKey               string `pg:",pk,use_zero"`
+	BuyingDaoCoinCreatorPublicKey  string `pg:",pk,use_zero"`
+	SellingDaoCoinCreatorPublicKey string `pg:",pk,use_zero"`
+	OpCount                        uint64 `pg:",use_zero"`
+}
+
+type PGDerivedKeyDaoCoinLimitOrderLimit struct {
+	bun.BaseModel `bun:"table:derived_key_dao_coin_limit_order_limit"`
+	DerivedKeyDaoCoinLimitOrderLimit
+}
+
+// DerivedKeyAssociationLimit is the number of association operations a derived key can perform.
+type DerivedKeyAssociationLimit struct {
+	OwnerPublicKey   string `pg:",pk,use_zero"`
+	DerivedPublicKey string `pg:",pk,use_zero"`
+	AssociationClass string `pg:",pk,use_zero"`
+	AssociationType  string `pg:",pk,use_zero"`
+	AppScopeType     string `pg:",pk,use_zero"`
+	AppPublicKey     string `pg:",pk,use_zero"`
+	Operation        string `pg:",pk,use_zero"`
+	OpCount          uint64 `pg:",use_zero"`
+}
+
+type PGDerivedKeyAssociationLimit struct {
+	bun.BaseModel `bun:"table:derived_key_association_limit"`
+	DerivedKeyAssociationLimit
+}
+
+// DerivedKeyAccessGroupLimit is the number of access group or access group member operations a derived key can
+// perform on an access group.
+type DerivedKeyAccessGroupLimit struct {
+	OwnerPublicKey            string `pg:",pk,use_zero"`
+	DerivedPublicKey          string `pg:",pk,use_zero"`
+	AccessGroupOwnerPublicKey string `pg:",pk,use_zero"`
+	AccessGroupKeyName        string `pg:",pk,use_zero"`
+	ScopeType                 string `pg:",pk,use_zero"`
+	// Whether the limit is for managing the group's members, rather than the group itself.
+	IsMemberOperation bool   `pg:",pk,use_zero"`
+	Operation         string `pg:",pk,use_zero"`
+	OpCount           uint64 `pg:",use_zero"`
+}
+
+type PGDerivedKeyAccessGroupLimit struct {
+	bun.BaseModel `bun:"table:derived_key_access_group_limit"`
+	DerivedKeyAccessGroupLimit
+}
+
+// DerivedKeyLockupLimit is the number of lockup operations a derived key can perform on a profile's coins.
+type DerivedKeyLockupLimit struct {
+	OwnerPublicKey   string `pg:",pk,use_zero"`
+	DerivedPublicKey string `pg:",pk,use_zero"`
+	ProfilePublicKey string `pg:",pk,use_zero"`
+	ScopeType        string `pg:",pk,use_zero"`
+	Operation        string `pg:",pk,use_zero"`
+	OpCount          uint64 `pg:",use_zero"`
+}
+
+type PGDerivedKeyLockupLimit struct {
+	bun.BaseModel `bun:"table:derived_key_lockup_limit"`
+	DerivedKeyLockupLimit
+}
+
+// DerivedKeyStakeLimit is the amount a derived key can stake or unstake with a validator, or the number of times it
+// can unlock stake from them.
+type DerivedKeyStakeLimit struct {
+	OwnerPublicKey     string      `pg:",pk,use_zero"`
+	DerivedPublicKey   string      `pg:",pk,use_zero"`
+	ValidatorPublicKey string      `pg:",pk,use_zero"`
+	Operation          string      `pg:",pk,use_zero"`
+	AmountNanos        *bunbig.Int `bun:",nullzero"`
+	OpCount            uint64      `pg:",use_zero"`
+}
+
+type PGDerivedKeyStakeLimit struct {
+	bun.BaseModel `bun:"table:derived_key_stake_limit"`
+	DerivedKeyStakeLimit
+}
+
+// derivedKeySpendingLimitModels are the models of every spending limit table, used to clear a derived key's limits.
+var derivedKeySpendingLimitModels = []interface{}{
+	&PGDerivedKeyTxnCountLimit{},
+	&PGDerivedKeyCreatorCoinLimit{},
+	&PGDerivedKeyDaoCoinLimit{},
+	&PGDerivedKeyNftLimit{},
+	&PGDerivedKeyDaoCoinLimitOrderLimit{},
+	&PGDerivedKeyAssociationLimit{},
+	&PGDerivedKeyAccessGroupLimit{},
+	&PGDerivedKeyLockupLimit{},
+	&PGDerivedKeyStakeLimit{},
+}
+
+// derivedKeySpendingLimits holds the spending limit rows of one or more derived keys, grouped by table.
+type derivedKeySpendingLimits struct {
+	txnCountLimits          []*PGDerivedKeyTxnCountLimit
+	creatorCoinLimits       []*PGDerivedKeyCreatorCoinLimit
+	daoCoinLimits           []*PGDerivedKeyDaoCoinLimit
+	nftLimits               []*PGDerivedKeyNftLimit
+	daoCoinLimitOrderLimits []*PGDerivedKeyDaoCoinLimitOrderLimit
+	associationLimits       []*PGDerivedKeyAssociationLimit
+	accessGroupLimits       []*PGDerivedKeyAccessGroupLimit
+	lockupLimits            []*PGDerivedKeyLockupLimit
+	stakeLimits             []*PGDerivedKeyStakeLimit
+}
+
+// add expands a derived key's transaction spending limits into rows and adds them to limits.
+func (limits *derivedKeySpendingLimits) add(derivedKeyEntry *DerivedKeyEntry) {
+	owner := derivedKeyEntry.OwnerPublicKey
+	derived := derivedKeyEntry.DerivedPublicKey
+	spendingLimits := &derivedKeyEntry.TransactionSpendingLimits
+
+	for txnType, opCount := range spendingLimits.TransactionCountLimitMap {
+		limits.txnCountLimits = append(limits.txnCountLimits, &PGDerivedKeyTxnCountLimit{
+			DerivedKeyTxnCountLimit: DerivedKeyTxnCountLimit{
+				OwnerPublicKey:   owner,
+				DerivedPublicKey: derived,
+				TxnType:          string(txnType),
+				OpCount:          opCount,
+			},
+		})
+	}
+
+	for creatorPublicKey, operations := range spendingLimits.CreatorCoinOperationLimitMap {
+		for operation, opCount := range operations {
+			limits.creatorCoinLimits = append(limits.creatorCoinLimits, &PGDerivedKeyCreatorCoinLimit{
+				DerivedKeyCreatorCoinLimit: DerivedKeyCreatorCoinLimit{
+					OwnerPublicKey:   owner,
+					DerivedPublicKey: derived,
+					CreatorPublicKey: creatorPublicKey,
+					Operation:        string(operation),
+					OpCount:          opCount,
+				},
+			})
+		}
+	}
+
+	for creatorPublicKey, operations := range spendingLimits.DAOCoinOperationLimitMap {
+		for operation, opCount := range operations {
+			limits.daoCoinLimits = append(limits.daoCoinLimits, &PGDerivedKeyDaoCoinLimit{
+				DerivedKeyDaoCoinLimit: DerivedKeyDaoCoinLimit{
+					OwnerPublicKey:   owner,
+					DerivedPublicKey: derived,
+					CreatorPublicKey: creatorPublicKey,
+					Operation:        string(operation),
+					OpCount:          opCount,
+				},
+			})
+		}
+	}
+
+	for nftPostHash, serialNumbers := range spendingLimits.NFTOperationLimitMap {
+		for serialNumber, operations := range serialNumbers {
+			for operation, opCount := range operations {
+				limits.nftLimits = append(limits.nftLimits, &PGDerivedKeyNftLimit{
+					DerivedKeyNftLimit: DerivedKeyNftLimit{
+						OwnerPublicKey:   owner,
+						DerivedPublicKey: derived,
+						NftPostHash:      nftPostHash,
+						SerialNumber:     serialNumber,
+						Operation:        string(operation),
+						OpCount:          opCount,
+					},
+				})
+			}
+		}
+	}
+
+	for buyingPublicKey, sellingPublicKeys := range spendingLimits.DAOCoinLimitOrderLimitMap {
+		for sellingPublicKey, opCount := range sellingPublicKeys {
+			limits.daoCoinLimitOrderLimits = append(limits.daoCoinLimitOrderLimits, &PGDerivedKeyDaoCoinLimitOrderLimit{
+				DerivedKeyDaoCoinLimitOrderLimit: DerivedKeyDaoCoinLimitOrderLimit{
+					OwnerPublicKey:                 owner,
+					DerivedPublicKey:               derived,
+					BuyingDaoCoinCreatorPublicKey:  buyingPublicKey,
+					SellingDaoCoinCreatorPublicKey: sellingPublicKey,
+					OpCount:                        opCount,
+				},
+			})
+		}
+	}
+
+	for _, associationLimit := range spendingLimits.AssociationLimitMap {
+		limits.associationLimits = append(limits.associationLimits, &PGDerivedKeyAssociationLimit{
+			DerivedKeyAssociationLimit: DerivedKeyAssociationLimit{
+				OwnerPublicKey:   owner,
+				DerivedPublicKey: derived,
+				AssociationClass: string(associationLimit.AssociationClass),
+				AssociationType:  associationLimit.AssociationType,
+				AppScopeType:     string(associationLimit.AppScopeType),
+				AppPublicKey:     associationLimit.AppPublicKeyBase58Check,
+				Operation:        string(associationLimit.AssociationOperation),
+				OpCount:          associationLimit.OpCount,
+			},
+		})
+	}
+
+	for _, accessGroupLimit := range spendingLimits.AccessGroupLimitMap {
+		limits.accessGroupLimits = append(limits.accessGroupLimits, &PGDerivedKeyAccessGroupLimit{
+			DerivedKeyAccessGroupLimit: DerivedKeyAccessGroupLimit{
+				OwnerPublicKey:            owner,
+				DerivedPublicKey:          derived,
+				AccessGroupOwnerPublicKey: accessGroupLimit.AccessGroupOwnerPublicKeyBase58Check,
+				AccessGroupKeyName:        accessGroupLimit.AccessGroupKeyName,
+				ScopeType:                 string(accessGroupLimit.ScopeType),
+				IsMemberOperation:         false,
+				Operation:                 string(accessGroupLimit.OperationType),
+				OpCount:                   accessGroupLimit.OpCount,
+			},
+		})
+	}
+
+	for _, accessGroupMemberLimit := range spendingLimits.AccessGroupMemberLimitMap {
+		limits.accessGroupLimits = append(limits.accessGroupLimits, &PGDerivedKeyAccessGroupLimit{
+			DerivedKeyAccessGroupLimit: DerivedKeyAccessGroupLimit{
+				OwnerPublicKey:            owner,
+				DerivedPublicKey:          derived,
+				AccessGroupOwnerPublicKey: accessGroupMemberLimit.AccessGroupOwnerPublicKeyBase58Check,
+				AccessGroupKeyName:        accessGroupMemberLimit.AccessGroupKeyName,
+				ScopeType:                 string(accessGroupMemberLimit.ScopeType),
+				IsMemberOperation:         true,
+				Operation:                 string(accessGroupMemberLimit.OperationType),
+				OpCount:                   accessGroupMemberLimit.OpCount,
+			},
+		})
+	}
+
+	for _, lockupLimit := range spendingLimits.LockupLimitMap {
+		limits.lockupLimits = append(limits.lockupLimits, &PGDerivedKeyLockupLimit{
+			DerivedKeyLockupLimit: DerivedKeyLockupLimit{
+				OwnerPublicKey:   owner,
+				DerivedPublicKey: derived,
+				ProfilePublicKey: lockupLimit.ProfilePublicKeyBase58Check,
+				ScopeType:        string(lockupLimit.ScopeType),
+				Operation:        string(lockupLimit.Operation),
+				OpCount:          lockupLimit.OpCount,
+			},
+		})
+	}
+
+	stakeLimitMaps := []struct {
+		operation   string
+		stakeLimits []routes.StakeLimitMapItem
+	}{
+		{DerivedKeyStakeLimitOperationStake, spendingLimits.StakeLimitMap},
+		{DerivedKeyStakeLimitOperationUnstake, spendingLimits.UnstakeLimitMap},
+		{DerivedKeyStakeLimitOperationUnlockStake, spendingLimits.UnlockStakeLimitMap},
+	}
+	for _, stakeLimitMap := range stakeLimitMaps {
+		for _, stakeLimit := range stakeLimitMap.stakeLimits {
+			pgStakeLimit := &PGDerivedKeyStakeLimit{
+				DerivedKeyStakeLimit: DerivedKeyStakeLimit{
+					OwnerPublicKey:     owner,
+					DerivedPublicKey:   derived,
+					ValidatorPublicKey: stakeLimit.ValidatorPublicKeyBase58Check,
+					Operation:          stakeLimitMap.operation,
+					OpCount:            stakeLimit.OpCount,
+				},
+			}
+			if stakeLimit.StakeLimit != nil {
+				pgStakeLimit.AmountNanos = bunbig.FromMathBig(stakeLimit.StakeLimit.ToBig())
+			}
+			limits.stakeLimits = append(limits.stakeLimits, pgStakeLimit)
+		}
+	}
+}
+
+// replaceDerivedKeySpendingLimits replaces the spending limit rows of a batch of derived keys with their current
+// spending limits. Existing rows only need to be cleared when derived keys are being updated rather than synced
+// for the first time.
+func replaceDerivedKeySpendingLimits(pgEntrySlice []*PGDerivedKeyEntry, db bun.IDB, deleteExisting bool) error {
+	if len(pgEntrySlice) == 0 {
+		return nil
+	}
+	if deleteExisting {
+		derivedKeys := make([][]string, len(pgEntrySlice))
+		for ii, pgEntry := range pgEntrySlice {
+			derivedKeys[ii] = []string{pgEntry.OwnerPublicKey, pgEntry.DerivedPublicKey}
+		}
+		for _, model := range derivedKeySpendingLimitModels {
+			if _, err := db.NewDelete().
+				Model(model).
+				Where("(owner_public_key, derived_public_key) IN (?)", bun.In(derivedKeys)).
+				Returning("").
+				Exec(context.Background()); err != nil {
+				return errors.Wrapf(err, "entries.replaceDerivedKeySpendingLimits: Error deleting spending limits")
+			}
+		}
+	}
+
+	limits := &derivedKeySpendingLimits{}
+	for _, pgEntry := range pgEntrySlice {
+		limits.add(&pgEntry.DerivedKeyEntry)
+	}
+	limitSlices := []struct {
+		model interface{}
+		count int
+	}{
+		{&limits.txnCountLimits, len(limits.txnCountLimits)},
+		{&limits.creatorCoinLimits, len(limits.creatorCoinLimits)},
+		{&limits.daoCoinLimits, len(limits.daoCoinLimits)},
+		{&limits.nftLimits, len(limits.nftLimits)},
+		{&limits.daoCoinLimitOrderLimits, len(limits.daoCoinLimitOrderLimits)},
+		{&limits.associationLimits, len(limits.associationLimits)},
+		{&limits.accessGroupLimits, len(limits.accessGroupLimits)},
+		{&limits.lockupLimits, len(limits.lockupLimits)},
+		{&limits.stakeLimits, len(limits.stakeLimits)},
+	}
+	for _, limitSlice := range limitSlices {
+		if limitSlice.count == 0 {
+			continue
+		}
+		if _, err := db.NewInsert().
+			Model(limitSlice.model).
+			On("CONFLICT DO NOTHING").
+			Returning("").
+			Exec(context.Background()); err != nil {
+			return errors.Wrapf(err, "entries.replaceDerivedKeySpendingLimits: Error inserting spending limits")
+		}
+	}
+	return nil
+}
+
+// deleteDerivedKeySpendingLimits deletes the spending limit rows of the derived keys with the given badger keys.
+// It must be called before the derived keys themselves are deleted.
+func deleteDerivedKeySpendingLimits(keysToDelete [][]byte, db bun.IDB) error {
+	for _, model := range derivedKeySpendingLimitModels {
+		if _, err := db.NewDelete().
+			Model(model).
+			Where("(owner_public_key, derived_public_key) IN (?)", db.NewSelect().
+				Model((*PGDerivedKeyEntry)(nil)).
+				Column("owner_public_key", "derived_public_key").
+				Where("badger_key IN (?)", bun.In(keysToDelete))).
+			Returning("").
+			Exec(context.Background()); err != nil {
+			return errors.Wrapf(err, "entries.deleteDerivedKeySpendingLimits: Error deleting spending limits")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package entries
+
+import (
+	"testing"
+
+	"github.com/deso-protocol/backend/routes"
+	"github.com/deso-protocol/core/lib"
+	"github.com/deso-protocol/uint256"
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun/extra/bunbig"
+)
+
+func TestDerivedKeySpendingLimitsAdd(t *testing.T) {
+	limits := &derivedKeySpendingLimits{}
+	limits.add(&DerivedKeyEntry{
+		OwnerPublicKey:   "owner",
+		DerivedPublicKey: "derived",
+		TransactionSpendingLimits: routes.TransactionSpendingLimitResponse{
+			TransactionCountLimitMap: map[lib.TxnString]uint64{lib.TxnStringSubmitPost: 5},
+			CreatorCoinOperationLimitMap: map[string]map[lib.CreatorCoinLimitOperationString]uint64{
+				"": {lib.AnyCreatorCoinOperation: 1},
+				"creator": {
+					lib.BuyCreatorCoinOperation:  2,
+					lib.SellCreatorCoinOperation: 3,
+				},
+			},
+			StakeLimitMap: []routes.StakeLimitMapItem{
+				{ValidatorPublicKeyBase58Check: "validator", StakeLimit: uint256.NewInt(1000)},
+			},
+			UnlockStakeLimitMap: []routes.StakeLimitMapItem{
+				{ValidatorPublicKeyBase58Check: "", OpCount: 4},
+			},
+		},
+	})
+
+	require.Equal(t, []*PGDerivedKeyTxnCountLimit{{DerivedKeyTxnCountLimit: DerivedKeyTxnCountLimit{
+		OwnerPublicKey:   "owner",
+		DerivedPublicKey: "derived",
+		TxnType:          string(lib.TxnStringSubmitPost),
+		OpCount:          5,
+	}}}, limits.txnCountLimits)
+
+	// An empty creator public key is kept, since it applies the limit to any creator.
+	require.ElementsMatch(t, []*PGDerivedKeyCreatorCoinLimit{
+		{DerivedKeyCreatorCoinLimit: DerivedKeyCreatorCoinLimit{
+			OwnerPublicKey: "owner", DerivedPublicKey: "derived",
+			CreatorPublicKey: "", Operation: string(lib.AnyCreatorCoinOperation), OpCount: 1,
+		}},
+		{DerivedKeyCreatorCoinLimit: DerivedKeyCreatorCoinLimit{
+			OwnerPublicKey: "owner", DerivedPublicKey: "derived",
+			CreatorPublicKey: "creator", Operation: string(lib.BuyCreatorCoinOperation), OpCount: 2,
+		}},
+		{DerivedKeyCreatorCoinLimit: DerivedKeyCreatorCoinLimit{
+			OwnerPublicKey: "owner", DerivedPublicKey: "derived",
+			CreatorPublicKey: "creator", Operation: string(lib.SellCreatorCoinOperation), OpCount: 3,
+		}},
+	}, limits.creatorCoinLimits)
+
+	// Stake and unstake limits are amounts, while unlock stake limits are operation counts.
+	require.Equal(t, []*PGDerivedKeyStakeLimit{
+		{DerivedKeyStakeLimit: DerivedKeyStakeLimit{
+			OwnerPublicKey:     "owner",
+			DerivedPublicKey:   "derived",
+			ValidatorPublicKey: "validator",
+			Operation:          DerivedKeyStakeLimitOperationStake,
+			AmountNanos:        bunbig.FromMathBig(uint256.NewInt(1000).ToBig()),
+		}},
+		{DerivedKeyStakeLimit: DerivedKeyStakeLimit{
+			OwnerPublicKey:   "owner",
+			DerivedPublicKey: "derived",
+			Operation:        DerivedKeyStakeLimitOperationUnlockStake,
+			OpCount:          4,
+		}},
+	}, limits.stakeLimits)
+
+	require.Empty(t, limits.daoCoinLimits)
+	require.Empty(t, limits.nftLimits)
+	require.Empty(t, limits.accessGroupLimits)
+}
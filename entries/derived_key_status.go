@@ -0,0 +1,78 @@
+package entries
+
+import (
+	"context"
+	"time"
+
+	"github.com/deso-protocol/core/lib"
+	"github.com/pkg/errors"
+	"github.com/uptrace/bun"
+)
+
+// DerivedKeyExpirationEvent records a valid derived key expiring, written when the block at the key's expiration
+// height is added. Keys that are re-authorized with a later expiration block expire again when that block arrives.
+type DerivedKeyExpirationEvent struct {
+	OwnerPublicKey   string    `pg:",pk,use_zero"`
+	DerivedPublicKey string    `pg:",pk,use_zero"`
+	ExpirationBlock  uint64    `pg:",pk,use_zero"`
+	BlockHeight      uint64    `pg:",use_zero"`
+	BlockHash        string    `pg:",use_zero"`
+	Timestamp        time.Time `pg:",use_zero"`
+}
+
+type PGDerivedKeyExpirationEvent struct {
+	bun.BaseModel `bun:"table:derived_key_expiration_event"`
+	DerivedKeyExpirationEvent
+}
+
+// derivedKeyExpirationBlock is a block that expires the derived keys whose expiration block is its height.
+type derivedKeyExpirationBlock struct {
+	BlockHash string
+	Height    uint64
+	Timestamp time.Time
+}
+
+// bulkInsertDerivedKeyExpirationEvents records the expiration of every valid derived key that expires at the height
+// of one of the given blocks. It's called from bulkInsertBlockEntry, which returns before reaching it for the
+// initial sync's inserts, as the blocks of the initial sync are stored along with their utxo operations instead.
+// Keys that expired during the initial sync therefore don't have an expiration event.
+func bulkInsertDerivedKeyExpirationEvents(pgBlockEntrySlice []*PGBlockEntry, db bun.IDB) error {
+	// A single insert can't update the same row twice, so only keep the latest block at each height.
+	blockIndex := make(map[uint64]int)
+	var expirationBlocks []*derivedKeyExpirationBlock
+	for _, pgBlockEntry := range pgBlockEntrySlice {
+		expirationBlock := &derivedKeyExpirationBlock{
+			BlockHash: pgBlockEntry.BlockHash,
+			Height:    pgBlockEntry.Height,
+			Timestamp: pgBlockEntry.Timestamp,
+		}
+		if ii, ok := blockIndex[pgBlockEntry.Height]; ok {
+			expirationBlocks[ii] = expirationBlock
+			continue
+		}
+		blockIndex[pgBlockEntry.Height] = len(expirationBlocks)
+		expirationBlocks = append(expirationBlocks, expirationBlock)
+	}
+	if len(expirationBlocks) == 0 {
+		return nil
+	}
+
+	if _, err := db.NewRaw(`
+		WITH _data (block_hash, height, timestamp) AS (?)
+		INSERT INTO derived_key_expiration_event (
+			owner_public_key, derived_public_key, expiration_block, block_height, block_hash, timestamp
+		)
+		SELECT derived_key_entry.owner_public_key, derived_key_entry.derived_public_key, derived_key_entry.expiration_block,
+			_data.height, _data.block_hash, _data.timestamp
+		FROM _data
+		JOIN derived_key_entry ON derived_key_entry.expiration_block = _data.height
+		WHERE derived_key_entry.operation_type = ?
+		ON CONFLICT (owner_public_key, derived_public_key, expiration_block) DO UPDATE SET
+			block_height = EXCLUDED.block_height,
+			block_hash = EXCLUDED.block_hash,
+			timestamp = EXCLUDED.timestamp
+	`, db.NewValues(&expirationBlocks), uint8(lib.AuthorizeDerivedKeyOperationValid)).Exec(context.Background()); err != nil {
+		return errors.Wrapf(err, "entries.bulkInsertDerivedKeyExpirationEvents: Error inserting expiration events")
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package entries
 
 import (
 	"context"
+	"fmt"
 	"github.com/deso-protocol/core/lib"
 	"github.com/deso-protocol/state-consumer/consumer"
 	"github.com/pkg/errors"
@@ -25,6 +26,23 @@ type PGDesoBalanceEntryUtxoOps struct {
 	UtxoOperation
 }
 
+// DesoBalanceHistoryEntry records the DESO balance of a public key as of a given block height.
+// Rows are written by the deso balance batch operation, which sets the balance and delta, and by the utxo
+// operation parser, which attributes the change to the block and transaction that caused it.
+type DesoBalanceHistoryEntry struct {
+	PublicKey    string `pg:",pk,use_zero"`
+	BlockHeight  uint64 `pg:",pk,use_zero"`
+	BlockHash    string `bun:",nullzero"`
+	TxnHash      string `bun:",nullzero"`
+	BalanceNanos *uint64
+	DeltaNanos   int64 `pg:",use_zero"`
+}
+
+type PGDesoBalanceHistoryEntry struct {
+	bun.BaseModel `bun:"table:deso_balance_history"`
+	DesoBalanceHistoryEntry
+}
+
 // Convert the Diamond DeSo encoder to the PG struct used by bun.
 func DesoBalanceEncoderToPGStruct(desoBalanceEntry *lib.DeSoBalanceEntry, keyBytes []byte, params *lib.DeSoParams) DesoBalanceEntry {
 	return DesoBalanceEntry{
@@ -64,6 +82,17 @@ func bulkInsertDesoBalanceEntry(entries []*lib.StateChangeEntry, db bun.IDB, ope
 		pgEntrySlice[ii] = &PGDesoBalanceEntry{DesoBalanceEntry: DesoBalanceEncoderToPGStruct(entry.Encoder.(*lib.DeSoBalanceEntry), entry.KeyBytes, params)}
 	}
 
+	// Look up the current balances before they're overwritten, so that we can record the change in balance.
+	// Balances can't already exist during the initial sync, so we skip the lookup there.
+	prevBalances := make(map[string]uint64)
+	if operationType == lib.DbOperationTypeUpsert {
+		var err error
+		prevBalances, err = getDesoBalancesByBadgerKey(db, consumer.KeysToDelete(uniqueEntries))
+		if err != nil {
+			return errors.Wrapf(err, "entries.bulkInsertDesoBalanceEntry: Error getting previous balances")
+		}
+	}
+
 	query := db.NewInsert().Model(&pgEntrySlice)
 
 	if operationType == lib.DbOperationTypeUpsert {
@@ -73,6 +102,21 @@ func bulkInsertDesoBalanceEntry(entries []*lib.StateChangeEntry, db bun.IDB, ope
 	if _, err := query.Returning("").Exec(context.Background()); err != nil {
 		return errors.Wrapf(err, "entries.bulkInsertDesoBalanceEntry: Error inserting entries")
 	}
+
+	// Append the new balances to the balance history.
+	pgHistorySlice := make([]*PGDesoBalanceHistoryEntry, len(uniqueEntries))
+	for ii, entry := range uniqueEntries {
+		balanceNanos := pgEntrySlice[ii].BalanceNanos
+		pgHistorySlice[ii] = &PGDesoBalanceHistoryEntry{DesoBalanceHistoryEntry: DesoBalanceHistoryEntry{
+			PublicKey:    pgEntrySlice[ii].PublicKey,
+			BlockHeight:  entry.BlockHeight,
+			BalanceNanos: &balanceNanos,
+			DeltaNanos:   int64(balanceNanos) - int64(prevBalances[string(entry.KeyBytes)]),
+		}}
+	}
+	if err := bulkInsertDesoBalanceHistoryEntry(pgHistorySlice, db); err != nil {
+		return errors.Wrapf(err, "entries.bulkInsertDesoBalanceEntry: Error inserting balance history")
+	}
 	return nil
 }
 
@@ -84,6 +128,15 @@ func bulkDeleteDesoBalanceEntry(entries []*lib.StateChangeEntry, db bun.IDB, ope
 	// Transform the entries into a list of keys to delete.
 	keysToDelete := consumer.KeysToDelete(uniqueEntries)
 
+	// Look up the balances being deleted, so that we can record their removal in the balance history.
+	var prevEntries []*PGDesoBalanceEntry
+	if err := db.NewSelect().
+		Model(&prevEntries).
+		Where("badger_key IN (?)", bun.In(keysToDelete)).
+		Scan(context.Background()); err != nil {
+		return errors.Wrapf(err, "entries.bulkDeleteDesoBalanceEntry: Error getting previous balances")
+	}
+
 	// Execute the delete query.
 	if _, err := db.NewDelete().
 		Model(&PGDesoBalanceEntry{}).
@@ -93,5 +146,130 @@ func bulkDeleteDesoBalanceEntry(entries []*lib.StateChangeEntry, db bun.IDB, ope
 		return errors.Wrapf(err, "entries.bulkDeleteDesoBalanceEntry: Error deleting entries")
 	}
 
+	// Record each deleted balance as a zero balance at the height it was deleted.
+	blockHeightsByKey := make(map[string]uint64)
+	for _, entry := range uniqueEntries {
+		blockHeightsByKey[string(entry.KeyBytes)] = entry.BlockHeight
+	}
+	pgHistorySlice := make([]*PGDesoBalanceHistoryEntry, len(prevEntries))
+	for ii, prevEntry := range prevEntries {
+		balanceNanos := uint64(0)
+		pgHistorySlice[ii] = &PGDesoBalanceHistoryEntry{DesoBalanceHistoryEntry: DesoBalanceHistoryEntry{
+			PublicKey:    prevEntry.PublicKey,
+			BlockHeight:  blockHeightsByKey[string(prevEntry.BadgerKey)],
+			BalanceNanos: &balanceNanos,
+			DeltaNanos:   -int64(prevEntry.BalanceNanos),
+		}}
+	}
+	if err := bulkInsertDesoBalanceHistoryEntry(pgHistorySlice, db); err != nil {
+		return errors.Wrapf(err, "entries.bulkDeleteDesoBalanceEntry: Error inserting balance history")
+	}
+
+	return nil
+}
+
+// getDesoBalancesByBadgerKey returns a map of badger key to the balance currently stored for that key.
+func getDesoBalancesByBadgerKey(db bun.IDB, keys [][]byte) (map[string]uint64, error) {
+	balances := make(map[string]uint64)
+	if len(keys) == 0 {
+		return balances, nil
+	}
+	var pgEntries []*PGDesoBalanceEntry
+	if err := db.NewSelect().
+		Model(&pgEntries).
+		Column("badger_key", "balance_nanos").
+		Where("badger_key IN (?)", bun.In(keys)).
+		Scan(context.Background()); err != nil {
+		return nil, errors.Wrapf(err, "entries.getDesoBalancesByBadgerKey: Error getting balances")
+	}
+	for _, pgEntry := range pgEntries {
+		balances[string(pgEntry.BadgerKey)] = pgEntry.BalanceNanos
+	}
+	return balances, nil
+}
+
+// bulkInsertDesoBalanceHistoryEntry appends balances to the deso balance history. If a public key's balance changes
+// more than once at the same block height, the latest balance is kept, and the delta is adjusted by the change from
+// the balance already recorded, so that it stays the change from the balance before the height. State changes that
+// revert a disconnected block can rewrite an earlier height this way without leaving a stale delta behind.
+func bulkInsertDesoBalanceHistoryEntry(pgHistorySlice []*PGDesoBalanceHistoryEntry, db bun.IDB) error {
+	if len(pgHistorySlice) == 0 {
+		return nil
+	}
+	if _, err := db.NewInsert().
+		Model(&pgHistorySlice).
+		On("CONFLICT (public_key, block_height) DO UPDATE").
+		Set("balance_nanos = EXCLUDED.balance_nanos").
+		Set("delta_nanos = ?TableAlias.delta_nanos + EXCLUDED.balance_nanos - " +
+			"COALESCE(?TableAlias.balance_nanos, EXCLUDED.balance_nanos - EXCLUDED.delta_nanos)").
+		Returning("").
+		Exec(context.Background()); err != nil {
+		return errors.Wrapf(err, "entries.bulkInsertDesoBalanceHistoryEntry: Error inserting entries")
+	}
+	return nil
+}
+
+// DesoBalanceHistoryAttributionsFromUtxoOps returns a deso balance history row for each public key whose balance
+// was changed by a transaction's utxo operations, linking that balance change to the transaction and its block.
+func DesoBalanceHistoryAttributionsFromUtxoOps(
+	utxoOps []*lib.UtxoOperation,
+	transaction *PGTransactionEntry,
+	params *lib.DeSoParams,
+) []*PGDesoBalanceHistoryEntry {
+	var pgHistorySlice []*PGDesoBalanceHistoryEntry
+	publicKeysSeen := make(map[string]bool)
+	for _, utxoOp := range utxoOps {
+		var publicKeyBytes []byte
+		switch utxoOp.Type {
+		case lib.OperationTypeAddBalance, lib.OperationTypeSpendBalance:
+			publicKeyBytes = utxoOp.BalancePublicKey
+		case lib.OperationTypeAddUtxo, lib.OperationTypeSpendUtxo:
+			if utxoOp.Entry != nil {
+				publicKeyBytes = utxoOp.Entry.PublicKey
+			}
+		}
+		if len(publicKeyBytes) == 0 {
+			continue
+		}
+		publicKey := consumer.PublicKeyBytesToBase58Check(publicKeyBytes, params)
+		if publicKeysSeen[publicKey] {
+			continue
+		}
+		publicKeysSeen[publicKey] = true
+		pgHistorySlice = append(pgHistorySlice, &PGDesoBalanceHistoryEntry{
+			DesoBalanceHistoryEntry: DesoBalanceHistoryEntry{
+				PublicKey:   publicKey,
+				BlockHeight: transaction.BlockHeight,
+				BlockHash:   transaction.BlockHash,
+				TxnHash:     transaction.TransactionHash,
+			},
+		})
+	}
+	return pgHistorySlice
+}
+
+// bulkInsertDesoBalanceHistoryAttributions links deso balance history rows to the block and transaction that caused
+// them. When several transactions in a block change the same balance, the row is attributed to the last of them.
+func bulkInsertDesoBalanceHistoryAttributions(pgHistorySlice []*PGDesoBalanceHistoryEntry, db bun.IDB) error {
+	if len(pgHistorySlice) == 0 {
+		return nil
+	}
+	dedupedHistorySlice := latestAttributions(pgHistorySlice, func(pgHistoryEntry *PGDesoBalanceHistoryEntry) string {
+		return fmt.Sprintf("%v:%v", pgHistoryEntry.PublicKey, pgHistoryEntry.BlockHeight)
+	}, nil)
+
+	// Only rows already written by the deso balance batch operation are attributed, so an attribution never leaves a
+	// row without a balance behind.
+	if _, err := db.NewUpdate().
+		With("_data", db.NewValues(&dedupedHistorySlice)).
+		Model((*PGDesoBalanceHistoryEntry)(nil)).
+		TableExpr("_data").
+		Set("block_hash = _data.block_hash").
+		Set("txn_hash = _data.txn_hash").
+		Where("pg_deso_balance_history_entry.public_key = _data.public_key").
+		Where("pg_deso_balance_history_entry.block_height = _data.block_height").
+		Exec(context.Background()); err != nil {
+		return errors.Wrapf(err, "entries.bulkInsertDesoBalanceHistoryAttributions: Error inserting entries")
+	}
 	return nil
 }
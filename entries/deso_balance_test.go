@@ -0,0 +1,41 @@
+package entries
+
+import (
+	"testing"
+
+	"github.com/deso-protocol/core/lib"
+	"github.com/deso-protocol/state-consumer/consumer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDesoBalanceHistoryAttributionsFromUtxoOps(t *testing.T) {
+	params := &lib.DeSoMainnetParams
+	senderPublicKey := testPublicKeyBytes(1)
+	recipientPublicKey := testPublicKeyBytes(2)
+	transaction := &PGTransactionEntry{TransactionEntry: TransactionEntry{
+		TransactionHash: "txn",
+		BlockHash:       "block",
+		BlockHeight:     100,
+	}}
+
+	// Each public key whose balance changed is attributed once, whether its balance or its utxos changed.
+	pgHistorySlice := DesoBalanceHistoryAttributionsFromUtxoOps([]*lib.UtxoOperation{
+		{Type: lib.OperationTypeSpendBalance, BalancePublicKey: senderPublicKey},
+		{Type: lib.OperationTypeAddBalance, BalancePublicKey: recipientPublicKey},
+		{Type: lib.OperationTypeAddUtxo, Entry: &lib.UtxoEntry{PublicKey: senderPublicKey}},
+		{Type: lib.OperationTypeSpendUtxo},
+		{Type: lib.OperationTypeFollow},
+	}, transaction, params)
+	require.Len(t, pgHistorySlice, 2)
+	for ii, publicKey := range [][]byte{senderPublicKey, recipientPublicKey} {
+		require.Equal(t, DesoBalanceHistoryEntry{
+			PublicKey:   consumer.PublicKeyBytesToBase58Check(publicKey, params),
+			BlockHeight: 100,
+			BlockHash:   "block",
+			TxnHash:     "txn",
+		}, pgHistorySlice[ii].DesoBalanceHistoryEntry)
+	}
+
+	require.Nil(t, DesoBalanceHistoryAttributionsFromUtxoOps(
+		[]*lib.UtxoOperation{{Type: lib.OperationTypeFollow}}, transaction, params))
+}
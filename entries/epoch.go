@@ -94,5 +94,69 @@ func bulkInsertEpochEntry(entries []*lib.StateChangeEntry, db bun.IDB, operation
 	if _, err := query.Returning("").Exec(context.Background()); err != nil {
 		return errors.Wrapf(err, "entries.bulkInsertEpochEntry: Error inserting entries")
 	}
+
+	// Blocks stored before their epoch can now be resolved.
+	snapshotAtEpochNumbers := make([]uint64, len(pgEntrySlice))
+	for ii, pgEntry := range pgEntrySlice {
+		snapshotAtEpochNumbers[ii] = pgEntry.SnapshotAtEpochNumber
+	}
+	if err := resolveUnresolvedBlockValidators(db, snapshotAtEpochNumbers); err != nil {
+		return errors.Wrapf(err, "entries.bulkInsertEpochEntry: Error resolving block validators")
+	}
+
+	// A new epoch completes the one before it, so the previous epoch can be summarized and measured.
+	var completedEpochNumbers []uint64
+	for _, pgEntry := range pgEntrySlice {
+		if pgEntry.EpochNumber > 0 {
+			completedEpochNumbers = append(completedEpochNumbers, pgEntry.EpochNumber-1)
+		}
+	}
+	if err := upsertValidatorEpochSummaries(db, completedEpochNumbers); err != nil {
+		return errors.Wrapf(err, "entries.bulkInsertEpochEntry: Error upserting validator epoch summaries")
+	}
+	if err := upsertValidatorPerformance(db, completedEpochNumbers); err != nil {
+		return errors.Wrapf(err, "entries.bulkInsertEpochEntry: Error upserting validator performance")
+	}
+
+	// A new epoch can resolve the epochs of stored unstakes, and make locked unstakes unlockable.
+	if err := resolveUnstakeLifecycleEpochs(db, params); err != nil {
+		return errors.Wrapf(err, "entries.bulkInsertEpochEntry: Error resolving unstake lifecycle epochs")
+	}
 	return nil
 }
+
+// getEpochNumbersByBlockHeight returns a map of block height to the number of the epoch containing that height.
+// Heights whose epoch isn't stored yet are left out.
+func getEpochNumbersByBlockHeight(db bun.IDB, blockHeights []uint64) (map[uint64]uint64, error) {
+	epochNumbers := make(map[uint64]uint64)
+	if len(blockHeights) == 0 {
+		return epochNumbers, nil
+	}
+	minHeight, maxHeight := blockHeights[0], blockHeights[0]
+	for _, blockHeight := range blockHeights {
+		if blockHeight < minHeight {
+			minHeight = blockHeight
+		}
+		if blockHeight > maxHeight {
+			maxHeight = blockHeight
+		}
+	}
+	var epochEntries []*PGEpochEntry
+	if err := db.NewSelect().
+		Model(&epochEntries).
+		Column("epoch_number", "initial_block_height", "final_block_height").
+		Where("final_block_height >= ?", minHeight).
+		Where("initial_block_height <= ?", maxHeight).
+		Scan(context.Background()); err != nil {
+		return nil, errors.Wrapf(err, "entries.getEpochNumbersByBlockHeight: Error getting epoch entries")
+	}
+	for _, blockHeight := range blockHeights {
+		for _, epochEntry := range epochEntries {
+			if epochEntry.InitialBlockHeight <= blockHeight && blockHeight <= epochEntry.FinalBlockHeight {
+				epochNumbers[blockHeight] = epochEntry.EpochNumber
+				break
+			}
+		}
+	}
+	return epochNumbers, nil
+}
@@ -2,10 +2,13 @@ package entries
 
 import (
 	"context"
+	"fmt"
 	"github.com/deso-protocol/core/lib"
 	"github.com/deso-protocol/state-consumer/consumer"
+	"github.com/golang/glog"
 	"github.com/pkg/errors"
 	"github.com/uptrace/bun"
+	"time"
 )
 
 type FollowEntry struct {
@@ -25,6 +28,59 @@ type PGFollowEntryUtxoOps struct {
 	UtxoOperation
 }
 
+// FollowEvent records a follow or unfollow at a given block height. Rows are written by the follow batch operation
+// when follow entries are inserted or deleted, and by the utxo operation parser, which attributes the event to the
+// transaction that caused it.
+type FollowEvent struct {
+	FollowerPkid string    `pg:",pk,use_zero"`
+	FollowedPkid string    `pg:",pk,use_zero"`
+	BlockHeight  uint64    `pg:",pk,use_zero"`
+	IsUnfollow   bool      `pg:",use_zero"`
+	BlockHash    string    `bun:",nullzero"`
+	TxnHash      string    `bun:",nullzero"`
+	Timestamp    time.Time `bun:",nullzero"`
+}
+
+type PGFollowEvent struct {
+	bun.BaseModel `bun:"table:follow_event"`
+	FollowEvent
+}
+
+// FollowerCountDaily is the number of follows, unfollows and followers of a PKID on a given day. Days are taken from
+// the latest stored block at or below the height of each follow event.
+type FollowerCountDaily struct {
+	Pkid          string    `pg:",pk,use_zero"`
+	Day           time.Time `pg:",pk,use_zero"`
+	Follows       int64     `pg:",use_zero"`
+	Unfollows     int64     `pg:",use_zero"`
+	FollowerCount int64     `pg:",use_zero"`
+}
+
+type PGFollowerCountDaily struct {
+	bun.BaseModel `bun:"table:follower_count_daily"`
+	FollowerCountDaily
+}
+
+// FollowerCountDelta is the net change in a PKID's followers at a given block height.
+type FollowerCountDelta struct {
+	Pkid        string
+	BlockHeight uint64
+	Follows     int64
+	Unfollows   int64
+}
+
+// followEventAttribution links a follow or unfollow transaction to the follow event it caused. The public keys
+// are resolved to PKIDs when the attribution is written.
+type followEventAttribution struct {
+	FollowerPublicKey string
+	FollowedPublicKey string
+	IsUnfollow        bool
+	BlockHeight       uint64
+	BlockHash         string
+	TxnHash           string
+	Timestamp         time.Time
+}
+
 // Convert the follow DeSo encoder to the PG struct used by bun.
 func FollowEncoderToPGStruct(followEntry *lib.FollowEntry, keyBytes []byte, params *lib.DeSoParams) FollowEntry {
 	return FollowEntry{
@@ -64,6 +120,23 @@ func bulkInsertFollowEntry(entries []*lib.StateChangeEntry, db bun.IDB, operatio
 		pgEntrySlice[ii] = &PGFollowEntry{FollowEntry: FollowEncoderToPGStruct(entry.Encoder.(*lib.FollowEntry), entry.KeyBytes, params)}
 	}
 
+	// Look up which follows already exist, so that re-syncing a follow isn't recorded as a new follow.
+	// Follows can't already exist during the initial sync, so we skip the lookup there.
+	existingFollowKeys := make(map[string]bool)
+	if operationType == lib.DbOperationTypeUpsert {
+		var existingEntries []*PGFollowEntry
+		if err := db.NewSelect().
+			Model(&existingEntries).
+			Column("badger_key").
+			Where("badger_key IN (?)", bun.In(consumer.KeysToDelete(uniqueEntries))).
+			Scan(context.Background()); err != nil {
+			return errors.Wrapf(err, "entries.bulkInsertFollowEntry: Error getting existing entries")
+		}
+		for _, existingEntry := range existingEntries {
+			existingFollowKeys[string(existingEntry.BadgerKey)] = true
+		}
+	}
+
 	// Execute the insert query.
 	query := db.NewInsert().Model(&pgEntrySlice)
 
@@ -74,6 +147,22 @@ func bulkInsertFollowEntry(entries []*lib.StateChangeEntry, db bun.IDB, operatio
 	if _, err := query.Returning("").Exec(context.Background()); err != nil {
 		return errors.Wrapf(err, "entries.bulkInsertFollowEntry: Error inserting entries")
 	}
+
+	var pgEventSlice []*PGFollowEvent
+	for ii, entry := range uniqueEntries {
+		if existingFollowKeys[string(entry.KeyBytes)] {
+			continue
+		}
+		pgEventSlice = append(pgEventSlice, &PGFollowEvent{FollowEvent: FollowEvent{
+			FollowerPkid: pgEntrySlice[ii].FollowerPkid,
+			FollowedPkid: pgEntrySlice[ii].FollowedPkid,
+			BlockHeight:  entry.BlockHeight,
+			IsUnfollow:   false,
+		}})
+	}
+	if err := bulkInsertFollowEvents(pgEventSlice, db, operationType); err != nil {
+		return errors.Wrapf(err, "entries.bulkInsertFollowEntry: Error inserting follow events")
+	}
 	return nil
 }
 
@@ -85,6 +174,15 @@ func bulkDeleteFollowEntry(entries []*lib.StateChangeEntry, db bun.IDB, operatio
 	// Transform the entries into a list of keys to delete.
 	keysToDelete := consumer.KeysToDelete(uniqueEntries)
 
+	// Look up the follows being deleted, so that we can record them as unfollows.
+	var prevEntries []*PGFollowEntry
+	if err := db.NewSelect().
+		Model(&prevEntries).
+		Where("badger_key IN (?)", bun.In(keysToDelete)).
+		Scan(context.Background()); err != nil {
+		return errors.Wrapf(err, "entries.bulkDeleteFollowEntry: Error getting previous entries")
+	}
+
 	// Execute the delete query.
 	if _, err := db.NewDelete().
 		Model(&PGFollowEntry{}).
@@ -94,5 +192,210 @@ func bulkDeleteFollowEntry(entries []*lib.StateChangeEntry, db bun.IDB, operatio
 		return errors.Wrapf(err, "entries.bulkDeleteFollowEntry: Error deleting entries")
 	}
 
+	blockHeightsByKey := make(map[string]uint64)
+	for _, entry := range uniqueEntries {
+		blockHeightsByKey[string(entry.KeyBytes)] = entry.BlockHeight
+	}
+	pgEventSlice := make([]*PGFollowEvent, len(prevEntries))
+	for ii, prevEntry := range prevEntries {
+		pgEventSlice[ii] = &PGFollowEvent{FollowEvent: FollowEvent{
+			FollowerPkid: prevEntry.FollowerPkid,
+			FollowedPkid: prevEntry.FollowedPkid,
+			BlockHeight:  blockHeightsByKey[string(prevEntry.BadgerKey)],
+			IsUnfollow:   true,
+		}}
+	}
+	if err := bulkInsertFollowEvents(pgEventSlice, db, operationType); err != nil {
+		return errors.Wrapf(err, "entries.bulkDeleteFollowEntry: Error inserting follow events")
+	}
+
+	return nil
+}
+
+// bulkInsertFollowEvents records a batch of follows and unfollows, and applies them to the daily follower counts.
+// The blocks of the initial sync aren't stored yet when its follows are, so the daily follower counts are backfilled
+// from its follow events once the initial sync is done instead.
+func bulkInsertFollowEvents(pgEventSlice []*PGFollowEvent, db bun.IDB, operationType lib.StateSyncerOperationType) error {
+	if len(pgEventSlice) == 0 {
+		return nil
+	}
+	// If a follow is reversed within the same block height, only the latest event is kept.
+	if _, err := db.NewInsert().
+		Model(&pgEventSlice).
+		On("CONFLICT (follower_pkid, followed_pkid, block_height) DO UPDATE").
+		Set("is_unfollow = EXCLUDED.is_unfollow").
+		Returning("").
+		Exec(context.Background()); err != nil {
+		return errors.Wrapf(err, "entries.bulkInsertFollowEvents: Error inserting entries")
+	}
+	if operationType == lib.DbOperationTypeInsert {
+		return nil
+	}
+
+	if err := applyFollowerCountDeltas(followerCountDeltas(pgEventSlice), db); err != nil {
+		return errors.Wrapf(err, "entries.bulkInsertFollowEvents: Error updating follower counts")
+	}
+	return nil
+}
+
+// followerCountDeltas sums up the follows and unfollows of each followed PKID at each height.
+func followerCountDeltas(pgEventSlice []*PGFollowEvent) []*FollowerCountDelta {
+	deltaIndex := make(map[string]int)
+	var deltas []*FollowerCountDelta
+	for _, pgEvent := range pgEventSlice {
+		deltaKey := fmt.Sprintf("%v:%v", pgEvent.FollowedPkid, pgEvent.BlockHeight)
+		if _, ok := deltaIndex[deltaKey]; !ok {
+			deltaIndex[deltaKey] = len(deltas)
+			deltas = append(deltas, &FollowerCountDelta{Pkid: pgEvent.FollowedPkid, BlockHeight: pgEvent.BlockHeight})
+		}
+		if pgEvent.IsUnfollow {
+			deltas[deltaIndex[deltaKey]].Unfollows++
+		} else {
+			deltas[deltaIndex[deltaKey]].Follows++
+		}
+	}
+	return deltas
+}
+
+// applyFollowerCountDeltas adds follower count changes to the daily follower counts. Each change is bucketed by the
+// day of the latest stored block at or below its height, as the block it happened in may not be stored yet. The
+// follower counts of each changed PKID are then carried forward from the earliest day it changed on.
+func applyFollowerCountDeltas(deltas []*FollowerCountDelta, db bun.IDB) error {
+	if len(deltas) == 0 {
+		return nil
+	}
+	var pgDailySlice []*PGFollowerCountDaily
+	if err := db.NewRaw(`
+		WITH _data (pkid, block_height, follows, unfollows) AS (?)
+		SELECT _data.pkid, block_day.day, SUM(_data.follows) AS follows, SUM(_data.unfollows) AS unfollows
+		FROM _data
+		JOIN LATERAL (
+			SELECT block.timestamp::date AS day FROM block
+			WHERE block.height <= _data.block_height
+			ORDER BY block.height DESC LIMIT 1
+		) block_day ON true
+		GROUP BY 1, 2
+	`, db.NewValues(&deltas)).Scan(context.Background(), &pgDailySlice); err != nil {
+		return errors.Wrapf(err, "entries.applyFollowerCountDeltas: Error bucketing follower counts by day")
+	}
+	if len(pgDailySlice) == 0 {
+		return nil
+	}
+
+	if _, err := db.NewInsert().
+		Model(&pgDailySlice).
+		On("CONFLICT (pkid, day) DO UPDATE").
+		Set("follows = ?TableAlias.follows + EXCLUDED.follows").
+		Set("unfollows = ?TableAlias.unfollows + EXCLUDED.unfollows").
+		Returning("").
+		Exec(context.Background()); err != nil {
+		return errors.Wrapf(err, "entries.applyFollowerCountDeltas: Error inserting entries")
+	}
+
+	if _, err := db.NewRaw(`
+		WITH _data (pkid, day, follows, unfollows, follower_count) AS (?),
+		_changed AS (
+			SELECT pkid, MIN(day)::date AS day FROM _data GROUP BY pkid
+		),
+		_counts AS (
+			SELECT daily.pkid, daily.day,
+				COALESCE((
+					SELECT prev.follower_count FROM follower_count_daily prev
+					WHERE prev.pkid = _changed.pkid AND prev.day < _changed.day
+					ORDER BY prev.day DESC LIMIT 1
+				), 0) + SUM(daily.follows - daily.unfollows) OVER (PARTITION BY daily.pkid ORDER BY daily.day)
+					AS follower_count
+			FROM follower_count_daily daily
+			JOIN _changed ON daily.pkid = _changed.pkid AND daily.day >= _changed.day
+		)
+		UPDATE follower_count_daily SET follower_count = _counts.follower_count
+		FROM _counts
+		WHERE follower_count_daily.pkid = _counts.pkid AND follower_count_daily.day = _counts.day
+	`, db.NewValues(&pgDailySlice)).Exec(context.Background()); err != nil {
+		return errors.Wrapf(err, "entries.applyFollowerCountDeltas: Error updating follower counts")
+	}
+	return nil
+}
+
+// FollowEventAttributionFromUtxoOps returns the follow event caused by a follow transaction. The second return
+// value is false if the transaction didn't follow or unfollow anyone.
+func FollowEventAttributionFromUtxoOps(
+	txn *lib.MsgDeSoTxn,
+	utxoOps []*lib.UtxoOperation,
+	transaction *PGTransactionEntry,
+	params *lib.DeSoParams,
+) (*followEventAttribution, bool) {
+	followMetadata, ok := txn.TxnMeta.(*lib.FollowMetadata)
+	if !ok || consumer.GetUtxoOpByOperationType(utxoOps, lib.OperationTypeFollow) == nil {
+		return nil, false
+	}
+	return &followEventAttribution{
+		FollowerPublicKey: transaction.PublicKey,
+		FollowedPublicKey: consumer.PublicKeyBytesToBase58Check(followMetadata.FollowedPublicKey, params),
+		IsUnfollow:        followMetadata.IsUnfollow,
+		BlockHeight:       transaction.BlockHeight,
+		BlockHash:         transaction.BlockHash,
+		TxnHash:           transaction.TransactionHash,
+		Timestamp:         transaction.Timestamp,
+	}, true
+}
+
+// bulkInsertFollowEventAttributions links follow events to the block and transaction that caused them. Only events
+// already recorded by the follow batch operation are attributed, as a follow transaction doesn't always change the
+// follow entries, e.g. when it follows someone who is already followed.
+func bulkInsertFollowEventAttributions(attributions []*followEventAttribution, db bun.IDB) error {
+	if len(attributions) == 0 {
+		return nil
+	}
+	publicKeys := make([]string, 0, 2*len(attributions))
+	for _, attribution := range attributions {
+		publicKeys = append(publicKeys, attribution.FollowerPublicKey, attribution.FollowedPublicKey)
+	}
+	pkids, err := getPkidsByPublicKey(db, publicKeys)
+	if err != nil {
+		return errors.Wrapf(err, "entries.bulkInsertFollowEventAttributions: Error getting pkids")
+	}
+
+	var pgEventSlice []*PGFollowEvent
+	for _, attribution := range attributions {
+		followerPkid := pkids[attribution.FollowerPublicKey]
+		followedPkid := pkids[attribution.FollowedPublicKey]
+		if followerPkid == "" || followedPkid == "" {
+			glog.Warningf("entries.bulkInsertFollowEventAttributions: Unknown follower or followed for transaction %v "+
+				"at block height %v, leaving it unattributed", attribution.TxnHash, attribution.BlockHeight)
+			continue
+		}
+		pgEventSlice = append(pgEventSlice, &PGFollowEvent{FollowEvent: FollowEvent{
+			FollowerPkid: followerPkid,
+			FollowedPkid: followedPkid,
+			BlockHeight:  attribution.BlockHeight,
+			IsUnfollow:   attribution.IsUnfollow,
+			BlockHash:    attribution.BlockHash,
+			TxnHash:      attribution.TxnHash,
+			Timestamp:    attribution.Timestamp,
+		}})
+	}
+	dedupedEventSlice := latestAttributions(pgEventSlice, func(pgEvent *PGFollowEvent) string {
+		return fmt.Sprintf("%v:%v:%v", pgEvent.FollowerPkid, pgEvent.FollowedPkid, pgEvent.BlockHeight)
+	}, nil)
+	if len(dedupedEventSlice) == 0 {
+		return nil
+	}
+
+	// Whether the event is a follow or an unfollow is left to the follow batch operation, which sees the net
+	// result of the block.
+	if _, err := db.NewUpdate().
+		With("_data", db.NewValues(&dedupedEventSlice)).
+		Model((*PGFollowEvent)(nil)).
+		TableExpr("_data").
+		Set("block_hash = _data.block_hash").
+		Set("txn_hash = _data.txn_hash").
+		Set("timestamp = _data.timestamp").
+		Where("pg_follow_event.follower_pkid = _data.follower_pkid").
+		Where("pg_follow_event.followed_pkid = _data.followed_pkid").
+		Where("pg_follow_event.block_height = _data.block_height").
+		Exec(context.Background()); err != nil {
+		return errors.Wrapf(err, "entries.bulkInsertFollowEventAttributions: Error updating entries")
+	}
 	return nil
 }
@@ -0,0 +1,58 @@
+package entries
+
+import (
+	"testing"
+	"time"
+
+	"github.com/deso-protocol/core/lib"
+	"github.com/deso-protocol/state-consumer/consumer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFollowerCountDeltas(t *testing.T) {
+	require.Nil(t, followerCountDeltas(nil))
+
+	deltas := followerCountDeltas([]*PGFollowEvent{
+		{FollowEvent: FollowEvent{FollowerPkid: "a", FollowedPkid: "x", BlockHeight: 10}},
+		{FollowEvent: FollowEvent{FollowerPkid: "b", FollowedPkid: "x", BlockHeight: 10}},
+		{FollowEvent: FollowEvent{FollowerPkid: "c", FollowedPkid: "x", BlockHeight: 10, IsUnfollow: true}},
+		{FollowEvent: FollowEvent{FollowerPkid: "a", FollowedPkid: "y", BlockHeight: 10, IsUnfollow: true}},
+		{FollowEvent: FollowEvent{FollowerPkid: "a", FollowedPkid: "x", BlockHeight: 11, IsUnfollow: true}},
+	})
+	require.Equal(t, []*FollowerCountDelta{
+		{Pkid: "x", BlockHeight: 10, Follows: 2, Unfollows: 1},
+		{Pkid: "y", BlockHeight: 10, Follows: 0, Unfollows: 1},
+		{Pkid: "x", BlockHeight: 11, Follows: 0, Unfollows: 1},
+	}, deltas)
+}
+
+func TestFollowEventAttributionFromUtxoOps(t *testing.T) {
+	params := &lib.DeSoMainnetParams
+	followedPublicKey := testPublicKeyBytes(2)
+	transaction := &PGTransactionEntry{TransactionEntry: TransactionEntry{
+		TransactionHash: "txn",
+		PublicKey:       consumer.PublicKeyBytesToBase58Check(testPublicKeyBytes(1), params),
+		BlockHash:       "block",
+		BlockHeight:     100,
+		Timestamp:       time.Unix(1700000000, 0),
+	}}
+	txn := &lib.MsgDeSoTxn{TxnMeta: &lib.FollowMetadata{FollowedPublicKey: followedPublicKey, IsUnfollow: true}}
+
+	attribution, ok := FollowEventAttributionFromUtxoOps(
+		txn, []*lib.UtxoOperation{{Type: lib.OperationTypeFollow}}, transaction, params)
+	require.True(t, ok)
+	require.Equal(t, &followEventAttribution{
+		FollowerPublicKey: transaction.PublicKey,
+		FollowedPublicKey: consumer.PublicKeyBytesToBase58Check(followedPublicKey, params),
+		IsUnfollow:        true,
+		BlockHeight:       100,
+		BlockHash:         "block",
+		TxnHash:           "txn",
+		Timestamp:         transaction.Timestamp,
+	}, attribution)
+
+	// A transaction that didn't change any follow entries isn't attributed.
+	_, ok = FollowEventAttributionFromUtxoOps(
+		txn, []*lib.UtxoOperation{{Type: lib.OperationTypeSpendBalance}}, transaction, params)
+	require.False(t, ok)
+}
@@ -118,6 +118,11 @@ func bulkInsertGlobalParamsEntry(entries []*lib.StateChangeEntry, db bun.IDB, op
 	if _, err := query.Returning("").Exec(context.Background()); err != nil {
 		return errors.Wrapf(err, "entries.bulkInsertGlobalParamsEntry: Error inserting entries")
 	}
+
+	// A change to the stake lockup duration changes when unstakes become unlockable.
+	if err := resolveUnstakeLifecycleEpochs(db, params); err != nil {
+		return errors.Wrapf(err, "entries.bulkInsertGlobalParamsEntry: Error resolving unstake lifecycle epochs")
+	}
 	return nil
 }
 
@@ -7,6 +7,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/uptrace/bun"
 	"github.com/uptrace/bun/extra/bunbig"
+	"math/big"
 )
 
 // TODO: when to use nullzero vs use_zero?
@@ -83,6 +84,17 @@ func bulkInsertLockedStakeEntry(entries []*lib.StateChangeEntry, db bun.IDB, ope
 		pgEntrySlice[ii] = &PGLockedStakeEntry{LockedStakeEntry: LockedStakeEncoderToPGStruct(entry.Encoder.(*lib.LockedStakeEntry), entry.KeyBytes, params)}
 	}
 
+	// Look up the current locked amounts before they're overwritten, so that we can record the change in locked stake.
+	// Locked stake entries can't already exist during the initial sync, so we skip the lookup there.
+	prevEntries := make(map[string]*PGLockedStakeEntry)
+	if operationType == lib.DbOperationTypeUpsert {
+		var err error
+		prevEntries, err = getLockedStakeEntriesByBadgerKey(db, consumer.KeysToDelete(uniqueEntries))
+		if err != nil {
+			return errors.Wrapf(err, "entries.bulkInsertLockedStakeEntry: Error getting previous locked stake entries")
+		}
+	}
+
 	// Execute the insert query.
 	query := db.NewInsert().Model(&pgEntrySlice)
 
@@ -93,6 +105,24 @@ func bulkInsertLockedStakeEntry(entries []*lib.StateChangeEntry, db bun.IDB, ope
 	if _, err := query.Returning("").Exec(context.Background()); err != nil {
 		return errors.Wrapf(err, "entries.bulkInsertLockedStakeEntry: Error inserting entries")
 	}
+
+	// Append the locked stake positions that changed to the stake position history.
+	var pgHistorySlice []*PGStakePositionHistoryEntry
+	for ii, entry := range uniqueEntries {
+		amountBeforeNanos := big.NewInt(0)
+		if prevEntry, exists := prevEntries[string(entry.KeyBytes)]; exists {
+			amountBeforeNanos = prevEntry.LockedAmountNanos.ToMathBig()
+			if amountBeforeNanos.Cmp(pgEntrySlice[ii].LockedAmountNanos.ToMathBig()) == 0 {
+				continue
+			}
+		}
+		pgHistorySlice = append(pgHistorySlice, newStakePositionHistoryEntry(pgEntrySlice[ii].StakerPKID,
+			pgEntrySlice[ii].ValidatorPKID, StakePositionTypeLockedStake, pgEntrySlice[ii].LockedAtEpochNumber,
+			entry.BlockHeight, amountBeforeNanos, pgEntrySlice[ii].LockedAmountNanos.ToMathBig()))
+	}
+	if err := bulkInsertStakePositionHistoryEntry(pgHistorySlice, db); err != nil {
+		return errors.Wrapf(err, "entries.bulkInsertLockedStakeEntry: Error inserting stake position history")
+	}
 	return nil
 }
 
@@ -104,8 +134,14 @@ func bulkDeleteLockedStakeEntry(entries []*lib.StateChangeEntry, db bun.IDB, ope
 	// Transform the entries into a list of keys to delete.
 	keysToDelete := consumer.KeysToDelete(uniqueEntries)
 
+	// Look up the locked stake entries being deleted, so that we can record their removal in the stake position history.
+	prevEntries, err := getLockedStakeEntriesByBadgerKey(db, keysToDelete)
+	if err != nil {
+		return errors.Wrapf(err, "entries.bulkDeleteLockedStakeEntry: Error getting previous locked stake entries")
+	}
+
 	// Execute the delete query.
-	if _, err := db.NewDelete().
+	if _, err = db.NewDelete().
 		Model(&PGLockedStakeEntry{}).
 		Where("badger_key IN (?)", bun.In(keysToDelete)).
 		Returning("").
@@ -113,5 +149,40 @@ func bulkDeleteLockedStakeEntry(entries []*lib.StateChangeEntry, db bun.IDB, ope
 		return errors.Wrapf(err, "entries.bulkDeleteLockedStakeEntry: Error deleting entries")
 	}
 
+	// Record each deleted locked stake entry as an empty position at the height it was deleted.
+	var pgHistorySlice []*PGStakePositionHistoryEntry
+	for _, entry := range uniqueEntries {
+		prevEntry, exists := prevEntries[string(entry.KeyBytes)]
+		if !exists {
+			continue
+		}
+		pgHistorySlice = append(pgHistorySlice, newStakePositionHistoryEntry(prevEntry.StakerPKID,
+			prevEntry.ValidatorPKID, StakePositionTypeLockedStake, prevEntry.LockedAtEpochNumber, entry.BlockHeight,
+			prevEntry.LockedAmountNanos.ToMathBig(), big.NewInt(0)))
+	}
+	if err = bulkInsertStakePositionHistoryEntry(pgHistorySlice, db); err != nil {
+		return errors.Wrapf(err, "entries.bulkDeleteLockedStakeEntry: Error inserting stake position history")
+	}
+
 	return nil
 }
+
+// getLockedStakeEntriesByBadgerKey returns a map of badger key to the locked stake entry currently stored for that key.
+func getLockedStakeEntriesByBadgerKey(db bun.IDB, keys [][]byte) (map[string]*PGLockedStakeEntry, error) {
+	lockedStakeEntries := make(map[string]*PGLockedStakeEntry)
+	if len(keys) == 0 {
+		return lockedStakeEntries, nil
+	}
+	var pgEntries []*PGLockedStakeEntry
+	if err := db.NewSelect().
+		Model(&pgEntries).
+		Column("badger_key", "staker_pkid", "validator_pkid", "locked_amount_nanos", "locked_at_epoch_number").
+		Where("badger_key IN (?)", bun.In(keys)).
+		Scan(context.Background()); err != nil {
+		return nil, errors.Wrapf(err, "entries.getLockedStakeEntriesByBadgerKey: Error getting locked stake entries")
+	}
+	for _, pgEntry := range pgEntries {
+		lockedStakeEntries[string(pgEntry.BadgerKey)] = pgEntry
+	}
+	return lockedStakeEntries, nil
+}
@@ -0,0 +1,210 @@
+package entries
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/uptrace/bun"
+)
+
+const (
+	MessageThreadKeyPrefixDm    = "dm:"
+	MessageThreadKeyPrefixGroup = "group:"
+)
+
+// MessageThread summarizes a conversation, so that an inbox can be built without scanning every message. A DM
+// thread is keyed by the pair of access groups exchanging messages, and a group chat thread by its access group.
+type MessageThread struct {
+	ThreadKey   string `pg:",pk,use_zero"`
+	IsGroupChat bool
+	// For group chats, the access group the messages are sent to. For DMs, the participant that sorts first.
+	AccessGroupOwnerPublicKey string `bun:",nullzero"`
+	AccessGroupKeyName        string `bun:",nullzero"`
+	// For DMs, the participant that sorts second. Empty for group chats.
+	PartyAccessGroupOwnerPublicKey string    `bun:",nullzero"`
+	PartyAccessGroupKeyName        string    `bun:",nullzero"`
+	ParticipantPublicKeys          []string  `bun:",array"`
+	LastMessageTimestamp           time.Time `pg:",use_zero"`
+	LastMessageSenderPublicKey     string    `bun:",nullzero"`
+	MessageCount                   uint64    `pg:",use_zero"`
+}
+
+type PGMessageThread struct {
+	bun.BaseModel `bun:"table:message_thread"`
+	MessageThread
+}
+
+// messageThreadDeletion is the number of messages deleted from a thread.
+type messageThreadDeletion struct {
+	ThreadKey    string
+	DeletedCount uint64
+}
+
+// NewMessageToMessageThread returns the thread a message belongs to, with the message as its only message.
+func NewMessageToMessageThread(newMessageEntry *NewMessageEntry) MessageThread {
+	messageThread := MessageThread{
+		IsGroupChat:                newMessageEntry.IsGroupChatMessage,
+		ParticipantPublicKeys:      []string{newMessageEntry.SenderAccessGroupOwnerPublicKey},
+		LastMessageTimestamp:       newMessageEntry.Timestamp,
+		LastMessageSenderPublicKey: newMessageEntry.SenderAccessGroupOwnerPublicKey,
+		MessageCount:               1,
+	}
+	recipient := messageThreadParty(newMessageEntry.RecipientAccessGroupOwnerPublicKey, newMessageEntry.RecipientAccessGroupKeyName)
+	if newMessageEntry.IsGroupChatMessage {
+		messageThread.ThreadKey = MessageThreadKeyPrefixGroup + recipient
+		messageThread.AccessGroupOwnerPublicKey = newMessageEntry.RecipientAccessGroupOwnerPublicKey
+		messageThread.AccessGroupKeyName = newMessageEntry.RecipientAccessGroupKeyName
+		return messageThread
+	}
+
+	// Order the participants so that both directions of a DM map to the same thread. The comparison is bytewise, to
+	// match the "C" collation used when the threads were first built in postgres.
+	sender := messageThreadParty(newMessageEntry.SenderAccessGroupOwnerPublicKey, newMessageEntry.SenderAccessGroupKeyName)
+	if sender <= recipient {
+		messageThread.ThreadKey = MessageThreadKeyPrefixDm + sender + "|" + recipient
+		messageThread.AccessGroupOwnerPublicKey = newMessageEntry.SenderAccessGroupOwnerPublicKey
+		messageThread.AccessGroupKeyName = newMessageEntry.SenderAccessGroupKeyName
+		messageThread.PartyAccessGroupOwnerPublicKey = newMessageEntry.RecipientAccessGroupOwnerPublicKey
+		messageThread.PartyAccessGroupKeyName = newMessageEntry.RecipientAccessGroupKeyName
+	} else {
+		messageThread.ThreadKey = MessageThreadKeyPrefixDm + recipient + "|" + sender
+		messageThread.AccessGroupOwnerPublicKey = newMessageEntry.RecipientAccessGroupOwnerPublicKey
+		messageThread.AccessGroupKeyName = newMessageEntry.RecipientAccessGroupKeyName
+		messageThread.PartyAccessGroupOwnerPublicKey = newMessageEntry.SenderAccessGroupOwnerPublicKey
+		messageThread.PartyAccessGroupKeyName = newMessageEntry.SenderAccessGroupKeyName
+	}
+	if newMessageEntry.RecipientAccessGroupOwnerPublicKey != newMessageEntry.SenderAccessGroupOwnerPublicKey {
+		messageThread.ParticipantPublicKeys = append(messageThread.ParticipantPublicKeys, newMessageEntry.RecipientAccessGroupOwnerPublicKey)
+	}
+	return messageThread
+}
+
+func messageThreadParty(accessGroupOwnerPublicKey string, accessGroupKeyName string) string {
+	return accessGroupOwnerPublicKey + ":" + accessGroupKeyName
+}
+
+// bulkInsertMessageThreads adds a batch of new messages to their threads, creating any threads that don't exist yet.
+func bulkInsertMessageThreads(newMessageEntries []*NewMessageEntry, db bun.IDB) error {
+	// Combine the messages sent to the same thread.
+	threadIndex := make(map[string]int)
+	var pgThreadSlice []*PGMessageThread
+	for _, newMessageEntry := range newMessageEntries {
+		messageThread := NewMessageToMessageThread(newMessageEntry)
+		ii, ok := threadIndex[messageThread.ThreadKey]
+		if !ok {
+			threadIndex[messageThread.ThreadKey] = len(pgThreadSlice)
+			pgThreadSlice = append(pgThreadSlice, &PGMessageThread{MessageThread: messageThread})
+			continue
+		}
+		pgThread := pgThreadSlice[ii]
+		pgThread.MessageCount++
+		if !messageThread.LastMessageTimestamp.Before(pgThread.LastMessageTimestamp) {
+			pgThread.LastMessageTimestamp = messageThread.LastMessageTimestamp
+			pgThread.LastMessageSenderPublicKey = messageThread.LastMessageSenderPublicKey
+		}
+		pgThread.ParticipantPublicKeys = mergeParticipantPublicKeys(pgThread.ParticipantPublicKeys, messageThread.ParticipantPublicKeys)
+	}
+	if len(pgThreadSlice) == 0 {
+		return nil
+	}
+	for _, pgThread := range pgThreadSlice {
+		sort.Strings(pgThread.ParticipantPublicKeys)
+	}
+
+	if _, err := db.NewInsert().
+		Model(&pgThreadSlice).
+		On("CONFLICT (thread_key) DO UPDATE").
+		Set("message_count = ?TableAlias.message_count + EXCLUDED.message_count").
+		Set("last_message_sender_public_key = CASE WHEN EXCLUDED.last_message_timestamp >= ?TableAlias.last_message_timestamp THEN EXCLUDED.last_message_sender_public_key ELSE ?TableAlias.last_message_sender_public_key END").
+		Set("last_message_timestamp = GREATEST(?TableAlias.last_message_timestamp, EXCLUDED.last_message_timestamp)").
+		Set("participant_public_keys = ARRAY(SELECT DISTINCT unnest(?TableAlias.participant_public_keys || EXCLUDED.participant_public_keys) ORDER BY 1)").
+		Returning("").
+		Exec(context.Background()); err != nil {
+		return errors.Wrapf(err, "entries.bulkInsertMessageThreads: Error upserting threads")
+	}
+	return nil
+}
+
+func mergeParticipantPublicKeys(participantPublicKeys []string, newParticipantPublicKeys []string) []string {
+	for _, newParticipantPublicKey := range newParticipantPublicKeys {
+		found := false
+		for _, participantPublicKey := range participantPublicKeys {
+			if participantPublicKey == newParticipantPublicKey {
+				found = true
+				break
+			}
+		}
+		if !found {
+			participantPublicKeys = append(participantPublicKeys, newParticipantPublicKey)
+		}
+	}
+	return participantPublicKeys
+}
+
+// bulkDeleteMessageThreads removes a batch of deleted messages from their threads. Threads left without any messages
+// are deleted. The messages must already have been deleted from new_message_entry, as the last message and the
+// participants of the affected threads are recomputed from the messages that remain.
+func bulkDeleteMessageThreads(deletedEntries []*PGNewMessageEntry, db bun.IDB) error {
+	deletionIndex := make(map[string]int)
+	var deletions []*messageThreadDeletion
+	for _, deletedEntry := range deletedEntries {
+		if deletedEntry.ThreadKey == "" {
+			continue
+		}
+		ii, ok := deletionIndex[deletedEntry.ThreadKey]
+		if !ok {
+			ii = len(deletions)
+			deletionIndex[deletedEntry.ThreadKey] = ii
+			deletions = append(deletions, &messageThreadDeletion{ThreadKey: deletedEntry.ThreadKey})
+		}
+		deletions[ii].DeletedCount++
+	}
+	if len(deletions) == 0 {
+		return nil
+	}
+
+	if _, err := db.NewRaw(`
+		WITH _data (thread_key, deleted_count) AS (?)
+		UPDATE message_thread SET
+			message_count = GREATEST(message_thread.message_count - _data.deleted_count, 0),
+			last_message_timestamp = COALESCE(last_message.timestamp, message_thread.last_message_timestamp),
+			last_message_sender_public_key = last_message.sender_access_group_owner_public_key,
+			participant_public_keys = ARRAY(
+				SELECT DISTINCT participant.public_key
+				FROM new_message_entry
+				CROSS JOIN LATERAL (VALUES
+					(new_message_entry.sender_access_group_owner_public_key),
+					(CASE WHEN new_message_entry.is_group_chat_message THEN NULL ELSE new_message_entry.recipient_access_group_owner_public_key END)
+				) AS participant (public_key)
+				WHERE new_message_entry.thread_key = message_thread.thread_key AND participant.public_key IS NOT NULL
+				ORDER BY 1
+			)
+		FROM _data
+		LEFT JOIN LATERAL (
+			SELECT new_message_entry.timestamp, new_message_entry.sender_access_group_owner_public_key
+			FROM new_message_entry
+			WHERE new_message_entry.thread_key = _data.thread_key
+			ORDER BY new_message_entry.timestamp DESC
+			LIMIT 1
+		) last_message ON true
+		WHERE message_thread.thread_key = _data.thread_key
+	`, db.NewValues(&deletions)).Exec(context.Background()); err != nil {
+		return errors.Wrapf(err, "entries.bulkDeleteMessageThreads: Error updating threads")
+	}
+
+	threadKeys := make([]string, len(deletions))
+	for ii, deletion := range deletions {
+		threadKeys[ii] = deletion.ThreadKey
+	}
+	if _, err := db.NewDelete().
+		Model(&PGMessageThread{}).
+		Where("thread_key IN (?)", bun.In(threadKeys)).
+		Where("message_count = 0").
+		Returning("").
+		Exec(context.Background()); err != nil {
+		return errors.Wrapf(err, "entries.bulkDeleteMessageThreads: Error deleting empty threads")
+	}
+	return nil
+}
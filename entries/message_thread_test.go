@@ -0,0 +1,73 @@
+package entries
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewMessageToMessageThread(t *testing.T) {
+	timestamp := time.Unix(1700000000, 0)
+	dmMessage := &NewMessageEntry{
+		SenderAccessGroupOwnerPublicKey:    "bob",
+		SenderAccessGroupKeyName:           "default-key",
+		RecipientAccessGroupOwnerPublicKey: "alice",
+		RecipientAccessGroupKeyName:        "default-key",
+		Timestamp:                          timestamp,
+	}
+	require.Equal(t, MessageThread{
+		ThreadKey:                      "dm:alice:default-key|bob:default-key",
+		AccessGroupOwnerPublicKey:      "alice",
+		AccessGroupKeyName:             "default-key",
+		PartyAccessGroupOwnerPublicKey: "bob",
+		PartyAccessGroupKeyName:        "default-key",
+		ParticipantPublicKeys:          []string{"bob", "alice"},
+		LastMessageTimestamp:           timestamp,
+		LastMessageSenderPublicKey:     "bob",
+		MessageCount:                   1,
+	}, NewMessageToMessageThread(dmMessage))
+
+	// Both directions of a DM map to the same thread.
+	replyMessage := &NewMessageEntry{
+		SenderAccessGroupOwnerPublicKey:    "alice",
+		SenderAccessGroupKeyName:           "default-key",
+		RecipientAccessGroupOwnerPublicKey: "bob",
+		RecipientAccessGroupKeyName:        "default-key",
+		Timestamp:                          timestamp,
+	}
+	require.Equal(t, NewMessageToMessageThread(dmMessage).ThreadKey, NewMessageToMessageThread(replyMessage).ThreadKey)
+
+	// A note to self has a single participant.
+	noteToSelf := NewMessageToMessageThread(&NewMessageEntry{
+		SenderAccessGroupOwnerPublicKey:    "alice",
+		SenderAccessGroupKeyName:           "default-key",
+		RecipientAccessGroupOwnerPublicKey: "alice",
+		RecipientAccessGroupKeyName:        "default-key",
+	})
+	require.Equal(t, "dm:alice:default-key|alice:default-key", noteToSelf.ThreadKey)
+	require.Equal(t, []string{"alice"}, noteToSelf.ParticipantPublicKeys)
+
+	require.Equal(t, MessageThread{
+		ThreadKey:                  "group:alice:friends",
+		IsGroupChat:                true,
+		AccessGroupOwnerPublicKey:  "alice",
+		AccessGroupKeyName:         "friends",
+		ParticipantPublicKeys:      []string{"bob"},
+		LastMessageTimestamp:       timestamp,
+		LastMessageSenderPublicKey: "bob",
+		MessageCount:               1,
+	}, NewMessageToMessageThread(&NewMessageEntry{
+		SenderAccessGroupOwnerPublicKey:    "bob",
+		SenderAccessGroupKeyName:           "default-key",
+		RecipientAccessGroupOwnerPublicKey: "alice",
+		RecipientAccessGroupKeyName:        "friends",
+		IsGroupChatMessage:                 true,
+		Timestamp:                          timestamp,
+	}))
+}
+
+func TestMergeParticipantPublicKeys(t *testing.T) {
+	require.Equal(t, []string{"a", "b", "c"}, mergeParticipantPublicKeys([]string{"a", "b"}, []string{"b", "c"}))
+	require.Equal(t, []string{"a"}, mergeParticipantPublicKeys(nil, []string{"a"}))
+}
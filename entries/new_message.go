@@ -21,6 +21,7 @@ type NewMessageEntry struct {
 	EncryptedText                      string `pg:",use_zero"`
 	IsGroupChatMessage                 bool
 	Timestamp                          time.Time `pg:",use_zero"`
+	ThreadKey                          string    `bun:",nullzero"`
 
 	ExtraData map[string]string `bun:"type:jsonb"`
 	BadgerKey []byte            `pg:",pk,use_zero"`
@@ -77,6 +78,8 @@ func NewMessageEncoderToPGStruct(newMessageEntry *lib.NewMessageEntry, keyBytes
 		pgNewMessageEntry.RecipientAccessGroupPublicKey = consumer.PublicKeyBytesToBase58Check(newMessageEntry.RecipientAccessGroupPublicKey[:], params)
 	}
 
+	pgNewMessageEntry.ThreadKey = NewMessageToMessageThread(&pgNewMessageEntry).ThreadKey
+
 	return pgNewMessageEntry
 }
 
@@ -110,6 +113,23 @@ func bulkInsertNewMessageEntry(entries []*lib.StateChangeEntry, db bun.IDB, oper
 		pgEntrySlice[ii] = &PGNewMessageEntry{NewMessageEntry: NewMessageEncoderToPGStruct(entry.Encoder.(*lib.NewMessageEntry), entry.KeyBytes, params)}
 	}
 
+	// Look up which messages already exist, so that edits aren't counted as new messages in their thread.
+	// Messages can't already exist during the initial sync, so we skip the lookup there.
+	existingMessageKeys := make(map[string]bool)
+	if operationType == lib.DbOperationTypeUpsert {
+		var existingEntries []*PGNewMessageEntry
+		if err := db.NewSelect().
+			Model(&existingEntries).
+			Column("badger_key").
+			Where("badger_key IN (?)", bun.In(consumer.KeysToDelete(uniqueEntries))).
+			Scan(context.Background()); err != nil {
+			return errors.Wrapf(err, "entries.bulkInsertNewMessageEntry: Error getting existing entries")
+		}
+		for _, existingEntry := range existingEntries {
+			existingMessageKeys[string(existingEntry.BadgerKey)] = true
+		}
+	}
+
 	// Execute the insert query.
 	query := db.NewInsert().Model(&pgEntrySlice)
 
@@ -120,6 +140,16 @@ func bulkInsertNewMessageEntry(entries []*lib.StateChangeEntry, db bun.IDB, oper
 	if _, err := query.Returning("").Exec(context.Background()); err != nil {
 		return errors.Wrapf(err, "entries.bulkInsertNewMessageEntry: Error inserting entries")
 	}
+
+	var newMessageEntries []*NewMessageEntry
+	for _, pgEntry := range pgEntrySlice {
+		if !existingMessageKeys[string(pgEntry.BadgerKey)] {
+			newMessageEntries = append(newMessageEntries, &pgEntry.NewMessageEntry)
+		}
+	}
+	if err := bulkInsertMessageThreads(newMessageEntries, db); err != nil {
+		return errors.Wrapf(err, "entries.bulkInsertNewMessageEntry: Error updating message threads")
+	}
 	return nil
 }
 
@@ -131,6 +161,16 @@ func bulkDeleteNewMessageEntry(entries []*lib.StateChangeEntry, db bun.IDB, oper
 	// Transform the entries into a list of keys to delete.
 	keysToDelete := consumer.KeysToDelete(uniqueEntries)
 
+	// Look up the threads of the messages being deleted.
+	var prevEntries []*PGNewMessageEntry
+	if err := db.NewSelect().
+		Model(&prevEntries).
+		Column("badger_key", "thread_key").
+		Where("badger_key IN (?)", bun.In(keysToDelete)).
+		Scan(context.Background()); err != nil {
+		return errors.Wrapf(err, "entries.bulkDeleteNewMessageEntry: Error getting previous entries")
+	}
+
 	// Execute the delete query.
 	if _, err := db.NewDelete().
 		Model(&PGNewMessageEntry{}).
@@ -140,5 +180,9 @@ func bulkDeleteNewMessageEntry(entries []*lib.StateChangeEntry, db bun.IDB, oper
 		return errors.Wrapf(err, "entries.bulkDeleteNewMessageEntry: Error deleting entries")
 	}
 
+	if err := bulkDeleteMessageThreads(prevEntries, db); err != nil {
+		return errors.Wrapf(err, "entries.bulkDeleteNewMessageEntry: Error updating message threads")
+	}
+
 	return nil
 }
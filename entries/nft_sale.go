@@ -0,0 +1,100 @@
+package entries
+
+import (
+	"time"
+
+	"github.com/deso-protocol/core/lib"
+	"github.com/uptrace/bun"
+)
+
+// NftSale is a record of an NFT changing hands, either through an accepted bid or a buy now purchase.
+type NftSale struct {
+	TransactionHash         string `pg:",pk,use_zero"`
+	NftPostHash             string `pg:",use_zero"`
+	SerialNumber            uint64 `pg:",use_zero"`
+	SellerPublicKey         string `pg:",use_zero"`
+	BuyerPublicKey          string `pg:",use_zero"`
+	SalePriceNanos          uint64 `pg:",use_zero"`
+	IsBuyNow                bool   `pg:",use_zero"`
+	CreatorPublicKey        string `bun:",nullzero"`
+	CreatorRoyaltyNanos     uint64 `pg:",use_zero"`
+	CreatorCoinRoyaltyNanos uint64 `pg:",use_zero"`
+	// Maps of additional royalty recipient public keys to the royalty paid to them, in nanos.
+	AdditionalDesoRoyalties map[string]uint64 `bun:"type:jsonb"`
+	AdditionalCoinRoyalties map[string]uint64 `bun:"type:jsonb"`
+	BlockHash               string            `pg:",use_zero"`
+	BlockHeight             uint64            `pg:",use_zero"`
+	Timestamp               time.Time         `pg:",use_zero"`
+}
+
+type PGNftSale struct {
+	bun.BaseModel `bun:"table:nft_sale"`
+	NftSale
+}
+
+// AcceptNftBidTxindexMetadataToPGStruct converts the txindex metadata of an accept nft bid transaction to the
+// NftSale struct used by bun. The seller is the transactor, the buyer is the bidder whose bid was accepted.
+func AcceptNftBidTxindexMetadataToPGStruct(
+	acceptNftBidMetadata *lib.AcceptNFTBidTxindexMetadata,
+	bidderPublicKey string,
+	transaction *PGTransactionEntry,
+) NftSale {
+	nftSale := NftSale{
+		TransactionHash: transaction.TransactionHash,
+		NftPostHash:     acceptNftBidMetadata.NFTPostHashHex,
+		SerialNumber:    acceptNftBidMetadata.SerialNumber,
+		SellerPublicKey: transaction.PublicKey,
+		BuyerPublicKey:  bidderPublicKey,
+		SalePriceNanos:  acceptNftBidMetadata.BidAmountNanos,
+		IsBuyNow:        false,
+		BlockHash:       transaction.BlockHash,
+		BlockHeight:     transaction.BlockHeight,
+		Timestamp:       transaction.Timestamp,
+	}
+	setNftSaleRoyalties(&nftSale, acceptNftBidMetadata.NFTRoyaltiesMetadata)
+	return nftSale
+}
+
+// nftBidderPublicKey returns the public key of the bidder whose bid was accepted, which is only tracked as an affected
+// public key on the txindex metadata of an accept nft bid transaction.
+func nftBidderPublicKey(affectedPublicKeys []*lib.AffectedPublicKey) string {
+	for _, affectedPublicKey := range affectedPublicKeys {
+		if affectedPublicKey.Metadata == "NFTBidderPublicKeyBase58Check" {
+			return affectedPublicKey.PublicKeyBase58Check
+		}
+	}
+	return ""
+}
+
+// BuyNowNftBidTxindexMetadataToPGStruct converts the txindex metadata of a buy now nft bid transaction to the
+// NftSale struct used by bun. The buyer is the transactor, the seller is the owner of the nft at the time of the bid.
+func BuyNowNftBidTxindexMetadataToPGStruct(
+	nftBidMetadata *lib.NFTBidTxindexMetadata,
+	transaction *PGTransactionEntry,
+) NftSale {
+	nftSale := NftSale{
+		TransactionHash: transaction.TransactionHash,
+		NftPostHash:     nftBidMetadata.NFTPostHashHex,
+		SerialNumber:    nftBidMetadata.SerialNumber,
+		SellerPublicKey: nftBidMetadata.OwnerPublicKeyBase58Check,
+		BuyerPublicKey:  transaction.PublicKey,
+		SalePriceNanos:  nftBidMetadata.BidAmountNanos,
+		IsBuyNow:        true,
+		BlockHash:       transaction.BlockHash,
+		BlockHeight:     transaction.BlockHeight,
+		Timestamp:       transaction.Timestamp,
+	}
+	setNftSaleRoyalties(&nftSale, nftBidMetadata.NFTRoyaltiesMetadata)
+	return nftSale
+}
+
+func setNftSaleRoyalties(nftSale *NftSale, royaltiesMetadata *lib.NFTRoyaltiesMetadata) {
+	if royaltiesMetadata == nil {
+		return
+	}
+	nftSale.CreatorPublicKey = royaltiesMetadata.CreatorPublicKeyBase58Check
+	nftSale.CreatorRoyaltyNanos = royaltiesMetadata.CreatorRoyaltyNanos
+	nftSale.CreatorCoinRoyaltyNanos = royaltiesMetadata.CreatorCoinRoyaltyNanos
+	nftSale.AdditionalDesoRoyalties = royaltiesMetadata.AdditionalDESORoyaltiesMap
+	nftSale.AdditionalCoinRoyalties = royaltiesMetadata.AdditionalCoinRoyaltiesMap
+}
@@ -0,0 +1,92 @@
+package entries
+
+import (
+	"testing"
+	"time"
+
+	"github.com/deso-protocol/core/lib"
+	"github.com/stretchr/testify/require"
+)
+
+func testNftSaleTransaction(transactorPublicKey string) *PGTransactionEntry {
+	return &PGTransactionEntry{TransactionEntry: TransactionEntry{
+		TransactionHash: "txn",
+		PublicKey:       transactorPublicKey,
+		BlockHash:       "block",
+		BlockHeight:     100,
+		Timestamp:       time.Unix(1700000000, 0),
+	}}
+}
+
+func TestNftBidderPublicKey(t *testing.T) {
+	require.Equal(t, "", nftBidderPublicKey(nil))
+	require.Equal(t, "bidder", nftBidderPublicKey([]*lib.AffectedPublicKey{
+		{PublicKeyBase58Check: "seller", Metadata: "BasicTransferOutput"},
+		{PublicKeyBase58Check: "bidder", Metadata: "NFTBidderPublicKeyBase58Check"},
+		{PublicKeyBase58Check: "creator", Metadata: "NFTCreatorPublicKeyBase58Check"},
+	}))
+	require.Equal(t, "", nftBidderPublicKey([]*lib.AffectedPublicKey{
+		{PublicKeyBase58Check: "seller", Metadata: "BasicTransferOutput"},
+	}))
+}
+
+func TestAcceptNftBidTxindexMetadataToPGStruct(t *testing.T) {
+	transaction := testNftSaleTransaction("seller")
+	bidderPublicKey := nftBidderPublicKey([]*lib.AffectedPublicKey{
+		{PublicKeyBase58Check: "seller", Metadata: "BasicTransferOutput"},
+		{PublicKeyBase58Check: "bidder", Metadata: "NFTBidderPublicKeyBase58Check"},
+	})
+	nftSale := AcceptNftBidTxindexMetadataToPGStruct(&lib.AcceptNFTBidTxindexMetadata{
+		NFTPostHashHex: "post",
+		SerialNumber:   3,
+		BidAmountNanos: 1000,
+		NFTRoyaltiesMetadata: &lib.NFTRoyaltiesMetadata{
+			CreatorPublicKeyBase58Check: "creator",
+			CreatorRoyaltyNanos:         50,
+			CreatorCoinRoyaltyNanos:     20,
+			AdditionalDESORoyaltiesMap:  map[string]uint64{"other": 10},
+		},
+	}, bidderPublicKey, transaction)
+
+	require.Equal(t, NftSale{
+		TransactionHash:         "txn",
+		NftPostHash:             "post",
+		SerialNumber:            3,
+		SellerPublicKey:         "seller",
+		BuyerPublicKey:          "bidder",
+		SalePriceNanos:          1000,
+		IsBuyNow:                false,
+		CreatorPublicKey:        "creator",
+		CreatorRoyaltyNanos:     50,
+		CreatorCoinRoyaltyNanos: 20,
+		AdditionalDesoRoyalties: map[string]uint64{"other": 10},
+		BlockHash:               "block",
+		BlockHeight:             100,
+		Timestamp:               transaction.Timestamp,
+	}, nftSale)
+}
+
+func TestBuyNowNftBidTxindexMetadataToPGStruct(t *testing.T) {
+	transaction := testNftSaleTransaction("buyer")
+	nftSale := BuyNowNftBidTxindexMetadataToPGStruct(&lib.NFTBidTxindexMetadata{
+		NFTPostHashHex:            "post",
+		SerialNumber:              1,
+		BidAmountNanos:            2000,
+		IsBuyNowBid:               true,
+		OwnerPublicKeyBase58Check: "owner",
+	}, transaction)
+
+	// Without royalties metadata the creator and royalty columns are left empty.
+	require.Equal(t, NftSale{
+		TransactionHash: "txn",
+		NftPostHash:     "post",
+		SerialNumber:    1,
+		SellerPublicKey: "owner",
+		BuyerPublicKey:  "buyer",
+		SalePriceNanos:  2000,
+		IsBuyNow:        true,
+		BlockHash:       "block",
+		BlockHeight:     100,
+		Timestamp:       transaction.Timestamp,
+	}, nftSale)
+}
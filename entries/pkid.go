@@ -218,3 +218,27 @@ func bulkDeletePkid(entries []*lib.StateChangeEntry, db bun.IDB, operationType l
 
 	return nil
 }
+
+// getPkidsByPublicKey returns a map of public key to the PKID currently associated with it. Public keys without a
+// PKID entry have never been swapped, so their PKID is the public key itself.
+func getPkidsByPublicKey(db bun.IDB, publicKeys []string) (map[string]string, error) {
+	pkids := make(map[string]string)
+	if len(publicKeys) == 0 {
+		return pkids, nil
+	}
+	for _, publicKey := range publicKeys {
+		pkids[publicKey] = publicKey
+	}
+	var pgEntries []*PGPkidEntry
+	if err := db.NewSelect().
+		Model(&pgEntries).
+		Column("pkid", "public_key").
+		Where("public_key IN (?)", bun.In(publicKeys)).
+		Scan(context.Background()); err != nil {
+		return nil, errors.Wrapf(err, "entries.getPkidsByPublicKey: Error getting pkids")
+	}
+	for _, pgEntry := range pgEntries {
+		pkids[pgEntry.PublicKey] = pgEntry.Pkid
+	}
+	return pkids, nil
+}
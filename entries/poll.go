@@ -0,0 +1,235 @@
+package entries
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"github.com/uptrace/bun"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Polls are posts that declare their options in extra data. Votes are cast as post associations of the poll
+// response type, whose value is the text of the chosen option.
+const (
+	postExtraDataPollOptionsKey                     = "PollOptions"
+	postExtraDataPollWeightTypeKey                  = "PollWeightType"
+	postExtraDataPollWeightTokenProfilePublicKeyKey = "PollWeightTokenProfilePublicKey"
+	postExtraDataPollExpirationBlockHeightKey       = "PollExpirationBlockHeight"
+
+	PollResponseAssociationType = "POLL_RESPONSE"
+)
+
+// Poll is a post that declares poll options, along with the total number of votes counted for it.
+type Poll struct {
+	PostHash              string    `pg:",pk,use_zero"`
+	PosterPublicKey       string    `pg:",use_zero"`
+	WeightType            string    `bun:",nullzero"`
+	WeightTokenPublicKey  string    `bun:",nullzero"`
+	ExpirationBlockHeight uint64    `bun:",nullzero"`
+	TotalVotes            uint64    `pg:",use_zero"`
+	Timestamp             time.Time `pg:",use_zero"`
+}
+
+type PGPoll struct {
+	bun.BaseModel `bun:"table:poll"`
+	Poll
+}
+
+// PollOption is a single option of a poll, positioned in the order it was declared on the post.
+type PollOption struct {
+	PostHash   string `pg:",pk,use_zero"`
+	Position   uint64 `pg:",pk,use_zero"`
+	OptionText string `pg:",use_zero"`
+	VoteCount  uint64 `pg:",use_zero"`
+}
+
+type PGPollOption struct {
+	bun.BaseModel `bun:"table:poll_option"`
+	PollOption
+}
+
+// pollOptionsFromExtraData returns the options declared in a post's extra data, or nil if the post isn't a poll.
+// The options are stored as a JSON array of strings.
+func pollOptionsFromExtraData(extraData map[string]string) []string {
+	optionsJson, exists := extraData[postExtraDataPollOptionsKey]
+	if !exists {
+		return nil
+	}
+	var options []string
+	if err := json.Unmarshal([]byte(optionsJson), &options); err != nil {
+		return nil
+	}
+	var pollOptions []string
+	for _, option := range options {
+		if strings.TrimSpace(option) == "" {
+			continue
+		}
+		pollOptions = append(pollOptions, option)
+	}
+	return pollOptions
+}
+
+// PostEntryToPoll returns the poll declared by a post and its options. The first return value is nil if the post
+// isn't a poll.
+func PostEntryToPoll(postEntry *PostEntry) (*Poll, []*PollOption) {
+	options := pollOptionsFromExtraData(postEntry.ExtraData)
+	if len(options) == 0 {
+		return nil, nil
+	}
+	poll := &Poll{
+		PostHash:             postEntry.PostHash,
+		PosterPublicKey:      postEntry.PosterPublicKey,
+		WeightType:           postEntry.ExtraData[postExtraDataPollWeightTypeKey],
+		WeightTokenPublicKey: postEntry.ExtraData[postExtraDataPollWeightTokenProfilePublicKeyKey],
+		Timestamp:            postEntry.Timestamp,
+	}
+	if expirationBlockHeight, err := strconv.ParseUint(
+		strings.TrimSpace(postEntry.ExtraData[postExtraDataPollExpirationBlockHeightKey]), 10, 64); err == nil {
+		poll.ExpirationBlockHeight = expirationBlockHeight
+	}
+	pollOptions := make([]*PollOption, len(options))
+	for ii, option := range options {
+		pollOptions[ii] = &PollOption{
+			PostHash:   postEntry.PostHash,
+			Position:   uint64(ii),
+			OptionText: option,
+		}
+	}
+	return poll, pollOptions
+}
+
+// replacePolls re-extracts the polls of the given posts and recounts their votes. Hidden posts have no poll.
+// If deleteExisting is true, the previously stored polls of the posts are deleted first.
+func replacePolls(db bun.IDB, postEntries []*PostEntry, deleteExisting bool) error {
+	if len(postEntries) == 0 {
+		return nil
+	}
+	if deleteExisting {
+		postHashes := make([]string, len(postEntries))
+		for ii, postEntry := range postEntries {
+			postHashes[ii] = postEntry.PostHash
+		}
+		if err := deletePolls(db, postHashes); err != nil {
+			return errors.Wrapf(err, "entries.replacePolls: Error deleting existing polls")
+		}
+	}
+
+	var pgPollSlice []*PGPoll
+	var pgPollOptionSlice []*PGPollOption
+	var pollPostHashes []string
+	for _, postEntry := range postEntries {
+		if postEntry.IsHidden {
+			continue
+		}
+		poll, pollOptions := PostEntryToPoll(postEntry)
+		if poll == nil {
+			continue
+		}
+		pgPollSlice = append(pgPollSlice, &PGPoll{Poll: *poll})
+		for _, pollOption := range pollOptions {
+			pgPollOptionSlice = append(pgPollOptionSlice, &PGPollOption{PollOption: *pollOption})
+		}
+		pollPostHashes = append(pollPostHashes, poll.PostHash)
+	}
+	if len(pgPollSlice) == 0 {
+		return nil
+	}
+	if _, err := db.NewInsert().
+		Model(&pgPollSlice).
+		On("CONFLICT (post_hash) DO UPDATE").
+		Returning("").
+		Exec(context.Background()); err != nil {
+		return errors.Wrapf(err, "entries.replacePolls: Error inserting polls")
+	}
+	if _, err := db.NewInsert().
+		Model(&pgPollOptionSlice).
+		On("CONFLICT (post_hash, position) DO UPDATE").
+		Returning("").
+		Exec(context.Background()); err != nil {
+		return errors.Wrapf(err, "entries.replacePolls: Error inserting poll options")
+	}
+
+	// Responses can be stored before the poll itself, so the votes are counted once the poll exists.
+	if err := updatePollVoteCounts(db, pollPostHashes); err != nil {
+		return errors.Wrapf(err, "entries.replacePolls: Error updating vote counts")
+	}
+	return nil
+}
+
+// deletePolls deletes the stored polls of the given posts, along with their options.
+func deletePolls(db bun.IDB, postHashes []string) error {
+	if len(postHashes) == 0 {
+		return nil
+	}
+	if _, err := db.NewDelete().
+		Model(&PGPollOption{}).
+		Where("post_hash IN (?)", bun.In(postHashes)).
+		Returning("").
+		Exec(context.Background()); err != nil {
+		return errors.Wrapf(err, "entries.deletePolls: Error deleting poll options")
+	}
+	if _, err := db.NewDelete().
+		Model(&PGPoll{}).
+		Where("post_hash IN (?)", bun.In(postHashes)).
+		Returning("").
+		Exec(context.Background()); err != nil {
+		return errors.Wrapf(err, "entries.deletePolls: Error deleting polls")
+	}
+	return nil
+}
+
+// updatePollVoteCounts recounts the votes of the polls on the given posts. Each voter's earliest response counts
+// once, provided it names one of the poll's options and was cast before the poll expired. Posts without a poll
+// are ignored.
+func updatePollVoteCounts(db bun.IDB, postHashes []string) error {
+	if len(postHashes) == 0 {
+		return nil
+	}
+	if _, err := db.NewRaw(`
+		WITH poll_vote AS (
+			SELECT DISTINCT ON (pae.post_hash, pae.transactor_pkid) pae.post_hash, pae.association_value
+			FROM post_association_entry pae
+			JOIN poll ON poll.post_hash = pae.post_hash
+			WHERE pae.post_hash IN (?)
+			AND upper(pae.association_type) = ?
+			AND (poll.expiration_block_height IS NULL OR pae.block_height <= poll.expiration_block_height)
+			AND EXISTS (
+				SELECT 1 FROM poll_option
+				WHERE poll_option.post_hash = pae.post_hash AND poll_option.option_text = pae.association_value
+			)
+			ORDER BY pae.post_hash, pae.transactor_pkid, pae.block_height, pae.association_id
+		)
+		UPDATE poll_option
+		SET vote_count = (
+			SELECT count(*) FROM poll_vote
+			WHERE poll_vote.post_hash = poll_option.post_hash AND poll_vote.association_value = poll_option.option_text
+		)
+		WHERE poll_option.post_hash IN (?)`,
+		bun.In(postHashes), PollResponseAssociationType, bun.In(postHashes),
+	).Exec(context.Background()); err != nil {
+		return errors.Wrapf(err, "entries.updatePollVoteCounts: Error updating poll options")
+	}
+
+	// Options with the same text share their votes, so the total is counted from the votes rather than the options.
+	if _, err := db.NewRaw(`
+		UPDATE poll
+		SET total_votes = (
+			SELECT count(DISTINCT pae.transactor_pkid)
+			FROM post_association_entry pae
+			WHERE pae.post_hash = poll.post_hash
+			AND upper(pae.association_type) = ?
+			AND (poll.expiration_block_height IS NULL OR pae.block_height <= poll.expiration_block_height)
+			AND EXISTS (
+				SELECT 1 FROM poll_option
+				WHERE poll_option.post_hash = pae.post_hash AND poll_option.option_text = pae.association_value
+			)
+		)
+		WHERE poll.post_hash IN (?)`,
+		PollResponseAssociationType, bun.In(postHashes),
+	).Exec(context.Background()); err != nil {
+		return errors.Wrapf(err, "entries.updatePollVoteCounts: Error updating polls")
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package entries
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPollOptionsFromExtraData(t *testing.T) {
+	require.Nil(t, pollOptionsFromExtraData(nil))
+	require.Nil(t, pollOptionsFromExtraData(map[string]string{"Node": "1"}))
+	require.Nil(t, pollOptionsFromExtraData(map[string]string{postExtraDataPollOptionsKey: "not json"}))
+	require.Nil(t, pollOptionsFromExtraData(map[string]string{postExtraDataPollOptionsKey: `{"a": "b"}`}))
+	require.Nil(t, pollOptionsFromExtraData(map[string]string{postExtraDataPollOptionsKey: `["", "  "]`}))
+	require.Equal(t, []string{"Yes", " No "}, pollOptionsFromExtraData(map[string]string{
+		postExtraDataPollOptionsKey: `["Yes", "", " No "]`,
+	}))
+}
+
+func TestPostEntryToPoll(t *testing.T) {
+	timestamp := time.Unix(1700000000, 0)
+	poll, pollOptions := PostEntryToPoll(&PostEntry{
+		PostHash:        "post",
+		PosterPublicKey: "poster",
+		Timestamp:       timestamp,
+		ExtraData: map[string]string{
+			postExtraDataPollOptionsKey:                     `["Yes", "No"]`,
+			postExtraDataPollWeightTypeKey:                  "token",
+			postExtraDataPollWeightTokenProfilePublicKeyKey: "token_profile",
+			postExtraDataPollExpirationBlockHeightKey:       " 1234 ",
+		},
+	})
+	require.Equal(t, &Poll{
+		PostHash:              "post",
+		PosterPublicKey:       "poster",
+		WeightType:            "token",
+		WeightTokenPublicKey:  "token_profile",
+		ExpirationBlockHeight: 1234,
+		Timestamp:             timestamp,
+	}, poll)
+	require.Equal(t, []*PollOption{
+		{PostHash: "post", Position: 0, OptionText: "Yes"},
+		{PostHash: "post", Position: 1, OptionText: "No"},
+	}, pollOptions)
+
+	// An expiration block height that isn't a number is ignored.
+	poll, _ = PostEntryToPoll(&PostEntry{
+		PostHash: "post",
+		ExtraData: map[string]string{
+			postExtraDataPollOptionsKey:               `["Yes"]`,
+			postExtraDataPollExpirationBlockHeightKey: "soon",
+		},
+	})
+	require.NotNil(t, poll)
+	require.Equal(t, uint64(0), poll.ExpirationBlockHeight)
+
+	// Options and expiration block heights are trimmed of any unicode whitespace, and heights can be any uint64.
+	poll, pollOptions = PostEntryToPoll(&PostEntry{
+		PostHash: "post",
+		ExtraData: map[string]string{
+			postExtraDataPollOptionsKey:               `["\u00a0", "Yes"]`,
+			postExtraDataPollExpirationBlockHeightKey: "\u00a018446744073709551615\u2003",
+		},
+	})
+	require.Equal(t, uint64(18446744073709551615), poll.ExpirationBlockHeight)
+	require.Equal(t, []*PollOption{{PostHash: "post", Position: 0, OptionText: "Yes"}}, pollOptions)
+
+	poll, pollOptions = PostEntryToPoll(&PostEntry{PostHash: "post", ExtraData: map[string]string{}})
+	require.Nil(t, poll)
+	require.Nil(t, pollOptions)
+}
@@ -33,6 +33,9 @@ type PostEntry struct {
 	AdditionalNFTRoyaltiesToCoinsBasisPoints    map[string]uint64 `pg:"additional_nft_royalties_to_coins_basis_points,use_zero" bun:"type:jsonb"`
 	ExtraData                                   map[string]string `bun:"type:jsonb"`
 	IsFrozen                                    bool              `pg:",use_zero"`
+	IsPoll                                      bool              `pg:",use_zero"`
+	RootPostHash                                string            `bun:",nullzero"`
+	Depth                                       uint64            `pg:",use_zero"`
 	BadgerKey                                   []byte            `pg:",use_zero"`
 }
 
@@ -71,6 +74,15 @@ func PostEntryEncoderToPGStruct(postEntry *lib.PostEntry, keyBytes []byte, param
 		BadgerKey: keyBytes,
 	}
 
+	// The root post hash is the top-level post of the thread this post belongs to, and the depth is how many replies
+	// deep the post is in that thread. Replies get both from their parent once they're stored.
+	if pgPostEntry.ParentPostHash == "" {
+		pgPostEntry.RootPostHash = pgPostEntry.PostHash
+	}
+
+	// Polls are posts that declare their options in extra data.
+	pgPostEntry.IsPoll = len(pollOptionsFromExtraData(pgPostEntry.ExtraData)) > 0
+
 	if postEntry.RepostedPostHash != nil {
 		pgPostEntry.RepostedPostHash = hex.EncodeToString(postEntry.RepostedPostHash[:])
 	}
@@ -122,6 +134,45 @@ func bulkInsertPostEntry(entries []*lib.StateChangeEntry, db bun.IDB, operationT
 		}
 	}
 
+	postHashes := make([]string, len(pgEntrySlice))
+	for ii, pgEntry := range pgEntrySlice {
+		postHashes[ii] = pgEntry.PostHash
+	}
+
+	// Look up the current posts before they're overwritten, so that we can archive the content of edited posts and
+	// track changes in visibility. Posts can't already exist during the initial sync, so we skip the lookup there.
+	prevEntries := make(map[string]*PostEntry)
+	if operationType == lib.DbOperationTypeUpsert {
+		var err error
+		prevEntries, err = getPostEntriesByHash(db, postHashes)
+		if err != nil {
+			return errors.Wrapf(err, "entries.bulkInsertPostEntry: Error getting previous entries")
+		}
+	}
+	var pgRevisionSlice []*PGPostRevision
+	for ii, entry := range uniqueEntries {
+		prevEntry, exists := prevEntries[pgEntrySlice[ii].PostHash]
+		if !exists {
+			continue
+		}
+		if revision, isEdit := PostEntriesToRevision(prevEntry, &pgEntrySlice[ii].PostEntry, entry.BlockHeight); isEdit {
+			pgRevisionSlice = append(pgRevisionSlice, &PGPostRevision{PostRevision: revision})
+		}
+	}
+
+	// The thread structure is only recomputed for posts that are new or whose parent changed. Other posts keep the
+	// root post hash and depth already stored, rather than having them reset by the upsert.
+	var threadChangedPostHashes []string
+	for _, pgEntry := range pgEntrySlice {
+		prevEntry, exists := prevEntries[pgEntry.PostHash]
+		if exists && prevEntry.ParentPostHash == pgEntry.ParentPostHash {
+			pgEntry.RootPostHash = prevEntry.RootPostHash
+			pgEntry.Depth = prevEntry.Depth
+			continue
+		}
+		threadChangedPostHashes = append(threadChangedPostHashes, pgEntry.PostHash)
+	}
+
 	query := db.NewInsert().Model(&pgEntrySlice)
 
 	if operationType == lib.DbOperationTypeUpsert {
@@ -131,6 +182,117 @@ func bulkInsertPostEntry(entries []*lib.StateChangeEntry, db bun.IDB, operationT
 	if _, err := query.Returning("").Exec(context.Background()); err != nil {
 		return errors.Wrapf(err, "entries.bulkInsertPostEntry: Error inserting entries")
 	}
+
+	if err := bulkInsertPostRevisions(pgRevisionSlice, db); err != nil {
+		return errors.Wrapf(err, "entries.bulkInsertPostEntry: Error inserting post revisions")
+	}
+
+	// Re-extract the hashtags, mentions, media and polls of posts that are new, or whose content or visibility changed.
+	// Other upserts, such as like and diamond count changes, don't affect them.
+	var textChangedEntries []*PostEntry
+	var mediaChangedEntries []*PostEntry
+	for _, pgEntry := range pgEntrySlice {
+		prevEntry, exists := prevEntries[pgEntry.PostHash]
+		if !exists || prevEntry.IsHidden != pgEntry.IsHidden {
+			textChangedEntries = append(textChangedEntries, &pgEntry.PostEntry)
+			mediaChangedEntries = append(mediaChangedEntries, &pgEntry.PostEntry)
+			continue
+		}
+		if prevEntry.Body != pgEntry.Body {
+			textChangedEntries = append(textChangedEntries, &pgEntry.PostEntry)
+		}
+		if !stringSlicesEqual(prevEntry.ImageUrls, pgEntry.ImageUrls) ||
+			!stringSlicesEqual(prevEntry.VideoUrls, pgEntry.VideoUrls) ||
+			!stringMapsEqual(prevEntry.ExtraData, pgEntry.ExtraData) {
+			mediaChangedEntries = append(mediaChangedEntries, &pgEntry.PostEntry)
+		}
+	}
+	deleteExisting := operationType == lib.DbOperationTypeUpsert
+	if err := replacePostHashtags(db, textChangedEntries, deleteExisting); err != nil {
+		return errors.Wrapf(err, "entries.bulkInsertPostEntry: Error replacing hashtags")
+	}
+	if err := replacePostMentions(db, textChangedEntries, deleteExisting); err != nil {
+		return errors.Wrapf(err, "entries.bulkInsertPostEntry: Error replacing mentions")
+	}
+	if err := replacePostMedia(db, mediaChangedEntries, deleteExisting); err != nil {
+		return errors.Wrapf(err, "entries.bulkInsertPostEntry: Error replacing media")
+	}
+	// Polls are declared in extra data, so they change along with the media.
+	if err := replacePolls(db, mediaChangedEntries, deleteExisting); err != nil {
+		return errors.Wrapf(err, "entries.bulkInsertPostEntry: Error replacing polls")
+	}
+
+	// Store the post counts. The thread structure and reply counts aren't maintained during the initial sync,
+	// as posts arrive in no particular order. They're computed by a post sync migration once it's done.
+	pgStatsSlice := make([]*PGPostStats, len(uniqueEntries))
+	for ii, entry := range uniqueEntries {
+		pgStatsSlice[ii] = &PGPostStats{PostStats: PostEntryToPostStats(entry.Encoder.(*lib.PostEntry), pgEntrySlice[ii].PostHash)}
+	}
+	if operationType != lib.DbOperationTypeUpsert {
+		if err := bulkUpsertPostStats(db, pgStatsSlice, nil); err != nil {
+			return errors.Wrapf(err, "entries.bulkInsertPostEntry: Error upserting post stats")
+		}
+		return nil
+	}
+
+	if err := updatePostThreadStructure(db, threadChangedPostHashes); err != nil {
+		return errors.Wrapf(err, "entries.bulkInsertPostEntry: Error updating thread structure")
+	}
+
+	// Count the replies already stored below any new posts.
+	newPostHashSet := make(map[string]bool)
+	var newPostHashes []string
+	for _, pgEntry := range pgEntrySlice {
+		if _, exists := prevEntries[pgEntry.PostHash]; !exists {
+			newPostHashSet[pgEntry.PostHash] = true
+			newPostHashes = append(newPostHashes, pgEntry.PostHash)
+		}
+	}
+	if err := bulkUpsertPostStats(db, pgStatsSlice, newPostHashes); err != nil {
+		return errors.Wrapf(err, "entries.bulkInsertPostEntry: Error upserting post stats")
+	}
+	totalReplyCounts, err := getPostTotalReplyCounts(db, newPostHashes)
+	if err != nil {
+		return errors.Wrapf(err, "entries.bulkInsertPostEntry: Error getting total reply counts")
+	}
+
+	// Add new replies, and replies that were hidden or unhidden, to the total reply counts of their ancestors, and
+	// recount the direct replies of their parents. New posts may also have replies stored before them.
+	var replyCountDeltas []*PostReplyCountDelta
+	directReplyCountPostHashes := append([]string{}, newPostHashes...)
+	for _, pgEntry := range pgEntrySlice {
+		if pgEntry.ParentPostHash == "" {
+			continue
+		}
+		if newPostHashSet[pgEntry.PostHash] || prevEntries[pgEntry.PostHash].IsHidden != pgEntry.IsHidden {
+			directReplyCountPostHashes = append(directReplyCountPostHashes, pgEntry.ParentPostHash)
+		}
+		if newPostHashSet[pgEntry.PostHash] {
+			// New replies below a new post were already counted when that post's replies were counted.
+			if newPostHashSet[pgEntry.ParentPostHash] {
+				continue
+			}
+			delta := int64(totalReplyCounts[pgEntry.PostHash])
+			if !pgEntry.IsHidden {
+				delta++
+			}
+			if delta != 0 {
+				replyCountDeltas = append(replyCountDeltas, &PostReplyCountDelta{PostHash: pgEntry.PostHash, Delta: delta})
+			}
+		} else if prevEntry := prevEntries[pgEntry.PostHash]; prevEntry.IsHidden != pgEntry.IsHidden {
+			delta := int64(1)
+			if pgEntry.IsHidden {
+				delta = -1
+			}
+			replyCountDeltas = append(replyCountDeltas, &PostReplyCountDelta{PostHash: pgEntry.PostHash, Delta: delta})
+		}
+	}
+	if err := applyPostReplyCountDeltas(db, replyCountDeltas); err != nil {
+		return errors.Wrapf(err, "entries.bulkInsertPostEntry: Error updating total reply counts")
+	}
+	if err := updatePostDirectReplyCounts(db, directReplyCountPostHashes); err != nil {
+		return errors.Wrapf(err, "entries.bulkInsertPostEntry: Error updating direct reply counts")
+	}
 	return nil
 }
 
@@ -142,6 +304,46 @@ func bulkDeletePostEntry(entries []*lib.StateChangeEntry, db bun.IDB, operationT
 	// Transform the entries into a list of keys to delete.
 	keysToDelete := consumer.KeysToDelete(uniqueEntries)
 
+	// Look up the posts being deleted, so that they can be removed from the total reply counts of their ancestors.
+	var prevEntries []*PGPostEntry
+	if err := db.NewSelect().
+		Model(&prevEntries).
+		Column("post_hash", "parent_post_hash", "is_hidden").
+		Where("badger_key IN (?)", bun.In(keysToDelete)).
+		Scan(context.Background()); err != nil {
+		return errors.Wrapf(err, "entries.bulkDeletePostEntry: Error getting previous entries")
+	}
+	deletedPostHashSet := make(map[string]bool)
+	deletedPostHashes := make([]string, len(prevEntries))
+	for ii, prevEntry := range prevEntries {
+		deletedPostHashSet[prevEntry.PostHash] = true
+		deletedPostHashes[ii] = prevEntry.PostHash
+	}
+	totalReplyCounts, err := getPostTotalReplyCounts(db, deletedPostHashes)
+	if err != nil {
+		return errors.Wrapf(err, "entries.bulkDeletePostEntry: Error getting total reply counts")
+	}
+	var replyCountDeltas []*PostReplyCountDelta
+	var directReplyCountPostHashes []string
+	for _, prevEntry := range prevEntries {
+		// Replies below a deleted post are removed along with that post's own replies.
+		if prevEntry.ParentPostHash == "" || deletedPostHashSet[prevEntry.ParentPostHash] {
+			continue
+		}
+		directReplyCountPostHashes = append(directReplyCountPostHashes, prevEntry.ParentPostHash)
+		delta := -int64(totalReplyCounts[prevEntry.PostHash])
+		if !prevEntry.IsHidden {
+			delta--
+		}
+		if delta != 0 {
+			replyCountDeltas = append(replyCountDeltas, &PostReplyCountDelta{PostHash: prevEntry.PostHash, Delta: delta})
+		}
+	}
+	// The ancestors are found through the deleted posts, so the deltas are applied before the posts are deleted.
+	if err := applyPostReplyCountDeltas(db, replyCountDeltas); err != nil {
+		return errors.Wrapf(err, "entries.bulkDeletePostEntry: Error updating total reply counts")
+	}
+
 	// Execute the delete query.
 	if _, err := db.NewDelete().
 		Model(&PGPostEntry{}).
@@ -150,6 +352,32 @@ func bulkDeletePostEntry(entries []*lib.StateChangeEntry, db bun.IDB, operationT
 		Exec(context.Background()); err != nil {
 		return errors.Wrapf(err, "entries.bulkDeletePostEntry: Error deleting entries")
 	}
+	if err := updatePostDirectReplyCounts(db, directReplyCountPostHashes); err != nil {
+		return errors.Wrapf(err, "entries.bulkDeletePostEntry: Error updating direct reply counts")
+	}
+
+	if err := deletePostHashtags(db, deletedPostHashes); err != nil {
+		return errors.Wrapf(err, "entries.bulkDeletePostEntry: Error deleting hashtags")
+	}
+	if err := deletePostMentions(db, deletedPostHashes); err != nil {
+		return errors.Wrapf(err, "entries.bulkDeletePostEntry: Error deleting mentions")
+	}
+	if err := deletePostMedia(db, deletedPostHashes); err != nil {
+		return errors.Wrapf(err, "entries.bulkDeletePostEntry: Error deleting media")
+	}
+	if err := deletePolls(db, deletedPostHashes); err != nil {
+		return errors.Wrapf(err, "entries.bulkDeletePostEntry: Error deleting polls")
+	}
+
+	if len(deletedPostHashes) > 0 {
+		if _, err := db.NewDelete().
+			Model(&PGPostStats{}).
+			Where("post_hash IN (?)", bun.In(deletedPostHashes)).
+			Returning("").
+			Exec(context.Background()); err != nil {
+			return errors.Wrapf(err, "entries.bulkDeletePostEntry: Error deleting post stats")
+		}
+	}
 
 	return nil
 }
@@ -7,6 +7,7 @@ import (
 	"github.com/deso-protocol/state-consumer/consumer"
 	"github.com/pkg/errors"
 	"github.com/uptrace/bun"
+	"strings"
 )
 
 type PostAssociationEntry struct {
@@ -89,6 +90,25 @@ func bulkInsertPostAssociationEntry(entries []*lib.StateChangeEntry, db bun.IDB,
 		pgEntrySlice[ii] = &PGPostAssociationEntry{PostAssociationEntry: PostAssociationEncoderToPGStruct(entry.Encoder.(*lib.PostAssociationEntry), entry.KeyBytes, params)}
 	}
 
+	// Look up the associations being updated, so that their previous values can be removed from the counts.
+	// Associations can't already exist during the initial sync, so we skip the lookup there.
+	countDeltas := newAssociationCountDeltas()
+	if operationType == lib.DbOperationTypeUpsert {
+		var prevEntries []*PGPostAssociationEntry
+		if err := db.NewSelect().
+			Model(&prevEntries).
+			Where("badger_key IN (?)", bun.In(consumer.KeysToDelete(uniqueEntries))).
+			Scan(context.Background()); err != nil {
+			return errors.Wrapf(err, "entries.bulkInsertPostAssociationEntry: Error getting previous entries")
+		}
+		for _, prevEntry := range prevEntries {
+			countDeltas.add(prevEntry.PostHash, prevEntry.AssociationType, prevEntry.AssociationValue, prevEntry.AppPKID, -1)
+		}
+	}
+	for _, pgEntry := range pgEntrySlice {
+		countDeltas.add(pgEntry.PostHash, pgEntry.AssociationType, pgEntry.AssociationValue, pgEntry.AppPKID, 1)
+	}
+
 	query := db.NewInsert().Model(&pgEntrySlice)
 
 	if operationType == lib.DbOperationTypeUpsert {
@@ -98,6 +118,13 @@ func bulkInsertPostAssociationEntry(entries []*lib.StateChangeEntry, db bun.IDB,
 	if _, err := query.Returning("").Exec(context.Background()); err != nil {
 		return errors.Wrapf(err, "entries.bulkInsertPostAssociationEntry: Error inserting entries")
 	}
+
+	if err := applyAssociationCountDeltas(countDeltas, "post_association_count", "post_hash", db); err != nil {
+		return errors.Wrapf(err, "entries.bulkInsertPostAssociationEntry: Error updating association counts")
+	}
+	if err := updatePollVoteCounts(db, pollResponsePostHashes(pgEntrySlice)); err != nil {
+		return errors.Wrapf(err, "entries.bulkInsertPostAssociationEntry: Error updating poll vote counts")
+	}
 	return nil
 }
 
@@ -109,6 +136,15 @@ func bulkDeletePostAssociationEntry(entries []*lib.StateChangeEntry, db bun.IDB,
 	// Transform the entries into a list of keys to delete.
 	keysToDelete := consumer.KeysToDelete(uniqueEntries)
 
+	// Look up the associations being deleted, so that they can be removed from the counts.
+	var prevEntries []*PGPostAssociationEntry
+	if err := db.NewSelect().
+		Model(&prevEntries).
+		Where("badger_key IN (?)", bun.In(keysToDelete)).
+		Scan(context.Background()); err != nil {
+		return errors.Wrapf(err, "entries.bulkDeletePostAssociationEntry: Error getting previous entries")
+	}
+
 	// Execute the delete query.
 	if _, err := db.NewDelete().
 		Model(&PGPostAssociationEntry{}).
@@ -118,5 +154,30 @@ func bulkDeletePostAssociationEntry(entries []*lib.StateChangeEntry, db bun.IDB,
 		return errors.Wrapf(err, "entries.bulkDeletePostAssociationEntry: Error deleting entries")
 	}
 
+	countDeltas := newAssociationCountDeltas()
+	for _, prevEntry := range prevEntries {
+		countDeltas.add(prevEntry.PostHash, prevEntry.AssociationType, prevEntry.AssociationValue, prevEntry.AppPKID, -1)
+	}
+	if err := applyAssociationCountDeltas(countDeltas, "post_association_count", "post_hash", db); err != nil {
+		return errors.Wrapf(err, "entries.bulkDeletePostAssociationEntry: Error updating association counts")
+	}
+	if err := updatePollVoteCounts(db, pollResponsePostHashes(prevEntries)); err != nil {
+		return errors.Wrapf(err, "entries.bulkDeletePostAssociationEntry: Error updating poll vote counts")
+	}
+
 	return nil
 }
+
+// pollResponsePostHashes returns the unique post hashes of the poll responses among the given associations.
+func pollResponsePostHashes(pgEntrySlice []*PGPostAssociationEntry) []string {
+	postHashSet := make(map[string]bool)
+	var postHashes []string
+	for _, pgEntry := range pgEntrySlice {
+		if !strings.EqualFold(pgEntry.AssociationType, PollResponseAssociationType) || postHashSet[pgEntry.PostHash] {
+			continue
+		}
+		postHashSet[pgEntry.PostHash] = true
+		postHashes = append(postHashes, pgEntry.PostHash)
+	}
+	return postHashes
+}
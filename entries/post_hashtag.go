@@ -0,0 +1,100 @@
+package entries
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"github.com/uptrace/bun"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// PostHashtag is a hashtag used in the body of a post. Tags are stored lowercased, so that trending tags aren't
+// split by capitalization.
+type PostHashtag struct {
+	PostHash  string    `pg:",pk,use_zero"`
+	Tag       string    `pg:",pk,use_zero"`
+	Timestamp time.Time `pg:",use_zero"`
+}
+
+type PGPostHashtag struct {
+	bun.BaseModel `bun:"table:post_hashtag"`
+	PostHashtag
+}
+
+// A hashtag is a # followed by letters, numbers and underscores. The # must start the body or follow a character
+// that can't be part of a word, so that things like URL fragments and HTML entities aren't picked up.
+var hashtagRegex = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_&/])#([\p{L}\p{N}_]+)`)
+
+// ExtractHashtags returns the distinct lowercased hashtags in a post body, in the order they first appear.
+func ExtractHashtags(body string) []string {
+	var tags []string
+	tagsSeen := make(map[string]bool)
+	for _, match := range hashtagRegex.FindAllStringSubmatch(body, -1) {
+		tag := strings.ToLower(match[1])
+		// Skip tags that are only numbers, like "#1".
+		if strings.Trim(tag, "0123456789") == "" || tagsSeen[tag] {
+			continue
+		}
+		tagsSeen[tag] = true
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
+// replacePostHashtags replaces the stored hashtags of a batch of posts with the hashtags in their current bodies.
+// Hidden posts have their hashtags removed.
+func replacePostHashtags(db bun.IDB, postEntries []*PostEntry, deleteExisting bool) error {
+	if len(postEntries) == 0 {
+		return nil
+	}
+	if deleteExisting {
+		postHashes := make([]string, len(postEntries))
+		for ii, postEntry := range postEntries {
+			postHashes[ii] = postEntry.PostHash
+		}
+		if err := deletePostHashtags(db, postHashes); err != nil {
+			return errors.Wrapf(err, "entries.replacePostHashtags: Error deleting existing hashtags")
+		}
+	}
+
+	var pgHashtagSlice []*PGPostHashtag
+	for _, postEntry := range postEntries {
+		if postEntry.IsHidden {
+			continue
+		}
+		for _, tag := range ExtractHashtags(postEntry.Body) {
+			pgHashtagSlice = append(pgHashtagSlice, &PGPostHashtag{PostHashtag: PostHashtag{
+				PostHash:  postEntry.PostHash,
+				Tag:       tag,
+				Timestamp: postEntry.Timestamp,
+			}})
+		}
+	}
+	if len(pgHashtagSlice) == 0 {
+		return nil
+	}
+	if _, err := db.NewInsert().
+		Model(&pgHashtagSlice).
+		On("CONFLICT (post_hash, tag) DO UPDATE").
+		Returning("").
+		Exec(context.Background()); err != nil {
+		return errors.Wrapf(err, "entries.replacePostHashtags: Error inserting entries")
+	}
+	return nil
+}
+
+// deletePostHashtags deletes the stored hashtags of the given posts.
+func deletePostHashtags(db bun.IDB, postHashes []string) error {
+	if len(postHashes) == 0 {
+		return nil
+	}
+	if _, err := db.NewDelete().
+		Model(&PGPostHashtag{}).
+		Where("post_hash IN (?)", bun.In(postHashes)).
+		Returning("").
+		Exec(context.Background()); err != nil {
+		return errors.Wrapf(err, "entries.deletePostHashtags: Error deleting entries")
+	}
+	return nil
+}
@@ -0,0 +1,17 @@
+package entries
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractHashtags(t *testing.T) {
+	require.Nil(t, ExtractHashtags(""))
+	require.Nil(t, ExtractHashtags("no tags here"))
+	require.Equal(t, []string{"deso", "web3", "café"},
+		ExtractHashtags("#DeSo is #web3, (#Café) and #deso again"))
+
+	// Number-only tags, URL fragments and HTML entities aren't hashtags.
+	require.Nil(t, ExtractHashtags("#1 https://deso.com/#about &#39; word#tag"))
+}
@@ -0,0 +1,177 @@
+package entries
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"github.com/uptrace/bun"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	PostMediaTypeImage = "image"
+	PostMediaTypeVideo = "video"
+	// Embeds are links to media hosted on other sites, set through the EmbedVideoURL extra data key.
+	PostMediaTypeEmbed = "embed"
+)
+
+// Post extra data keys that describe a post's media. Dimensions and durations apply to the first media of their type.
+const (
+	postExtraDataEmbedVideoUrlKey = "EmbedVideoURL"
+	postExtraDataImageWidthKey    = "ImageWidth"
+	postExtraDataImageHeightKey   = "ImageHeight"
+	postExtraDataVideoWidthKey    = "VideoWidth"
+	postExtraDataVideoHeightKey   = "VideoHeight"
+	postExtraDataVideoDurationKey = "VideoDuration"
+)
+
+// PostMedia is a single image, video or embed attached to a post.
+type PostMedia struct {
+	PostHash        string    `pg:",pk,use_zero"`
+	MediaType       string    `pg:",pk,use_zero"`
+	Position        uint64    `pg:",pk,use_zero"`
+	Url             string    `pg:",use_zero"`
+	Host            string    `bun:",nullzero"`
+	IsDesoImage     bool      `pg:",use_zero"`
+	IsLivepeer      bool      `pg:",use_zero"`
+	IsYoutube       bool      `pg:",use_zero"`
+	Width           uint64    `bun:",nullzero"`
+	Height          uint64    `bun:",nullzero"`
+	DurationSeconds float64   `bun:",nullzero"`
+	PosterPublicKey string    `pg:",use_zero"`
+	Timestamp       time.Time `pg:",use_zero"`
+}
+
+type PGPostMedia struct {
+	bun.BaseModel `bun:"table:post_media"`
+	PostMedia
+}
+
+// PostEntryToPostMedia returns a row for each image, video and embed attached to a post, positioned in the order
+// they appear on the post.
+func PostEntryToPostMedia(postEntry *PostEntry) []*PostMedia {
+	var postMedia []*PostMedia
+	for ii, imageUrl := range postEntry.ImageUrls {
+		postMedia = append(postMedia, newPostMedia(postEntry, PostMediaTypeImage, uint64(ii), imageUrl))
+	}
+	for ii, videoUrl := range postEntry.VideoUrls {
+		postMedia = append(postMedia, newPostMedia(postEntry, PostMediaTypeVideo, uint64(ii), videoUrl))
+	}
+	if embedUrl := postEntry.ExtraData[postExtraDataEmbedVideoUrlKey]; embedUrl != "" {
+		postMedia = append(postMedia, newPostMedia(postEntry, PostMediaTypeEmbed, 0, embedUrl))
+	}
+
+	for _, media := range postMedia {
+		if media.Position != 0 {
+			continue
+		}
+		switch media.MediaType {
+		case PostMediaTypeImage:
+			media.Width = parseExtraDataUint(postEntry.ExtraData, postExtraDataImageWidthKey)
+			media.Height = parseExtraDataUint(postEntry.ExtraData, postExtraDataImageHeightKey)
+		case PostMediaTypeVideo:
+			media.Width = parseExtraDataUint(postEntry.ExtraData, postExtraDataVideoWidthKey)
+			media.Height = parseExtraDataUint(postEntry.ExtraData, postExtraDataVideoHeightKey)
+			media.DurationSeconds = parseExtraDataFloat(postEntry.ExtraData, postExtraDataVideoDurationKey)
+		}
+	}
+	return postMedia
+}
+
+func newPostMedia(postEntry *PostEntry, mediaType string, position uint64, mediaUrl string) *PostMedia {
+	postMedia := &PostMedia{
+		PostHash:        postEntry.PostHash,
+		MediaType:       mediaType,
+		Position:        position,
+		Url:             mediaUrl,
+		PosterPublicKey: postEntry.PosterPublicKey,
+		Timestamp:       postEntry.Timestamp,
+	}
+	parsedUrl, err := url.Parse(strings.TrimSpace(mediaUrl))
+	if err != nil {
+		return postMedia
+	}
+	host := strings.TrimPrefix(strings.ToLower(parsedUrl.Hostname()), "www.")
+	postMedia.Host = host
+	postMedia.IsDesoImage = host == "images.deso.org"
+	postMedia.IsLivepeer = host == "lvpr.tv" || hostMatchesDomain(host, "livepeer.studio") ||
+		hostMatchesDomain(host, "livepeercdn.com") || hostMatchesDomain(host, "livepeer.com")
+	postMedia.IsYoutube = host == "youtu.be" || hostMatchesDomain(host, "youtube.com")
+	return postMedia
+}
+
+// hostMatchesDomain returns true if the host is the domain or one of its subdomains.
+func hostMatchesDomain(host string, domain string) bool {
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+func parseExtraDataUint(extraData map[string]string, key string) uint64 {
+	value, err := strconv.ParseUint(strings.TrimSpace(extraData[key]), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return value
+}
+
+func parseExtraDataFloat(extraData map[string]string, key string) float64 {
+	value, err := strconv.ParseFloat(strings.TrimSpace(extraData[key]), 64)
+	if err != nil {
+		return 0
+	}
+	return value
+}
+
+// replacePostMedia replaces the stored media of a batch of posts with the media currently attached to them.
+// Hidden posts have their media removed.
+func replacePostMedia(db bun.IDB, postEntries []*PostEntry, deleteExisting bool) error {
+	if len(postEntries) == 0 {
+		return nil
+	}
+	if deleteExisting {
+		postHashes := make([]string, len(postEntries))
+		for ii, postEntry := range postEntries {
+			postHashes[ii] = postEntry.PostHash
+		}
+		if err := deletePostMedia(db, postHashes); err != nil {
+			return errors.Wrapf(err, "entries.replacePostMedia: Error deleting existing media")
+		}
+	}
+
+	var pgMediaSlice []*PGPostMedia
+	for _, postEntry := range postEntries {
+		if postEntry.IsHidden {
+			continue
+		}
+		for _, postMedia := range PostEntryToPostMedia(postEntry) {
+			pgMediaSlice = append(pgMediaSlice, &PGPostMedia{PostMedia: *postMedia})
+		}
+	}
+	if len(pgMediaSlice) == 0 {
+		return nil
+	}
+	if _, err := db.NewInsert().
+		Model(&pgMediaSlice).
+		On("CONFLICT (post_hash, media_type, position) DO UPDATE").
+		Returning("").
+		Exec(context.Background()); err != nil {
+		return errors.Wrapf(err, "entries.replacePostMedia: Error inserting entries")
+	}
+	return nil
+}
+
+// deletePostMedia deletes the stored media of the given posts.
+func deletePostMedia(db bun.IDB, postHashes []string) error {
+	if len(postHashes) == 0 {
+		return nil
+	}
+	if _, err := db.NewDelete().
+		Model(&PGPostMedia{}).
+		Where("post_hash IN (?)", bun.In(postHashes)).
+		Returning("").
+		Exec(context.Background()); err != nil {
+		return errors.Wrapf(err, "entries.deletePostMedia: Error deleting entries")
+	}
+	return nil
+}
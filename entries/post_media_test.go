@@ -0,0 +1,76 @@
+package entries
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostEntryToPostMedia(t *testing.T) {
+	timestamp := time.Unix(1700000000, 0)
+	require.Nil(t, PostEntryToPostMedia(&PostEntry{PostHash: "post"}))
+
+	postMedia := PostEntryToPostMedia(&PostEntry{
+		PostHash:        "post",
+		PosterPublicKey: "poster",
+		Timestamp:       timestamp,
+		ImageUrls:       []string{"https://images.deso.org/a.webp", "https://example.com/b.png"},
+		VideoUrls:       []string{"https://lvpr.tv/?v=abc"},
+		ExtraData: map[string]string{
+			postExtraDataEmbedVideoUrlKey: "https://www.YouTube.com/embed/abc",
+			postExtraDataImageWidthKey:    "640",
+			postExtraDataImageHeightKey:   "not a number",
+			postExtraDataVideoDurationKey: " 12.5 ",
+		},
+	})
+	require.Equal(t, []*PostMedia{
+		{
+			PostHash:        "post",
+			MediaType:       PostMediaTypeImage,
+			Position:        0,
+			Url:             "https://images.deso.org/a.webp",
+			Host:            "images.deso.org",
+			IsDesoImage:     true,
+			Width:           640,
+			PosterPublicKey: "poster",
+			Timestamp:       timestamp,
+		},
+		{
+			PostHash:        "post",
+			MediaType:       PostMediaTypeImage,
+			Position:        1,
+			Url:             "https://example.com/b.png",
+			Host:            "example.com",
+			PosterPublicKey: "poster",
+			Timestamp:       timestamp,
+		},
+		{
+			PostHash:        "post",
+			MediaType:       PostMediaTypeVideo,
+			Position:        0,
+			Url:             "https://lvpr.tv/?v=abc",
+			Host:            "lvpr.tv",
+			IsLivepeer:      true,
+			DurationSeconds: 12.5,
+			PosterPublicKey: "poster",
+			Timestamp:       timestamp,
+		},
+		{
+			PostHash:        "post",
+			MediaType:       PostMediaTypeEmbed,
+			Position:        0,
+			Url:             "https://www.YouTube.com/embed/abc",
+			Host:            "youtube.com",
+			IsYoutube:       true,
+			PosterPublicKey: "poster",
+			Timestamp:       timestamp,
+		},
+	}, postMedia)
+}
+
+func TestHostMatchesDomain(t *testing.T) {
+	require.True(t, hostMatchesDomain("livepeer.studio", "livepeer.studio"))
+	require.True(t, hostMatchesDomain("cdn.livepeer.studio", "livepeer.studio"))
+	require.False(t, hostMatchesDomain("notlivepeer.studio", "livepeer.studio"))
+}
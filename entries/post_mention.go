@@ -0,0 +1,170 @@
+package entries
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"github.com/uptrace/bun"
+	"regexp"
+	"strings"
+)
+
+// PostMention is an @username mention in the body of a post. The mentioned public key is resolved from the
+// profile with that username when the post is stored, and is null until a profile with that username exists.
+type PostMention struct {
+	PostHash           string `pg:",pk,use_zero"`
+	MentionedUsername  string `pg:",pk,use_zero"`
+	MentionedPublicKey string `bun:",nullzero"`
+}
+
+type PGPostMention struct {
+	bun.BaseModel `bun:"table:post_mention"`
+	PostMention
+}
+
+// A mention is an @ followed by a username, which is made up of letters, numbers and underscores. The @ must start
+// the body or follow a character that can't be part of a username, so that email addresses aren't picked up.
+var mentionRegex = regexp.MustCompile(`(?:^|[^A-Za-z0-9_])@([A-Za-z0-9_]{1,25})`)
+
+// ExtractMentionedUsernames returns the distinct usernames mentioned in a post body, in the order they first appear.
+// Usernames are case-insensitive, so only the first spelling of each is kept.
+func ExtractMentionedUsernames(body string) []string {
+	var usernames []string
+	usernamesSeen := make(map[string]bool)
+	for _, match := range mentionRegex.FindAllStringSubmatch(body, -1) {
+		if usernamesSeen[strings.ToLower(match[1])] {
+			continue
+		}
+		usernamesSeen[strings.ToLower(match[1])] = true
+		usernames = append(usernames, match[1])
+	}
+	return usernames
+}
+
+// getProfilesByLowercaseUsername returns the username and public key of the profiles with the given usernames,
+// keyed by lowercased username.
+func getProfilesByLowercaseUsername(db bun.IDB, usernames []string) (map[string]*ProfileEntry, error) {
+	profiles := make(map[string]*ProfileEntry)
+	if len(usernames) == 0 {
+		return profiles, nil
+	}
+	lowercaseUsernames := make([]string, len(usernames))
+	for ii, username := range usernames {
+		lowercaseUsernames[ii] = strings.ToLower(username)
+	}
+	var pgProfiles []*PGProfileEntry
+	if err := db.NewSelect().
+		Model(&pgProfiles).
+		Column("public_key", "username").
+		Where("LOWER(username) IN (?)", bun.In(lowercaseUsernames)).
+		Scan(context.Background()); err != nil {
+		return nil, errors.Wrapf(err, "entries.getProfilesByLowercaseUsername: Error getting profiles")
+	}
+	for _, pgProfile := range pgProfiles {
+		profiles[strings.ToLower(pgProfile.Username)] = &pgProfile.ProfileEntry
+	}
+	return profiles, nil
+}
+
+// replacePostMentions replaces the stored mentions of a batch of posts with the mentions in their current bodies.
+// Hidden posts have their mentions removed.
+func replacePostMentions(db bun.IDB, postEntries []*PostEntry, deleteExisting bool) error {
+	if len(postEntries) == 0 {
+		return nil
+	}
+	if deleteExisting {
+		postHashes := make([]string, len(postEntries))
+		for ii, postEntry := range postEntries {
+			postHashes[ii] = postEntry.PostHash
+		}
+		if err := deletePostMentions(db, postHashes); err != nil {
+			return errors.Wrapf(err, "entries.replacePostMentions: Error deleting existing mentions")
+		}
+	}
+
+	mentionedUsernamesByPost := make(map[string][]string)
+	var allMentionedUsernames []string
+	for _, postEntry := range postEntries {
+		if postEntry.IsHidden {
+			continue
+		}
+		mentionedUsernames := ExtractMentionedUsernames(postEntry.Body)
+		mentionedUsernamesByPost[postEntry.PostHash] = mentionedUsernames
+		allMentionedUsernames = append(allMentionedUsernames, mentionedUsernames...)
+	}
+	if len(allMentionedUsernames) == 0 {
+		return nil
+	}
+	profiles, err := getProfilesByLowercaseUsername(db, allMentionedUsernames)
+	if err != nil {
+		return errors.Wrapf(err, "entries.replacePostMentions: Error resolving usernames")
+	}
+
+	var pgMentionSlice []*PGPostMention
+	for _, postEntry := range postEntries {
+		for _, mentionedUsername := range mentionedUsernamesByPost[postEntry.PostHash] {
+			postMention := PostMention{
+				PostHash:          postEntry.PostHash,
+				MentionedUsername: mentionedUsername,
+			}
+			// Store the username as the profile spells it, so that mentions of the same profile group together.
+			if profile, exists := profiles[strings.ToLower(mentionedUsername)]; exists {
+				postMention.MentionedUsername = profile.Username
+				postMention.MentionedPublicKey = profile.PublicKey
+			}
+			pgMentionSlice = append(pgMentionSlice, &PGPostMention{PostMention: postMention})
+		}
+	}
+	if _, err := db.NewInsert().
+		Model(&pgMentionSlice).
+		On("CONFLICT (post_hash, mentioned_username) DO UPDATE").
+		Returning("").
+		Exec(context.Background()); err != nil {
+		return errors.Wrapf(err, "entries.replacePostMentions: Error inserting entries")
+	}
+	return nil
+}
+
+// resolvePostMentions resolves the unresolved mentions of the usernames of the given profiles, which were just
+// stored or renamed. Mentions that are already resolved keep pointing at the profile that held the username when
+// the post was stored.
+func resolvePostMentions(db bun.IDB, profileEntries []*ProfileEntry) error {
+	var mentionedProfiles []*PostMention
+	for _, profileEntry := range profileEntries {
+		if profileEntry.Username == "" {
+			continue
+		}
+		mentionedProfiles = append(mentionedProfiles, &PostMention{
+			MentionedUsername:  profileEntry.Username,
+			MentionedPublicKey: profileEntry.PublicKey,
+		})
+	}
+	if len(mentionedProfiles) == 0 {
+		return nil
+	}
+	if _, err := db.NewRaw(`
+		WITH _data (post_hash, mentioned_username, mentioned_public_key) AS (?)
+		UPDATE post_mention
+		SET mentioned_username = _data.mentioned_username, mentioned_public_key = _data.mentioned_public_key
+		FROM _data
+		WHERE LOWER(post_mention.mentioned_username) = LOWER(_data.mentioned_username)
+			AND post_mention.mentioned_public_key IS NULL
+	`, db.NewValues(&mentionedProfiles)).Exec(context.Background()); err != nil {
+		return errors.Wrapf(err, "entries.resolvePostMentions: Error updating mentions")
+	}
+	return nil
+}
+
+// deletePostMentions deletes the stored mentions of the given posts.
+func deletePostMentions(db bun.IDB, postHashes []string) error {
+	if len(postHashes) == 0 {
+		return nil
+	}
+	if _, err := db.NewDelete().
+		Model(&PGPostMention{}).
+		Where("post_hash IN (?)", bun.In(postHashes)).
+		Returning("").
+		Exec(context.Background()); err != nil {
+		return errors.Wrapf(err, "entries.deletePostMentions: Error deleting entries")
+	}
+	return nil
+}
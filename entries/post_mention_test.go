@@ -0,0 +1,16 @@
+package entries
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractMentionedUsernames(t *testing.T) {
+	require.Nil(t, ExtractMentionedUsernames(""))
+	require.Equal(t, []string{"Alice", "bob_2"},
+		ExtractMentionedUsernames("@Alice meet @bob_2, cc @alice"))
+
+	// Email addresses aren't mentions.
+	require.Nil(t, ExtractMentionedUsernames("mail me at alice@deso.com"))
+}
@@ -0,0 +1,201 @@
+package entries
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"github.com/deso-protocol/core/lib"
+	"github.com/deso-protocol/state-consumer/consumer"
+	"github.com/pkg/errors"
+	"github.com/uptrace/bun"
+	"reflect"
+	"time"
+)
+
+// PostRevision archives the content a post had before it was edited. Revision 1 is the original post, and each
+// edit archives the version it replaced under the next revision number. Rows are written by the post batch
+// operation, which compares the edited post against the stored one, and are then attributed by the utxo operation
+// parser to the transaction that made the edit.
+type PostRevision struct {
+	PostHash        string            `pg:",pk,use_zero"`
+	BlockHeight     uint64            `pg:",pk,use_zero"`
+	RevisionNumber  uint64            `bun:",nullzero"`
+	Body            string            `bun:",nullzero"`
+	ImageUrls       []string          `pg:",nullzero" bun:"type:varchar[]"`
+	VideoUrls       []string          `pg:",nullzero" bun:"type:varchar[]"`
+	ExtraData       map[string]string `bun:"type:jsonb"`
+	EditorPublicKey string            `bun:",nullzero"`
+	BlockHash       string            `bun:",nullzero"`
+	TxnHash         string            `bun:",nullzero"`
+	Timestamp       time.Time         `bun:",nullzero"`
+}
+
+type PGPostRevision struct {
+	bun.BaseModel `bun:"table:post_revision"`
+	PostRevision
+}
+
+// PostEntriesToRevision returns the revision archiving a post's previous content, if the post's body, media or
+// extra data changed. The second return value is false if the content is unchanged.
+func PostEntriesToRevision(prevEntry *PostEntry, newEntry *PostEntry, blockHeight uint64) (PostRevision, bool) {
+	if prevEntry.Body == newEntry.Body &&
+		stringSlicesEqual(prevEntry.ImageUrls, newEntry.ImageUrls) &&
+		stringSlicesEqual(prevEntry.VideoUrls, newEntry.VideoUrls) &&
+		stringMapsEqual(prevEntry.ExtraData, newEntry.ExtraData) {
+		return PostRevision{}, false
+	}
+	return PostRevision{
+		PostHash:    prevEntry.PostHash,
+		BlockHeight: blockHeight,
+		Body:        prevEntry.Body,
+		ImageUrls:   prevEntry.ImageUrls,
+		VideoUrls:   prevEntry.VideoUrls,
+		ExtraData:   prevEntry.ExtraData,
+	}, true
+}
+
+// stringSlicesEqual treats nil and empty slices as equal, since the two are indistinguishable once stored.
+func stringSlicesEqual(a []string, b []string) bool {
+	if len(a) == 0 && len(b) == 0 {
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// stringMapsEqual treats nil and empty maps as equal, since the two are indistinguishable once stored.
+func stringMapsEqual(a map[string]string, b map[string]string) bool {
+	if len(a) == 0 && len(b) == 0 {
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// getPostEntriesByHash returns the stored post entries with the given post hashes, keyed by post hash.
+func getPostEntriesByHash(db bun.IDB, postHashes []string) (map[string]*PostEntry, error) {
+	postEntries := make(map[string]*PostEntry)
+	if len(postHashes) == 0 {
+		return postEntries, nil
+	}
+	var pgEntries []*PGPostEntry
+	if err := db.NewSelect().
+		Model(&pgEntries).
+		Where("post_hash IN (?)", bun.In(postHashes)).
+		Scan(context.Background()); err != nil {
+		return nil, errors.Wrapf(err, "entries.getPostEntriesByHash: Error getting post entries")
+	}
+	for _, pgEntry := range pgEntries {
+		postEntries[pgEntry.PostHash] = &pgEntry.PostEntry
+	}
+	return postEntries, nil
+}
+
+// bulkInsertPostRevisions archives a batch of post revisions, numbering each after the latest revision already
+// stored for its post. If a post is edited more than once at the same block height, the earliest content is kept.
+func bulkInsertPostRevisions(pgRevisionSlice []*PGPostRevision, db bun.IDB) error {
+	if len(pgRevisionSlice) == 0 {
+		return nil
+	}
+	postHashes := make([]string, len(pgRevisionSlice))
+	for ii, pgRevision := range pgRevisionSlice {
+		postHashes[ii] = pgRevision.PostHash
+	}
+	var latestRevisions []struct {
+		PostHash       string
+		RevisionNumber uint64
+	}
+	if err := db.NewSelect().
+		Model((*PGPostRevision)(nil)).
+		Column("post_hash").
+		ColumnExpr("MAX(revision_number) AS revision_number").
+		Where("post_hash IN (?)", bun.In(postHashes)).
+		Where("revision_number IS NOT NULL").
+		Group("post_hash").
+		Scan(context.Background(), &latestRevisions); err != nil {
+		return errors.Wrapf(err, "entries.bulkInsertPostRevisions: Error getting latest revisions")
+	}
+	latestRevisionNumbers := make(map[string]uint64)
+	for _, latestRevision := range latestRevisions {
+		latestRevisionNumbers[latestRevision.PostHash] = latestRevision.RevisionNumber
+	}
+	for _, pgRevision := range pgRevisionSlice {
+		latestRevisionNumbers[pgRevision.PostHash]++
+		pgRevision.RevisionNumber = latestRevisionNumbers[pgRevision.PostHash]
+	}
+
+	if _, err := db.NewInsert().
+		Model(&pgRevisionSlice).
+		On("CONFLICT (post_hash, block_height) DO NOTHING").
+		Returning("").
+		Exec(context.Background()); err != nil {
+		return errors.Wrapf(err, "entries.bulkInsertPostRevisions: Error inserting entries")
+	}
+	return nil
+}
+
+// PostRevisionAttributionFromUtxoOps returns a post revision row linking an edit of a post's content to the
+// submit post transaction that made it. The second return value is false if the transaction didn't edit a post's
+// body or extra data, e.g. if it only hid the post.
+func PostRevisionAttributionFromUtxoOps(
+	txn *lib.MsgDeSoTxn,
+	utxoOps []*lib.UtxoOperation,
+	transaction *PGTransactionEntry,
+) (*PGPostRevision, bool) {
+	submitPostMetadata, ok := txn.TxnMeta.(*lib.SubmitPostMetadata)
+	if !ok || len(submitPostMetadata.PostHashToModify) == 0 {
+		return nil, false
+	}
+	submitPostUtxoOp := consumer.GetUtxoOpByOperationType(utxoOps, lib.OperationTypeSubmitPost)
+	if submitPostUtxoOp == nil || submitPostUtxoOp.PrevPostEntry == nil {
+		return nil, false
+	}
+	prevPostEntry := submitPostUtxoOp.PrevPostEntry
+	contentChanged := !bytes.Equal(prevPostEntry.Body, submitPostMetadata.Body)
+	// Edits merge the transaction's extra data into the post's existing extra data.
+	for key, value := range txn.ExtraData {
+		if !bytes.Equal(prevPostEntry.PostExtraData[key], value) {
+			contentChanged = true
+		}
+	}
+	if !contentChanged {
+		return nil, false
+	}
+	return &PGPostRevision{
+		PostRevision: PostRevision{
+			PostHash:        hex.EncodeToString(submitPostMetadata.PostHashToModify),
+			BlockHeight:     transaction.BlockHeight,
+			EditorPublicKey: transaction.PublicKey,
+			BlockHash:       transaction.BlockHash,
+			TxnHash:         transaction.TransactionHash,
+			Timestamp:       transaction.Timestamp,
+		},
+	}, true
+}
+
+// bulkInsertPostRevisionAttributions links post revisions to the block and transaction that made the edit. When
+// a post is edited more than once in a block, the revision is attributed to the last of the edits. Only revisions
+// already archived by the post batch operation are attributed, so an edit that the post batch operation didn't
+// consider a change of content never leaves a row without content.
+func bulkInsertPostRevisionAttributions(pgRevisionSlice []*PGPostRevision, db bun.IDB) error {
+	if len(pgRevisionSlice) == 0 {
+		return nil
+	}
+	dedupedRevisionSlice := latestAttributions(pgRevisionSlice, func(pgRevision *PGPostRevision) string {
+		return fmt.Sprintf("%v:%v", pgRevision.PostHash, pgRevision.BlockHeight)
+	}, nil)
+
+	if _, err := db.NewUpdate().
+		With("_data", db.NewValues(&dedupedRevisionSlice)).
+		Model((*PGPostRevision)(nil)).
+		TableExpr("_data").
+		Set("editor_public_key = _data.editor_public_key").
+		Set("block_hash = _data.block_hash").
+		Set("txn_hash = _data.txn_hash").
+		Set("timestamp = _data.timestamp").
+		Where("pg_post_revision.post_hash = _data.post_hash").
+		Where("pg_post_revision.block_height = _data.block_height").
+		Exec(context.Background()); err != nil {
+		return errors.Wrapf(err, "entries.bulkInsertPostRevisionAttributions: Error inserting entries")
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package entries
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostEntriesToRevision(t *testing.T) {
+	prevEntry := &PostEntry{
+		PostHash:  "post",
+		Body:      "original",
+		ImageUrls: []string{"https://images.deso.org/a.webp"},
+		ExtraData: map[string]string{"Node": "1"},
+	}
+
+	// Nil and empty media and extra data aren't treated as an edit.
+	_, ok := PostEntriesToRevision(&PostEntry{PostHash: "post", Body: "body", ImageUrls: []string{}},
+		&PostEntry{PostHash: "post", Body: "body", ExtraData: map[string]string{}}, 100)
+	require.False(t, ok)
+
+	unchangedEntry := *prevEntry
+	unchangedEntry.IsHidden = true
+	_, ok = PostEntriesToRevision(prevEntry, &unchangedEntry, 100)
+	require.False(t, ok)
+
+	editedEntry := *prevEntry
+	editedEntry.Body = "edited"
+	revision, ok := PostEntriesToRevision(prevEntry, &editedEntry, 100)
+	require.True(t, ok)
+	require.Equal(t, PostRevision{
+		PostHash:    "post",
+		BlockHeight: 100,
+		Body:        "original",
+		ImageUrls:   []string{"https://images.deso.org/a.webp"},
+		ExtraData:   map[string]string{"Node": "1"},
+	}, revision)
+
+	editedEntry = *prevEntry
+	editedEntry.ExtraData = map[string]string{"Node": "2"}
+	_, ok = PostEntriesToRevision(prevEntry, &editedEntry, 100)
+	require.True(t, ok)
+}
@@ -0,0 +1,192 @@
+package entries
+
+import (
+	"context"
+	"github.com/deso-protocol/core/lib"
+	"github.com/pkg/errors"
+	"github.com/uptrace/bun"
+)
+
+// PostStats holds the engagement counts of a post. The repost, quote repost, like and diamond counts are copied from
+// the post entry, which consensus keeps up to date. The direct reply count covers the visible replies to the post,
+// and the total reply count every visible reply in the post's thread below it. Both are counted from the stored
+// posts, so that they match the counts backfilled for existing posts, and are maintained as replies are inserted,
+// hidden or deleted.
+type PostStats struct {
+	PostHash         string `pg:",pk,use_zero"`
+	DirectReplyCount uint64 `pg:",use_zero"`
+	TotalReplyCount  uint64 `pg:",use_zero"`
+	RepostCount      uint64 `pg:",use_zero"`
+	QuoteRepostCount uint64 `pg:",use_zero"`
+	LikeCount        uint64 `pg:",use_zero"`
+	DiamondCount     uint64 `pg:",use_zero"`
+}
+
+type PGPostStats struct {
+	bun.BaseModel `bun:"table:post_stats"`
+	PostStats
+}
+
+// PostReplyCountDelta is a change in the number of visible replies below a post, to be applied to each of the
+// post's ancestors.
+type PostReplyCountDelta struct {
+	PostHash string
+	Delta    int64
+}
+
+// PostEntryToPostStats converts the counts of a post entry to the PostStats struct used by bun.
+func PostEntryToPostStats(postEntry *lib.PostEntry, postHash string) PostStats {
+	return PostStats{
+		PostHash:         postHash,
+		RepostCount:      postEntry.RepostCount,
+		QuoteRepostCount: postEntry.QuoteRepostCount,
+		LikeCount:        postEntry.LikeCount,
+		DiamondCount:     postEntry.DiamondCount,
+	}
+}
+
+// updatePostThreadStructure sets the root post hash and depth of a batch of posts from their parents, and then
+// pushes the result down to any replies to those posts that are already stored. A reply stored before its parent
+// keeps a null root post hash until the parent arrives.
+func updatePostThreadStructure(db bun.IDB, postHashes []string) error {
+	if len(postHashes) == 0 {
+		return nil
+	}
+	if _, err := db.NewRaw(`
+		UPDATE post_entry
+		SET root_post_hash = COALESCE(parent.root_post_hash, parent.post_hash), depth = parent.depth + 1
+		FROM post_entry AS parent
+		WHERE post_entry.post_hash IN (?) AND parent.post_hash = post_entry.parent_post_hash
+	`, bun.In(postHashes)).Exec(context.Background()); err != nil {
+		return errors.Wrapf(err, "entries.updatePostThreadStructure: Error updating posts from their parents")
+	}
+
+	// Posts whose parent is in the same batch are reached through their parent, so they aren't used as a starting
+	// point. Otherwise they could be updated twice with different values.
+	if _, err := db.NewRaw(`
+		WITH RECURSIVE descendants AS (
+			SELECT post_hash, root_post_hash, depth FROM post_entry
+			WHERE post_hash IN (?) AND (parent_post_hash IS NULL OR parent_post_hash NOT IN (?))
+			UNION ALL
+			SELECT child.post_hash, descendants.root_post_hash, descendants.depth + 1
+			FROM descendants JOIN post_entry AS child ON child.parent_post_hash = descendants.post_hash
+		)
+		UPDATE post_entry
+		SET root_post_hash = descendants.root_post_hash, depth = descendants.depth
+		FROM descendants
+		WHERE post_entry.post_hash = descendants.post_hash
+			AND (post_entry.root_post_hash IS DISTINCT FROM descendants.root_post_hash OR post_entry.depth <> descendants.depth)
+	`, bun.In(postHashes), bun.In(postHashes)).Exec(context.Background()); err != nil {
+		return errors.Wrapf(err, "entries.updatePostThreadStructure: Error updating replies")
+	}
+	return nil
+}
+
+// bulkUpsertPostStats stores the counts of a batch of posts. The reply counts of existing posts are left untouched,
+// as they're maintained from the stored replies. New posts get the number of visible replies already
+// stored below them, which is only non-zero if replies were stored before the post. Pass no new post hashes to skip
+// the count.
+func bulkUpsertPostStats(db bun.IDB, pgStatsSlice []*PGPostStats, newPostHashes []string) error {
+	if len(pgStatsSlice) == 0 {
+		return nil
+	}
+	if _, err := db.NewInsert().
+		Model(&pgStatsSlice).
+		On("CONFLICT (post_hash) DO UPDATE").
+		Set("repost_count = EXCLUDED.repost_count").
+		Set("quote_repost_count = EXCLUDED.quote_repost_count").
+		Set("like_count = EXCLUDED.like_count").
+		Set("diamond_count = EXCLUDED.diamond_count").
+		Returning("").
+		Exec(context.Background()); err != nil {
+		return errors.Wrapf(err, "entries.bulkUpsertPostStats: Error inserting entries")
+	}
+
+	if len(newPostHashes) == 0 {
+		return nil
+	}
+	if _, err := db.NewRaw(`
+		WITH RECURSIVE descendants AS (
+			SELECT post_entry.post_hash AS ancestor_post_hash, post_entry.post_hash, false AS is_hidden
+			FROM post_entry WHERE post_entry.post_hash IN (?)
+			UNION ALL
+			SELECT descendants.ancestor_post_hash, child.post_hash, COALESCE(child.is_hidden, false)
+			FROM descendants JOIN post_entry AS child ON child.parent_post_hash = descendants.post_hash
+		)
+		UPDATE post_stats
+		SET total_reply_count = reply_counts.total_reply_count
+		FROM (
+			SELECT ancestor_post_hash, COUNT(*) FILTER (WHERE post_hash <> ancestor_post_hash AND NOT is_hidden) AS total_reply_count
+			FROM descendants
+			GROUP BY ancestor_post_hash
+		) AS reply_counts
+		WHERE post_stats.post_hash = reply_counts.ancestor_post_hash
+	`, bun.In(newPostHashes)).Exec(context.Background()); err != nil {
+		return errors.Wrapf(err, "entries.bulkUpsertPostStats: Error counting replies of new posts")
+	}
+	return nil
+}
+
+// updatePostDirectReplyCounts recounts the visible replies directly below each of the given posts.
+func updatePostDirectReplyCounts(db bun.IDB, postHashes []string) error {
+	if len(postHashes) == 0 {
+		return nil
+	}
+	if _, err := db.NewRaw(`
+		UPDATE post_stats
+		SET direct_reply_count = (
+			SELECT COUNT(*) FROM post_entry
+			WHERE post_entry.parent_post_hash = post_stats.post_hash AND NOT COALESCE(post_entry.is_hidden, false)
+		)
+		WHERE post_stats.post_hash IN (?)
+	`, bun.In(postHashes)).Exec(context.Background()); err != nil {
+		return errors.Wrapf(err, "entries.updatePostDirectReplyCounts: Error updating direct reply counts")
+	}
+	return nil
+}
+
+// applyPostReplyCountDeltas adds each delta to the total reply count of every stored ancestor of its post.
+func applyPostReplyCountDeltas(db bun.IDB, deltas []*PostReplyCountDelta) error {
+	if len(deltas) == 0 {
+		return nil
+	}
+	if _, err := db.NewRaw(`
+		WITH RECURSIVE _data (post_hash, delta) AS (?),
+		ancestors AS (
+			SELECT post_entry.parent_post_hash AS post_hash, _data.delta
+			FROM _data JOIN post_entry ON post_entry.post_hash = _data.post_hash
+			WHERE post_entry.parent_post_hash IS NOT NULL
+			UNION ALL
+			SELECT post_entry.parent_post_hash, ancestors.delta
+			FROM ancestors JOIN post_entry ON post_entry.post_hash = ancestors.post_hash
+			WHERE post_entry.parent_post_hash IS NOT NULL
+		)
+		UPDATE post_stats
+		SET total_reply_count = GREATEST(post_stats.total_reply_count + ancestor_deltas.delta, 0)
+		FROM (SELECT post_hash, SUM(delta) AS delta FROM ancestors GROUP BY post_hash) AS ancestor_deltas
+		WHERE post_stats.post_hash = ancestor_deltas.post_hash
+	`, db.NewValues(&deltas)).Exec(context.Background()); err != nil {
+		return errors.Wrapf(err, "entries.applyPostReplyCountDeltas: Error updating total reply counts")
+	}
+	return nil
+}
+
+// getPostTotalReplyCounts returns the total reply count stored for each of the given posts.
+func getPostTotalReplyCounts(db bun.IDB, postHashes []string) (map[string]uint64, error) {
+	totalReplyCounts := make(map[string]uint64)
+	if len(postHashes) == 0 {
+		return totalReplyCounts, nil
+	}
+	var pgStatsSlice []*PGPostStats
+	if err := db.NewSelect().
+		Model(&pgStatsSlice).
+		Column("post_hash", "total_reply_count").
+		Where("post_hash IN (?)", bun.In(postHashes)).
+		Scan(context.Background()); err != nil {
+		return nil, errors.Wrapf(err, "entries.getPostTotalReplyCounts: Error getting post stats")
+	}
+	for _, pgStats := range pgStatsSlice {
+		totalReplyCounts[pgStats.PostHash] = pgStats.TotalReplyCount
+	}
+	return totalReplyCounts, nil
+}
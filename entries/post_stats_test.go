@@ -0,0 +1,25 @@
+package entries
+
+import (
+	"testing"
+
+	"github.com/deso-protocol/core/lib"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostEntryToPostStats(t *testing.T) {
+	// The reply counts are counted from the stored posts rather than copied from consensus.
+	require.Equal(t, PostStats{
+		PostHash:         "post",
+		RepostCount:      2,
+		QuoteRepostCount: 3,
+		LikeCount:        4,
+		DiamondCount:     5,
+	}, PostEntryToPostStats(&lib.PostEntry{
+		CommentCount:     1,
+		RepostCount:      2,
+		QuoteRepostCount: 3,
+		LikeCount:        4,
+		DiamondCount:     5,
+	}, "post"))
+}
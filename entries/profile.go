@@ -1,11 +1,15 @@
 package entries
 
 import (
+	"bytes"
 	"context"
+	"fmt"
 	"github.com/deso-protocol/core/lib"
 	"github.com/deso-protocol/state-consumer/consumer"
 	"github.com/pkg/errors"
 	"github.com/uptrace/bun"
+	"reflect"
+	"sort"
 )
 
 type ProfileEntry struct {
@@ -37,6 +41,26 @@ type PGProfileEntryUtxoOps struct {
 	UtxoOperation
 }
 
+// ProfileHistoryEntry records a change to the user-facing fields of a profile at a given block height.
+// Rows are written by the profile batch operation, which diffs the new profile against the stored one, and by the
+// utxo operation parser, which attributes the change to the transaction that caused it.
+type ProfileHistoryEntry struct {
+	PublicKey   string `pg:",pk,use_zero"`
+	BlockHeight uint64 `pg:",pk,use_zero"`
+	Pkid        string `bun:",nullzero"`
+	BlockHash   string `bun:",nullzero"`
+	TxnHash     string `bun:",nullzero"`
+	// The names of the profile_entry columns that changed, along with their values before and after the change.
+	ChangedFields  []string               `bun:",array"`
+	PreviousValues map[string]interface{} `bun:"type:jsonb"`
+	NewValues      map[string]interface{} `bun:"type:jsonb"`
+}
+
+type PGProfileHistoryEntry struct {
+	bun.BaseModel `bun:"table:profile_history"`
+	ProfileHistoryEntry
+}
+
 func ProfileEntryEncoderToPGStruct(profileEntry *lib.ProfileEntry, keyBytes []byte, params *lib.DeSoParams) ProfileEntry {
 	return ProfileEntry{
 		PublicKey:                        consumer.PublicKeyBytesToBase58Check(profileEntry.PublicKey, params),
@@ -87,6 +111,40 @@ func bulkInsertProfileEntry(entries []*lib.StateChangeEntry, db bun.IDB, operati
 		pgEntrySlice[ii] = &PGProfileEntry{ProfileEntry: ProfileEntryEncoderToPGStruct(entry.Encoder.(*lib.ProfileEntry), entry.KeyBytes, params)}
 	}
 
+	// Look up the current profiles before they're overwritten, so that we can record what changed.
+	// Profiles can't already exist during the initial sync, so we skip the history there.
+	var pgHistorySlice []*PGProfileHistoryEntry
+	var usernameChangedEntries []*ProfileEntry
+	if operationType == lib.DbOperationTypeUpsert {
+		publicKeys := make([]string, len(pgEntrySlice))
+		for ii, pgEntry := range pgEntrySlice {
+			publicKeys[ii] = pgEntry.PublicKey
+		}
+		var prevEntries []*PGProfileEntry
+		if err := db.NewSelect().
+			Model(&prevEntries).
+			Where("public_key IN (?)", bun.In(publicKeys)).
+			Scan(context.Background()); err != nil {
+			return errors.Wrapf(err, "entries.bulkInsertProfileEntry: Error getting previous profiles")
+		}
+		prevEntriesByPublicKey := make(map[string]*ProfileEntry)
+		for _, prevEntry := range prevEntries {
+			prevEntriesByPublicKey[prevEntry.PublicKey] = &prevEntry.ProfileEntry
+		}
+		for ii, entry := range uniqueEntries {
+			if prevEntry, exists := prevEntriesByPublicKey[pgEntrySlice[ii].PublicKey]; !exists ||
+				prevEntry.Username != pgEntrySlice[ii].Username {
+				usernameChangedEntries = append(usernameChangedEntries, &pgEntrySlice[ii].ProfileEntry)
+			}
+			historyEntry, hasChanges := ProfileEntriesToHistoryEntry(
+				prevEntriesByPublicKey[pgEntrySlice[ii].PublicKey], &pgEntrySlice[ii].ProfileEntry, entry.BlockHeight)
+			if !hasChanges {
+				continue
+			}
+			pgHistorySlice = append(pgHistorySlice, &PGProfileHistoryEntry{ProfileHistoryEntry: historyEntry})
+		}
+	}
+
 	query := db.NewInsert().Model(&pgEntrySlice)
 
 	if operationType == lib.DbOperationTypeUpsert {
@@ -96,6 +154,16 @@ func bulkInsertProfileEntry(entries []*lib.StateChangeEntry, db bun.IDB, operati
 	if _, err := query.Returning("").Exec(context.Background()); err != nil {
 		return errors.Wrapf(err, "entries.bulkInsertProfileEntry: Error inserting entries")
 	}
+
+	if err := bulkInsertProfileHistoryEntry(pgHistorySlice, db); err != nil {
+		return errors.Wrapf(err, "entries.bulkInsertProfileEntry: Error inserting profile history")
+	}
+
+	// Mentions of a username that was unclaimed when the post was stored are resolved once a profile claims it.
+	// During the initial sync, this is done by a post sync migration once all profiles are stored.
+	if err := resolvePostMentions(db, usernameChangedEntries); err != nil {
+		return errors.Wrapf(err, "entries.bulkInsertProfileEntry: Error resolving post mentions")
+	}
 	return nil
 }
 
@@ -118,3 +186,163 @@ func bulkDeleteProfileEntry(entries []*lib.StateChangeEntry, db bun.IDB, operati
 
 	return nil
 }
+
+// profileHistoryValues returns the user-facing fields of a profile that are tracked in the profile history, keyed
+// by column name. Coin state such as the deso locked and coins in circulation changes with every trade, so it's left
+// to the creator_coin_trade table. The profile pic is compared separately, as it can be too large to copy.
+func profileHistoryValues(profileEntry *ProfileEntry) map[string]interface{} {
+	return map[string]interface{}{
+		"username":                             profileEntry.Username,
+		"description":                          profileEntry.Description,
+		"creator_basis_points":                 profileEntry.CreatorBasisPoints,
+		"minting_disabled":                     profileEntry.MintingDisabled,
+		"dao_coin_minting_disabled":            profileEntry.DaoCoinMintingDisabled,
+		"dao_coin_transfer_restriction_status": profileEntry.DaoCoinTransferRestrictionStatus,
+		"extra_data":                           profileEntry.ExtraData,
+	}
+}
+
+// ProfileEntriesToHistoryEntry diffs a profile against its previous version, which is nil if the profile is new.
+// The second return value is false if none of the tracked fields changed.
+func ProfileEntriesToHistoryEntry(prevEntry *ProfileEntry, newEntry *ProfileEntry, blockHeight uint64) (ProfileHistoryEntry, bool) {
+	historyEntry := ProfileHistoryEntry{
+		PublicKey:      newEntry.PublicKey,
+		BlockHeight:    blockHeight,
+		Pkid:           newEntry.Pkid,
+		ChangedFields:  []string{},
+		PreviousValues: make(map[string]interface{}),
+		NewValues:      make(map[string]interface{}),
+	}
+	newValues := profileHistoryValues(newEntry)
+	if prevEntry == nil {
+		// Record every field that was set when the profile was created.
+		for field, newValue := range newValues {
+			if reflect.ValueOf(newValue).IsZero() {
+				continue
+			}
+			historyEntry.ChangedFields = append(historyEntry.ChangedFields, field)
+			historyEntry.NewValues[field] = newValue
+		}
+		if len(newEntry.ProfilePic) > 0 {
+			historyEntry.ChangedFields = append(historyEntry.ChangedFields, "profile_pic")
+		}
+	} else {
+		prevValues := profileHistoryValues(prevEntry)
+		for field, newValue := range newValues {
+			if reflect.DeepEqual(normalizeProfileHistoryValue(prevValues[field]), normalizeProfileHistoryValue(newValue)) {
+				continue
+			}
+			historyEntry.ChangedFields = append(historyEntry.ChangedFields, field)
+			historyEntry.PreviousValues[field] = prevValues[field]
+			historyEntry.NewValues[field] = newValue
+		}
+		if !bytes.Equal(prevEntry.ProfilePic, newEntry.ProfilePic) {
+			historyEntry.ChangedFields = append(historyEntry.ChangedFields, "profile_pic")
+		}
+	}
+	// Map iteration order is random, so sort the fields to keep rows stable.
+	sort.Strings(historyEntry.ChangedFields)
+	return historyEntry, len(historyEntry.ChangedFields) > 0
+}
+
+// normalizeProfileHistoryValue treats an empty extra data map the same as a missing one, since the two are
+// indistinguishable once stored.
+func normalizeProfileHistoryValue(value interface{}) interface{} {
+	if extraData, ok := value.(map[string]string); ok && len(extraData) == 0 {
+		return nil
+	}
+	return value
+}
+
+// bulkInsertProfileHistoryEntry appends changes to the profile history. If a profile changes more than once at the
+// same block height, the changes are merged into a single row that keeps the earliest previous values and the
+// latest new values.
+func bulkInsertProfileHistoryEntry(pgHistorySlice []*PGProfileHistoryEntry, db bun.IDB) error {
+	if len(pgHistorySlice) == 0 {
+		return nil
+	}
+	if _, err := db.NewInsert().
+		Model(&pgHistorySlice).
+		On("CONFLICT (public_key, block_height) DO UPDATE").
+		Set("pkid = EXCLUDED.pkid").
+		Set("changed_fields = ARRAY(SELECT DISTINCT unnest(?TableAlias.changed_fields || EXCLUDED.changed_fields) ORDER BY 1)").
+		Set("previous_values = EXCLUDED.previous_values || COALESCE(?TableAlias.previous_values, '{}'::jsonb)").
+		Set("new_values = COALESCE(?TableAlias.new_values, '{}'::jsonb) || EXCLUDED.new_values").
+		Returning("").
+		Exec(context.Background()); err != nil {
+		return errors.Wrapf(err, "entries.bulkInsertProfileHistoryEntry: Error inserting entries")
+	}
+	return nil
+}
+
+// ProfileHistoryAttributionFromUtxoOps returns a profile history row linking the profile changed by an update
+// profile transaction, or a dao coin transaction that changes the coin settings, to that transaction. The second
+// return value is false if the transaction didn't change a profile.
+func ProfileHistoryAttributionFromUtxoOps(
+	txn *lib.MsgDeSoTxn,
+	utxoOps []*lib.UtxoOperation,
+	transaction *PGTransactionEntry,
+	params *lib.DeSoParams,
+) (*PGProfileHistoryEntry, bool) {
+	var profilePublicKey []byte
+	switch txnMeta := txn.TxnMeta.(type) {
+	case *lib.UpdateProfileMetadata:
+		if consumer.GetUtxoOpByOperationType(utxoOps, lib.OperationTypeUpdateProfile) == nil {
+			return nil, false
+		}
+		profilePublicKey = txnMeta.ProfilePublicKey
+	case *lib.DAOCoinMetadata:
+		if txnMeta.OperationType != lib.DAOCoinOperationTypeDisableMinting &&
+			txnMeta.OperationType != lib.DAOCoinOperationTypeUpdateTransferRestrictionStatus {
+			return nil, false
+		}
+		if consumer.GetUtxoOpByOperationType(utxoOps, lib.OperationTypeDAOCoin) == nil {
+			return nil, false
+		}
+		profilePublicKey = txnMeta.ProfilePublicKey
+	default:
+		return nil, false
+	}
+	// An update profile transaction without a profile public key updates the transactor's own profile.
+	publicKey := transaction.PublicKey
+	if len(profilePublicKey) > 0 {
+		publicKey = consumer.PublicKeyBytesToBase58Check(profilePublicKey, params)
+	}
+	return &PGProfileHistoryEntry{
+		ProfileHistoryEntry: ProfileHistoryEntry{
+			PublicKey:      publicKey,
+			BlockHeight:    transaction.BlockHeight,
+			BlockHash:      transaction.BlockHash,
+			TxnHash:        transaction.TransactionHash,
+			ChangedFields:  []string{},
+			PreviousValues: make(map[string]interface{}),
+			NewValues:      make(map[string]interface{}),
+		},
+	}, true
+}
+
+// bulkInsertProfileHistoryAttributions links profile history rows to the block and transaction that caused them.
+// When several transactions in a block change the same profile, the row is attributed to the last of them.
+func bulkInsertProfileHistoryAttributions(pgHistorySlice []*PGProfileHistoryEntry, db bun.IDB) error {
+	if len(pgHistorySlice) == 0 {
+		return nil
+	}
+	dedupedHistorySlice := latestAttributions(pgHistorySlice, func(pgHistoryEntry *PGProfileHistoryEntry) string {
+		return fmt.Sprintf("%v:%v", pgHistoryEntry.PublicKey, pgHistoryEntry.BlockHeight)
+	}, nil)
+
+	// Only rows written by the profile batch operation are attributed, so a transaction that didn't change any field
+	// of the profile, such as an update that sets the same values, doesn't leave a row without changed fields.
+	if _, err := db.NewUpdate().
+		With("_data", db.NewValues(&dedupedHistorySlice)).
+		Model((*PGProfileHistoryEntry)(nil)).
+		TableExpr("_data").
+		Set("block_hash = _data.block_hash").
+		Set("txn_hash = _data.txn_hash").
+		Where("pg_profile_history_entry.public_key = _data.public_key").
+		Where("pg_profile_history_entry.block_height = _data.block_height").
+		Exec(context.Background()); err != nil {
+		return errors.Wrapf(err, "entries.bulkInsertProfileHistoryAttributions: Error inserting entries")
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package entries
+
+import (
+	"testing"
+
+	"github.com/deso-protocol/core/lib"
+	"github.com/deso-protocol/state-consumer/consumer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProfileEntriesToHistoryEntry(t *testing.T) {
+	prevEntry := &ProfileEntry{
+		PublicKey:          "public_key",
+		Pkid:               "pkid",
+		Username:           "alice",
+		Description:        "hello",
+		ProfilePic:         []byte("pic"),
+		CreatorBasisPoints: 1000,
+	}
+
+	// A new profile records every field that was set.
+	historyEntry, ok := ProfileEntriesToHistoryEntry(nil, prevEntry, 100)
+	require.True(t, ok)
+	require.Equal(t, ProfileHistoryEntry{
+		PublicKey:      "public_key",
+		BlockHeight:    100,
+		Pkid:           "pkid",
+		ChangedFields:  []string{"creator_basis_points", "description", "profile_pic", "username"},
+		PreviousValues: map[string]interface{}{},
+		NewValues: map[string]interface{}{
+			"creator_basis_points": uint64(1000),
+			"description":          "hello",
+			"username":             "alice",
+		},
+	}, historyEntry)
+
+	// Coin state isn't tracked, and an empty extra data map is the same as a missing one.
+	unchangedEntry := *prevEntry
+	unchangedEntry.DesoLockedNanos = 5000
+	unchangedEntry.ExtraData = map[string]string{}
+	_, ok = ProfileEntriesToHistoryEntry(prevEntry, &unchangedEntry, 101)
+	require.False(t, ok)
+
+	newEntry := *prevEntry
+	newEntry.Username = "alice2"
+	newEntry.ProfilePic = []byte("new pic")
+	historyEntry, ok = ProfileEntriesToHistoryEntry(prevEntry, &newEntry, 102)
+	require.True(t, ok)
+	require.Equal(t, []string{"profile_pic", "username"}, historyEntry.ChangedFields)
+	require.Equal(t, map[string]interface{}{"username": "alice"}, historyEntry.PreviousValues)
+	require.Equal(t, map[string]interface{}{"username": "alice2"}, historyEntry.NewValues)
+}
+
+func TestProfileHistoryAttributionFromUtxoOps(t *testing.T) {
+	params := &lib.DeSoMainnetParams
+	profilePublicKey := testPublicKeyBytes(2)
+	transaction := &PGTransactionEntry{TransactionEntry: TransactionEntry{
+		TransactionHash: "txn",
+		PublicKey:       consumer.PublicKeyBytesToBase58Check(testPublicKeyBytes(1), params),
+		BlockHash:       "block",
+		BlockHeight:     100,
+	}}
+	updateProfileUtxoOps := []*lib.UtxoOperation{{Type: lib.OperationTypeUpdateProfile}}
+
+	// An update profile transaction without a profile public key updates the transactor's own profile.
+	historyEntry, ok := ProfileHistoryAttributionFromUtxoOps(
+		&lib.MsgDeSoTxn{TxnMeta: &lib.UpdateProfileMetadata{}}, updateProfileUtxoOps, transaction, params)
+	require.True(t, ok)
+	require.Equal(t, transaction.PublicKey, historyEntry.PublicKey)
+	require.Equal(t, "txn", historyEntry.TxnHash)
+	require.Equal(t, "block", historyEntry.BlockHash)
+
+	historyEntry, ok = ProfileHistoryAttributionFromUtxoOps(
+		&lib.MsgDeSoTxn{TxnMeta: &lib.UpdateProfileMetadata{ProfilePublicKey: profilePublicKey}},
+		updateProfileUtxoOps, transaction, params)
+	require.True(t, ok)
+	require.Equal(t, consumer.PublicKeyBytesToBase58Check(profilePublicKey, params), historyEntry.PublicKey)
+
+	// Only dao coin operations that change the coin settings are attributed.
+	_, ok = ProfileHistoryAttributionFromUtxoOps(
+		&lib.MsgDeSoTxn{TxnMeta: &lib.DAOCoinMetadata{
+			ProfilePublicKey: profilePublicKey,
+			OperationType:    lib.DAOCoinOperationTypeDisableMinting,
+		}},
+		[]*lib.UtxoOperation{{Type: lib.OperationTypeDAOCoin}}, transaction, params)
+	require.True(t, ok)
+	_, ok = ProfileHistoryAttributionFromUtxoOps(
+		&lib.MsgDeSoTxn{TxnMeta: &lib.DAOCoinMetadata{
+			ProfilePublicKey: profilePublicKey,
+			OperationType:    lib.DAOCoinOperationTypeBurn,
+		}},
+		[]*lib.UtxoOperation{{Type: lib.OperationTypeDAOCoin}}, transaction, params)
+	require.False(t, ok)
+
+	_, ok = ProfileHistoryAttributionFromUtxoOps(
+		&lib.MsgDeSoTxn{TxnMeta: &lib.UpdateProfileMetadata{}},
+		[]*lib.UtxoOperation{{Type: lib.OperationTypeSpendBalance}}, transaction, params)
+	require.False(t, ok)
+}
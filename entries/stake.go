@@ -8,6 +8,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/uptrace/bun"
 	"github.com/uptrace/bun/extra/bunbig"
+	"math/big"
 )
 
 // TODO: when to use nullzero vs use_zero?
@@ -84,6 +85,17 @@ func bulkInsertStakeEntry(entries []*lib.StateChangeEntry, db bun.IDB, operation
 		pgEntrySlice[ii] = &PGStakeEntry{StakeEntry: StakeEncoderToPGStruct(entry.Encoder.(*lib.StakeEntry), entry.KeyBytes, params)}
 	}
 
+	// Look up the current stake entries before they're overwritten, so that we can record the change in stake.
+	// Stake entries can't already exist during the initial sync, so we skip the lookup there.
+	prevEntries := make(map[string]*PGStakeEntry)
+	if operationType == lib.DbOperationTypeUpsert {
+		var err error
+		prevEntries, err = getStakeEntriesByBadgerKey(db, consumer.KeysToDelete(uniqueEntries))
+		if err != nil {
+			return errors.Wrapf(err, "entries.bulkInsertStakeEntry: Error getting previous stake entries")
+		}
+	}
+
 	// Execute the insert query.
 	query := db.NewInsert().Model(&pgEntrySlice)
 
@@ -94,6 +106,27 @@ func bulkInsertStakeEntry(entries []*lib.StateChangeEntry, db bun.IDB, operation
 	if _, err := query.Returning("").Exec(context.Background()); err != nil {
 		return errors.Wrapf(err, "entries.bulkInsertStakeEntry: Error inserting entries")
 	}
+
+	// Append the stake positions that changed to the stake position history.
+	var pgHistorySlice []*PGStakePositionHistoryEntry
+	for ii, entry := range uniqueEntries {
+		amountBeforeNanos := big.NewInt(0)
+		prevEntry, exists := prevEntries[string(entry.KeyBytes)]
+		if exists {
+			amountBeforeNanos = prevEntry.StakeAmountNanos.ToMathBig()
+			if amountBeforeNanos.Cmp(pgEntrySlice[ii].StakeAmountNanos.ToMathBig()) == 0 &&
+				prevEntry.RewardMethod == pgEntrySlice[ii].RewardMethod {
+				continue
+			}
+		}
+		pgHistoryEntry := newStakePositionHistoryEntry(pgEntrySlice[ii].StakerPKID, pgEntrySlice[ii].ValidatorPKID,
+			StakePositionTypeStake, 0, entry.BlockHeight, amountBeforeNanos, pgEntrySlice[ii].StakeAmountNanos.ToMathBig())
+		pgHistoryEntry.RewardMethod = pgEntrySlice[ii].RewardMethod
+		pgHistorySlice = append(pgHistorySlice, pgHistoryEntry)
+	}
+	if err := bulkInsertStakePositionHistoryEntry(pgHistorySlice, db); err != nil {
+		return errors.Wrapf(err, "entries.bulkInsertStakeEntry: Error inserting stake position history")
+	}
 	return nil
 }
 
@@ -105,8 +138,14 @@ func bulkDeleteStakeEntry(entries []*lib.StateChangeEntry, db bun.IDB, operation
 	// Transform the entries into a list of keys to delete.
 	keysToDelete := consumer.KeysToDelete(uniqueEntries)
 
+	// Look up the stake entries being deleted, so that we can record their removal in the stake position history.
+	prevEntries, err := getStakeEntriesByBadgerKey(db, keysToDelete)
+	if err != nil {
+		return errors.Wrapf(err, "entries.bulkDeleteStakeEntry: Error getting previous stake entries")
+	}
+
 	// Execute the delete query.
-	if _, err := db.NewDelete().
+	if _, err = db.NewDelete().
 		Model(&PGStakeEntry{}).
 		Where("badger_key IN (?)", bun.In(keysToDelete)).
 		Returning("").
@@ -114,5 +153,41 @@ func bulkDeleteStakeEntry(entries []*lib.StateChangeEntry, db bun.IDB, operation
 		return errors.Wrapf(err, "entries.bulkDeleteStakeEntry: Error deleting entries")
 	}
 
+	// Record each deleted stake entry as an empty position at the height it was deleted.
+	var pgHistorySlice []*PGStakePositionHistoryEntry
+	for _, entry := range uniqueEntries {
+		prevEntry, exists := prevEntries[string(entry.KeyBytes)]
+		if !exists {
+			continue
+		}
+		pgHistoryEntry := newStakePositionHistoryEntry(prevEntry.StakerPKID, prevEntry.ValidatorPKID,
+			StakePositionTypeStake, 0, entry.BlockHeight, prevEntry.StakeAmountNanos.ToMathBig(), big.NewInt(0))
+		pgHistoryEntry.RewardMethod = prevEntry.RewardMethod
+		pgHistorySlice = append(pgHistorySlice, pgHistoryEntry)
+	}
+	if err = bulkInsertStakePositionHistoryEntry(pgHistorySlice, db); err != nil {
+		return errors.Wrapf(err, "entries.bulkDeleteStakeEntry: Error inserting stake position history")
+	}
+
 	return nil
 }
+
+// getStakeEntriesByBadgerKey returns a map of badger key to the stake entry currently stored for that key.
+func getStakeEntriesByBadgerKey(db bun.IDB, keys [][]byte) (map[string]*PGStakeEntry, error) {
+	stakeEntries := make(map[string]*PGStakeEntry)
+	if len(keys) == 0 {
+		return stakeEntries, nil
+	}
+	var pgEntries []*PGStakeEntry
+	if err := db.NewSelect().
+		Model(&pgEntries).
+		Column("badger_key", "staker_pkid", "validator_pkid", "reward_method", "stake_amount_nanos").
+		Where("badger_key IN (?)", bun.In(keys)).
+		Scan(context.Background()); err != nil {
+		return nil, errors.Wrapf(err, "entries.getStakeEntriesByBadgerKey: Error getting stake entries")
+	}
+	for _, pgEntry := range pgEntries {
+		stakeEntries[string(pgEntry.BadgerKey)] = pgEntry
+	}
+	return stakeEntries, nil
+}
@@ -0,0 +1,296 @@
+package entries
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/deso-protocol/backend/routes"
+	"github.com/deso-protocol/core/lib"
+	"github.com/deso-protocol/state-consumer/consumer"
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/extra/bunbig"
+)
+
+// A staker holds a stake position with each validator they stake to, along with a locked stake position for each
+// epoch they unstaked in. Unstaking moves stake into a locked position, and unlocking pays the locked stake out.
+const (
+	StakePositionTypeStake       = "stake"
+	StakePositionTypeLockedStake = "locked_stake"
+
+	StakePositionActionStake   = "stake"
+	StakePositionActionUnstake = "unstake"
+	StakePositionActionUnlock  = "unlock"
+	StakePositionActionRestake = "restake"
+)
+
+// StakePositionHistoryEntry records the amount of a stake position as of a given block height.
+// Rows are written by the stake and locked stake batch operations, which set the amounts, and by the utxo operation
+// parser, which attributes the change to the block and transaction that caused it.
+type StakePositionHistoryEntry struct {
+	StakerPKID    string `pg:",pk,use_zero"`
+	ValidatorPKID string `pg:",pk,use_zero"`
+	PositionType  string `pg:",pk,use_zero"`
+	// The epoch a locked stake position was locked at. Zero for stake positions.
+	LockedAtEpochNumber uint64 `pg:",pk,use_zero"`
+	BlockHeight         uint64 `pg:",pk,use_zero"`
+	Action              string `pg:",use_zero"`
+	// The reward method of a stake position. Empty for locked stake positions.
+	RewardMethod      routes.StakeRewardMethod `bun:",nullzero"`
+	AmountBeforeNanos *bunbig.Int
+	AmountAfterNanos  *bunbig.Int
+	DeltaNanos        *bunbig.Int
+	BlockHash         string `bun:",nullzero"`
+	TxnHash           string `bun:",nullzero"`
+}
+
+type PGStakePositionHistoryEntry struct {
+	bun.BaseModel `bun:"table:stake_position_history"`
+	StakePositionHistoryEntry
+}
+
+// stakePositionAction infers the action that changed a stake position from the sign of the change. Stake positions
+// grow by staking and shrink by unstaking, while locked stake positions grow by unstaking and shrink by unlocking.
+func stakePositionAction(positionType string, deltaNanos *big.Int) string {
+	if positionType == StakePositionTypeLockedStake {
+		if deltaNanos.Sign() < 0 {
+			return StakePositionActionUnlock
+		}
+		return StakePositionActionUnstake
+	}
+	if deltaNanos.Sign() < 0 {
+		return StakePositionActionUnstake
+	}
+	return StakePositionActionStake
+}
+
+// newStakePositionHistoryEntry returns the history row for a stake position changing from one amount to another.
+func newStakePositionHistoryEntry(
+	stakerPKID string,
+	validatorPKID string,
+	positionType string,
+	lockedAtEpochNumber uint64,
+	blockHeight uint64,
+	amountBeforeNanos *big.Int,
+	amountAfterNanos *big.Int,
+) *PGStakePositionHistoryEntry {
+	deltaNanos := big.NewInt(0).Sub(amountAfterNanos, amountBeforeNanos)
+	return &PGStakePositionHistoryEntry{StakePositionHistoryEntry: StakePositionHistoryEntry{
+		StakerPKID:          stakerPKID,
+		ValidatorPKID:       validatorPKID,
+		PositionType:        positionType,
+		LockedAtEpochNumber: lockedAtEpochNumber,
+		BlockHeight:         blockHeight,
+		Action:              stakePositionAction(positionType, deltaNanos),
+		AmountBeforeNanos:   bunbig.FromMathBig(amountBeforeNanos),
+		AmountAfterNanos:    bunbig.FromMathBig(amountAfterNanos),
+		DeltaNanos:          bunbig.FromMathBig(deltaNanos),
+	}}
+}
+
+// bulkInsertStakePositionHistoryEntry appends amounts to the stake position history. If a position changes more than
+// once at the same block height, the earliest amount before and the latest amount after are kept. The action is left
+// as first recorded, since the utxo operation parser sets it from the transaction.
+func bulkInsertStakePositionHistoryEntry(pgHistorySlice []*PGStakePositionHistoryEntry, db bun.IDB) error {
+	if len(pgHistorySlice) == 0 {
+		return nil
+	}
+	if _, err := db.NewInsert().
+		Model(&pgHistorySlice).
+		On("CONFLICT (staker_pkid, validator_pkid, position_type, locked_at_epoch_number, block_height) DO UPDATE").
+		Set("amount_before_nanos = COALESCE(?TableAlias.amount_before_nanos, EXCLUDED.amount_before_nanos)").
+		Set("amount_after_nanos = EXCLUDED.amount_after_nanos").
+		Set("delta_nanos = EXCLUDED.amount_after_nanos - COALESCE(?TableAlias.amount_before_nanos, EXCLUDED.amount_before_nanos)").
+		Set("reward_method = COALESCE(EXCLUDED.reward_method, ?TableAlias.reward_method)").
+		Returning("").
+		Exec(context.Background()); err != nil {
+		return errors.Wrapf(err, "entries.bulkInsertStakePositionHistoryEntry: Error inserting entries")
+	}
+	return nil
+}
+
+// stakePositionAttribution is a stake position history row attributed to a transaction, along with the public keys
+// that identify the position when its PKIDs aren't known from the utxo operations.
+type stakePositionAttribution struct {
+	StakePositionHistoryEntry
+	StakerPublicKey    string
+	ValidatorPublicKey string
+	// Unstaked stake is locked at the epoch the unstake happened in, which is resolved from the block height.
+	LockedAtBlockEpoch bool
+}
+
+// StakePositionAttributionsFromUtxoOps returns a stake position history row for each position changed by a stake,
+// unstake or unlock stake transaction, linking that change to the transaction and its block.
+func StakePositionAttributionsFromUtxoOps(
+	transaction *lib.MsgDeSoTxn,
+	utxoOps []*lib.UtxoOperation,
+	pgTransaction *PGTransactionEntry,
+	params *lib.DeSoParams,
+) []*stakePositionAttribution {
+	newAttribution := func(positionType string, action string) *stakePositionAttribution {
+		return &stakePositionAttribution{
+			StakePositionHistoryEntry: StakePositionHistoryEntry{
+				PositionType: positionType,
+				BlockHeight:  pgTransaction.BlockHeight,
+				Action:       action,
+				BlockHash:    pgTransaction.BlockHash,
+				TxnHash:      pgTransaction.TransactionHash,
+			},
+			StakerPublicKey: pgTransaction.PublicKey,
+		}
+	}
+
+	var attributions []*stakePositionAttribution
+	switch txnMeta := transaction.TxnMeta.(type) {
+	case *lib.StakeMetadata:
+		if txnMeta.ValidatorPublicKey == nil {
+			return nil
+		}
+		attribution := newAttribution(StakePositionTypeStake, StakePositionActionStake)
+		attribution.ValidatorPublicKey = consumer.PublicKeyBytesToBase58Check(txnMeta.ValidatorPublicKey.ToBytes(), params)
+		attributions = append(attributions, attribution)
+	case *lib.UnstakeMetadata:
+		if txnMeta.ValidatorPublicKey == nil {
+			return nil
+		}
+		validatorPublicKey := consumer.PublicKeyBytesToBase58Check(txnMeta.ValidatorPublicKey.ToBytes(), params)
+		stakeAttribution := newAttribution(StakePositionTypeStake, StakePositionActionUnstake)
+		stakeAttribution.ValidatorPublicKey = validatorPublicKey
+		lockedStakeAttribution := newAttribution(StakePositionTypeLockedStake, StakePositionActionUnstake)
+		lockedStakeAttribution.ValidatorPublicKey = validatorPublicKey
+		lockedStakeAttribution.LockedAtBlockEpoch = true
+		attributions = append(attributions, stakeAttribution, lockedStakeAttribution)
+	case *lib.UnlockStakeMetadata:
+		// The unlocked positions are the locked stake entries the transaction removed.
+		unlockStakeUtxoOp := consumer.GetUtxoOpByOperationType(utxoOps, lib.OperationTypeUnlockStake)
+		if unlockStakeUtxoOp == nil {
+			return nil
+		}
+		for _, prevLockedStakeEntry := range unlockStakeUtxoOp.PrevLockedStakeEntries {
+			if prevLockedStakeEntry == nil || prevLockedStakeEntry.StakerPKID == nil ||
+				prevLockedStakeEntry.ValidatorPKID == nil {
+				continue
+			}
+			attribution := newAttribution(StakePositionTypeLockedStake, StakePositionActionUnlock)
+			attribution.StakerPKID = consumer.PublicKeyBytesToBase58Check((*prevLockedStakeEntry.StakerPKID)[:], params)
+			attribution.ValidatorPKID = consumer.PublicKeyBytesToBase58Check(
+				(*prevLockedStakeEntry.ValidatorPKID)[:], params)
+			attribution.LockedAtEpochNumber = prevLockedStakeEntry.LockedAtEpochNumber
+			attributions = append(attributions, attribution)
+		}
+	}
+	return attributions
+}
+
+// StakePositionRestakeAttributionFromStakeReward returns the stake position history row for a stake reward that was
+// restaked at the end of a block. Restaked rewards aren't tied to a transaction.
+func StakePositionRestakeAttributionFromStakeReward(
+	stakeReward *StakeReward,
+	blockHeight uint64,
+) *stakePositionAttribution {
+	return &stakePositionAttribution{
+		StakePositionHistoryEntry: StakePositionHistoryEntry{
+			StakerPKID:    stakeReward.StakerPKID,
+			ValidatorPKID: stakeReward.ValidatorPKID,
+			PositionType:  StakePositionTypeStake,
+			BlockHeight:   blockHeight,
+			Action:        StakePositionActionRestake,
+			BlockHash:     stakeReward.BlockHash,
+		},
+	}
+}
+
+// stakePositionAttributionReplaces reports whether a later attribution of a stake position history row replaces an
+// earlier one. A restake at the end of a block doesn't replace the attribution of a transaction in the same block.
+func stakePositionAttributionReplaces(prev *PGStakePositionHistoryEntry, next *PGStakePositionHistoryEntry) bool {
+	return next.TxnHash != "" || prev.TxnHash == ""
+}
+
+// bulkInsertStakePositionAttributions links stake position history rows to the block and transaction that caused
+// them. When several transactions in a block change the same position, the row is attributed to the last of them.
+func bulkInsertStakePositionAttributions(attributions []*stakePositionAttribution, db bun.IDB) error {
+	if len(attributions) == 0 {
+		return nil
+	}
+
+	// Resolve the PKIDs of positions identified by public key, and the epochs of the attributed blocks for stake
+	// locked at the epoch it was unstaked in.
+	var publicKeys []string
+	var blockHeights []uint64
+	for _, attribution := range attributions {
+		if attribution.StakerPKID == "" && attribution.StakerPublicKey != "" {
+			publicKeys = append(publicKeys, attribution.StakerPublicKey)
+		}
+		if attribution.ValidatorPKID == "" && attribution.ValidatorPublicKey != "" {
+			publicKeys = append(publicKeys, attribution.ValidatorPublicKey)
+		}
+		if attribution.LockedAtBlockEpoch {
+			blockHeights = append(blockHeights, attribution.BlockHeight)
+		}
+	}
+	pkids, err := getPkidsByPublicKey(db, publicKeys)
+	if err != nil {
+		return errors.Wrapf(err, "entries.bulkInsertStakePositionAttributions: Error getting pkids")
+	}
+	epochNumbers, err := getEpochNumbersByBlockHeight(db, blockHeights)
+	if err != nil {
+		return errors.Wrapf(err, "entries.bulkInsertStakePositionAttributions: Error getting epoch numbers")
+	}
+
+	var pgHistorySlice []*PGStakePositionHistoryEntry
+	for _, attribution := range attributions {
+		pgHistoryEntry := &PGStakePositionHistoryEntry{StakePositionHistoryEntry: attribution.StakePositionHistoryEntry}
+		if pgHistoryEntry.StakerPKID == "" {
+			pgHistoryEntry.StakerPKID = pkids[attribution.StakerPublicKey]
+		}
+		if pgHistoryEntry.ValidatorPKID == "" {
+			pgHistoryEntry.ValidatorPKID = pkids[attribution.ValidatorPublicKey]
+		}
+		if attribution.LockedAtBlockEpoch {
+			epochNumber, exists := epochNumbers[attribution.BlockHeight]
+			if !exists {
+				glog.Warningf("entries.bulkInsertStakePositionAttributions: No epoch stored for block height %v, "+
+					"leaving the %v of staker %v unattributed", attribution.BlockHeight, attribution.Action,
+					pgHistoryEntry.StakerPKID)
+				continue
+			}
+			pgHistoryEntry.LockedAtEpochNumber = epochNumber
+		}
+		if pgHistoryEntry.StakerPKID == "" || pgHistoryEntry.ValidatorPKID == "" {
+			glog.Warningf("entries.bulkInsertStakePositionAttributions: Unknown staker or validator for the %v "+
+				"at block height %v, leaving it unattributed", attribution.Action, attribution.BlockHeight)
+			continue
+		}
+		pgHistorySlice = append(pgHistorySlice, pgHistoryEntry)
+	}
+	dedupedHistorySlice := latestAttributions(pgHistorySlice, func(pgHistoryEntry *PGStakePositionHistoryEntry) string {
+		return fmt.Sprintf("%v:%v:%v:%v:%v", pgHistoryEntry.StakerPKID, pgHistoryEntry.ValidatorPKID,
+			pgHistoryEntry.PositionType, pgHistoryEntry.LockedAtEpochNumber, pgHistoryEntry.BlockHeight)
+	}, stakePositionAttributionReplaces)
+	if len(dedupedHistorySlice) == 0 {
+		return nil
+	}
+
+	// The amounts are left untouched, as they are maintained by the stake and locked stake batch operations, and only
+	// rows those operations wrote are attributed. A transaction's attribution takes precedence over a restake in the
+	// same block, so a restake only sets the action of a row that isn't attributed to a transaction.
+	if _, err = db.NewUpdate().
+		With("_data", db.NewValues(&dedupedHistorySlice)).
+		Model((*PGStakePositionHistoryEntry)(nil)).
+		TableExpr("_data").
+		Set("action = CASE WHEN _data.txn_hash IS NULL AND pg_stake_position_history_entry.txn_hash IS NOT NULL " +
+			"THEN pg_stake_position_history_entry.action ELSE _data.action END").
+		Set("block_hash = _data.block_hash").
+		Set("txn_hash = COALESCE(_data.txn_hash, pg_stake_position_history_entry.txn_hash)").
+		Where("pg_stake_position_history_entry.staker_pkid = _data.staker_pkid").
+		Where("pg_stake_position_history_entry.validator_pkid = _data.validator_pkid").
+		Where("pg_stake_position_history_entry.position_type = _data.position_type").
+		Where("pg_stake_position_history_entry.locked_at_epoch_number = _data.locked_at_epoch_number").
+		Where("pg_stake_position_history_entry.block_height = _data.block_height").
+		Exec(context.Background()); err != nil {
+		return errors.Wrapf(err, "entries.bulkInsertStakePositionAttributions: Error updating entries")
+	}
+	return nil
+}
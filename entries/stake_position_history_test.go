@@ -0,0 +1,57 @@
+package entries
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStakePositionAction(t *testing.T) {
+	for _, testCase := range []struct {
+		positionType string
+		deltaNanos   int64
+		expected     string
+	}{
+		{StakePositionTypeStake, 100, StakePositionActionStake},
+		{StakePositionTypeStake, -100, StakePositionActionUnstake},
+		{StakePositionTypeStake, 0, StakePositionActionStake},
+		{StakePositionTypeLockedStake, 100, StakePositionActionUnstake},
+		{StakePositionTypeLockedStake, -100, StakePositionActionUnlock},
+		{StakePositionTypeLockedStake, 0, StakePositionActionUnstake},
+	} {
+		require.Equal(t, testCase.expected, stakePositionAction(testCase.positionType, big.NewInt(testCase.deltaNanos)),
+			"%v %v", testCase.positionType, testCase.deltaNanos)
+	}
+}
+
+func TestNewStakePositionHistoryEntry(t *testing.T) {
+	pgHistoryEntry := newStakePositionHistoryEntry(
+		"staker", "validator", StakePositionTypeLockedStake, 7, 100, big.NewInt(500), big.NewInt(200))
+	require.Equal(t, "staker", pgHistoryEntry.StakerPKID)
+	require.Equal(t, "validator", pgHistoryEntry.ValidatorPKID)
+	require.Equal(t, uint64(7), pgHistoryEntry.LockedAtEpochNumber)
+	require.Equal(t, uint64(100), pgHistoryEntry.BlockHeight)
+	require.Equal(t, StakePositionActionUnlock, pgHistoryEntry.Action)
+	require.Equal(t, int64(-300), pgHistoryEntry.DeltaNanos.ToMathBig().Int64())
+}
+
+func TestStakePositionAttributionReplaces(t *testing.T) {
+	stake := &PGStakePositionHistoryEntry{StakePositionHistoryEntry: StakePositionHistoryEntry{
+		Action: StakePositionActionStake, TxnHash: "txn1"}}
+	unstake := &PGStakePositionHistoryEntry{StakePositionHistoryEntry: StakePositionHistoryEntry{
+		Action: StakePositionActionUnstake, TxnHash: "txn2"}}
+	restake := &PGStakePositionHistoryEntry{StakePositionHistoryEntry: StakePositionHistoryEntry{
+		Action: StakePositionActionRestake}}
+
+	// A later transaction replaces an earlier one, and a transaction replaces a restake.
+	require.True(t, stakePositionAttributionReplaces(stake, unstake))
+	require.True(t, stakePositionAttributionReplaces(restake, stake))
+	require.True(t, stakePositionAttributionReplaces(restake, restake))
+	// A restake at the end of the block doesn't replace a transaction in it.
+	require.False(t, stakePositionAttributionReplaces(stake, restake))
+
+	rowKey := func(*PGStakePositionHistoryEntry) string { return "row" }
+	require.Equal(t, []*PGStakePositionHistoryEntry{unstake}, latestAttributions(
+		[]*PGStakePositionHistoryEntry{stake, unstake, restake}, rowKey, stakePositionAttributionReplaces))
+}
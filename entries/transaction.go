@@ -1,6 +1,7 @@
 package entries
 
 import (
+	"bytes"
 	"context"
 	"encoding/hex"
 	"fmt"
@@ -30,6 +31,7 @@ type TransactionEntry struct {
 	PublicKey                    string
 	ExtraData                    map[string]string `bun:"type:jsonb"`
 	Signature                    []byte
+	DerivedPublicKey             string `bun:",nullzero"`
 	IndexInBlock                 *uint64
 	BlockHeight                  uint64
 	Timestamp                    time.Time `pg:",use_zero"`
@@ -113,9 +115,38 @@ func TransactionEncoderToPGStruct(
 	if transaction.Signature.Sign != nil {
 		transactionEntry.Signature = transaction.Signature.ToBytes()
 	}
+
+	transactionEntry.DerivedPublicKey = getTransactionDerivedPublicKey(transaction, params)
 	return transactionEntry, nil
 }
 
+// getTransactionDerivedPublicKey returns the derived key that signed a transaction, or an empty string if the
+// transaction was signed by its owner. Derived keys either name themselves in the transaction's extra data, or sign
+// with a recoverable signature, in which case the signer is recovered from the signature.
+func getTransactionDerivedPublicKey(transaction *lib.MsgDeSoTxn, params *lib.DeSoParams) string {
+	if derivedPublicKey, exists := transaction.ExtraData[lib.DerivedPublicKey]; exists {
+		return consumer.PublicKeyBytesToBase58Check(derivedPublicKey, params)
+	}
+	if transaction.Signature.Sign == nil || !transaction.Signature.IsRecoverable {
+		return ""
+	}
+	// The signature covers the double sha256 hash of the transaction without its signature.
+	txnBytes, err := transaction.ToBytes(true)
+	if err != nil {
+		return ""
+	}
+	txnHash := lib.Sha256DoubleHash(txnBytes)
+	signerPublicKey, err := transaction.Signature.RecoverPublicKey(txnHash[:])
+	if err != nil {
+		return ""
+	}
+	signerPublicKeyBytes := signerPublicKey.SerializeCompressed()
+	if bytes.Equal(signerPublicKeyBytes, transaction.PublicKey) {
+		return ""
+	}
+	return consumer.PublicKeyBytesToBase58Check(signerPublicKeyBytes, params)
+}
+
 // TransactionBatchOperation is the entry point for processing a batch of transaction entries. It determines the appropriate handler
 // based on the operation type and executes it.
 func TransactionBatchOperation(entries []*lib.StateChangeEntry, db bun.IDB, params *lib.DeSoParams) error {
@@ -0,0 +1,25 @@
+package entries
+
+import (
+	"testing"
+
+	"github.com/deso-protocol/core/lib"
+	"github.com/deso-protocol/state-consumer/consumer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetTransactionDerivedPublicKey(t *testing.T) {
+	params := &lib.DeSoMainnetParams
+	ownerPublicKey := testPublicKeyBytes(1)
+	derivedPublicKey := testPublicKeyBytes(2)
+
+	// A transaction signed by its owner has no derived key.
+	require.Equal(t, "", getTransactionDerivedPublicKey(&lib.MsgDeSoTxn{PublicKey: ownerPublicKey}, params))
+
+	// A derived key named in the extra data is used without checking the signature.
+	require.Equal(t, consumer.PublicKeyBytesToBase58Check(derivedPublicKey, params),
+		getTransactionDerivedPublicKey(&lib.MsgDeSoTxn{
+			PublicKey: ownerPublicKey,
+			ExtraData: map[string][]byte{lib.DerivedPublicKey: derivedPublicKey},
+		}, params))
+}
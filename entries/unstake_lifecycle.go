@@ -0,0 +1,272 @@
+package entries
+
+import (
+	"context"
+	"time"
+
+	"github.com/deso-protocol/core/lib"
+	"github.com/deso-protocol/state-consumer/consumer"
+	"github.com/pkg/errors"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/extra/bunbig"
+)
+
+// Unstaked DESO is locked until the stake lockup duration has passed, after which the staker can claim it with an
+// unlock stake transaction.
+const (
+	UnstakeStatusLocked     = "locked"
+	UnstakeStatusUnlockable = "unlockable"
+	UnstakeStatusUnlocked   = "unlocked"
+)
+
+// UnstakeLifecycle tracks a single unstake from the moment its DESO is locked until it's unlocked.
+type UnstakeLifecycle struct {
+	UnstakeTxnHash string `pg:",pk,use_zero"`
+	StakerPKID     string `pg:",use_zero"`
+	ValidatorPKID  string `pg:",use_zero"`
+	// The epoch the unstaked DESO was locked at. Along with the staker and validator, this identifies the locked
+	// stake entry holding it, which is shared by all unstakes from the validator in the same epoch. The locked and
+	// unlockable epochs are null until the epoch containing the unstake is stored.
+	LockedAtEpochNumber     *uint64
+	UnstakeAmountNanos      *bunbig.Int `pg:",use_zero"`
+	UnlockableAtEpochNumber *uint64
+	Status                  string    `pg:",use_zero"`
+	UnstakeBlockHash        string    `pg:",use_zero"`
+	UnstakeBlockHeight      uint64    `pg:",use_zero"`
+	UnstakeTimestamp        time.Time `pg:",use_zero"`
+	UnlockTxnHash           string    `bun:",nullzero"`
+	UnlockBlockHash         string    `bun:",nullzero"`
+	UnlockBlockHeight       uint64    `bun:",nullzero"`
+	UnlockTimestamp         time.Time `bun:",nullzero"`
+}
+
+type PGUnstakeLifecycle struct {
+	bun.BaseModel `bun:"table:unstake_lifecycle"`
+	UnstakeLifecycle
+}
+
+// unstakeLifecycleAttribution is an unstake along with the public keys of the staker and validator, which are
+// resolved to PKIDs when it's stored.
+type unstakeLifecycleAttribution struct {
+	UnstakeLifecycle
+	StakerPublicKey    string
+	ValidatorPublicKey string
+}
+
+// stakeUnlock is a locked stake entry claimed by an unlock stake transaction.
+type stakeUnlock struct {
+	StakerPKID          string
+	ValidatorPKID       string
+	LockedAtEpochNumber uint64
+	TxnHash             string
+	BlockHash           string
+	BlockHeight         uint64
+	Timestamp           time.Time
+}
+
+// UnstakeLifecycleAttributionFromTxn returns the unstake made by an unstake transaction. The second return value is
+// false if the transaction isn't an unstake.
+func UnstakeLifecycleAttributionFromTxn(
+	transaction *lib.MsgDeSoTxn,
+	pgTransaction *PGTransactionEntry,
+	params *lib.DeSoParams,
+) (*unstakeLifecycleAttribution, bool) {
+	unstakeMetadata, ok := transaction.TxnMeta.(*lib.UnstakeMetadata)
+	if !ok || unstakeMetadata.ValidatorPublicKey == nil || unstakeMetadata.UnstakeAmountNanos == nil {
+		return nil, false
+	}
+	return &unstakeLifecycleAttribution{
+		UnstakeLifecycle: UnstakeLifecycle{
+			UnstakeTxnHash:     pgTransaction.TransactionHash,
+			UnstakeAmountNanos: bunbig.FromMathBig(unstakeMetadata.UnstakeAmountNanos.ToBig()),
+			Status:             UnstakeStatusLocked,
+			UnstakeBlockHash:   pgTransaction.BlockHash,
+			UnstakeBlockHeight: pgTransaction.BlockHeight,
+			UnstakeTimestamp:   pgTransaction.Timestamp,
+		},
+		StakerPublicKey:    pgTransaction.PublicKey,
+		ValidatorPublicKey: consumer.PublicKeyBytesToBase58Check(unstakeMetadata.ValidatorPublicKey.ToBytes(), params),
+	}, true
+}
+
+// StakeUnlocksFromUtxoOps returns the locked stake entries claimed by an unlock stake transaction.
+func StakeUnlocksFromUtxoOps(
+	utxoOps []*lib.UtxoOperation,
+	pgTransaction *PGTransactionEntry,
+	params *lib.DeSoParams,
+) []*stakeUnlock {
+	unlockStakeUtxoOp := consumer.GetUtxoOpByOperationType(utxoOps, lib.OperationTypeUnlockStake)
+	if unlockStakeUtxoOp == nil {
+		return nil
+	}
+	var stakeUnlocks []*stakeUnlock
+	for _, prevLockedStakeEntry := range unlockStakeUtxoOp.PrevLockedStakeEntries {
+		if prevLockedStakeEntry == nil || prevLockedStakeEntry.StakerPKID == nil ||
+			prevLockedStakeEntry.ValidatorPKID == nil {
+			continue
+		}
+		stakeUnlocks = append(stakeUnlocks, &stakeUnlock{
+			StakerPKID:          consumer.PublicKeyBytesToBase58Check((*prevLockedStakeEntry.StakerPKID)[:], params),
+			ValidatorPKID:       consumer.PublicKeyBytesToBase58Check((*prevLockedStakeEntry.ValidatorPKID)[:], params),
+			LockedAtEpochNumber: prevLockedStakeEntry.LockedAtEpochNumber,
+			TxnHash:             pgTransaction.TransactionHash,
+			BlockHash:           pgTransaction.BlockHash,
+			BlockHeight:         pgTransaction.BlockHeight,
+			Timestamp:           pgTransaction.Timestamp,
+		})
+	}
+	return stakeUnlocks
+}
+
+// bulkInsertUnstakeLifecycles stores the given unstakes. The epoch each one was locked at and the epoch it becomes
+// unlockable are filled in by resolveUnstakeLifecycleEpochs, once the epoch containing the unstake is stored.
+func bulkInsertUnstakeLifecycles(attributions []*unstakeLifecycleAttribution, db bun.IDB, params *lib.DeSoParams) error {
+	if len(attributions) == 0 {
+		return nil
+	}
+	publicKeys := make([]string, 0, 2*len(attributions))
+	for _, attribution := range attributions {
+		publicKeys = append(publicKeys, attribution.StakerPublicKey, attribution.ValidatorPublicKey)
+	}
+	pkids, err := getPkidsByPublicKey(db, publicKeys)
+	if err != nil {
+		return errors.Wrapf(err, "entries.bulkInsertUnstakeLifecycles: Error getting pkids")
+	}
+
+	pgUnstakeSlice := make([]*PGUnstakeLifecycle, len(attributions))
+	for ii, attribution := range attributions {
+		pgUnstake := &PGUnstakeLifecycle{UnstakeLifecycle: attribution.UnstakeLifecycle}
+		pgUnstake.StakerPKID = pkids[attribution.StakerPublicKey]
+		pgUnstake.ValidatorPKID = pkids[attribution.ValidatorPublicKey]
+		pgUnstakeSlice[ii] = pgUnstake
+	}
+
+	// The status and unlock are left untouched, as they're maintained as epochs pass and unlocks are made. The epochs
+	// are cleared, since the unstake's block may have changed, and resolved again below.
+	if _, err = db.NewInsert().
+		Model(&pgUnstakeSlice).
+		On("CONFLICT (unstake_txn_hash) DO UPDATE").
+		Set("staker_pkid = EXCLUDED.staker_pkid").
+		Set("validator_pkid = EXCLUDED.validator_pkid").
+		Set("locked_at_epoch_number = EXCLUDED.locked_at_epoch_number").
+		Set("unstake_amount_nanos = EXCLUDED.unstake_amount_nanos").
+		Set("unlockable_at_epoch_number = EXCLUDED.unlockable_at_epoch_number").
+		Set("unstake_block_hash = EXCLUDED.unstake_block_hash").
+		Set("unstake_block_height = EXCLUDED.unstake_block_height").
+		Set("unstake_timestamp = EXCLUDED.unstake_timestamp").
+		Returning("").
+		Exec(context.Background()); err != nil {
+		return errors.Wrapf(err, "entries.bulkInsertUnstakeLifecycles: Error inserting unstakes")
+	}
+
+	if err = resolveUnstakeLifecycleEpochs(db, params); err != nil {
+		return errors.Wrapf(err, "entries.bulkInsertUnstakeLifecycles: Error resolving epochs")
+	}
+	return nil
+}
+
+// applyStakeUnlocks marks the unstakes held in each claimed locked stake entry as unlocked by the claiming
+// transaction.
+func applyStakeUnlocks(stakeUnlocks []*stakeUnlock, db bun.IDB) error {
+	for _, unlock := range stakeUnlocks {
+		if _, err := db.NewUpdate().
+			Model((*PGUnstakeLifecycle)(nil)).
+			Set("status = ?", UnstakeStatusUnlocked).
+			Set("unlock_txn_hash = ?", unlock.TxnHash).
+			Set("unlock_block_hash = ?", unlock.BlockHash).
+			Set("unlock_block_height = ?", unlock.BlockHeight).
+			Set("unlock_timestamp = ?", unlock.Timestamp).
+			Where("staker_pkid = ?", unlock.StakerPKID).
+			Where("validator_pkid = ?", unlock.ValidatorPKID).
+			Where("locked_at_epoch_number = ?", unlock.LockedAtEpochNumber).
+			Where("unlock_txn_hash IS NULL").
+			Returning("").
+			Exec(context.Background()); err != nil {
+			return errors.Wrapf(err, "entries.applyStakeUnlocks: Error updating unstakes")
+		}
+	}
+	return nil
+}
+
+// resolveUnstakeLifecycleEpochs sets the epoch each unstake was locked at once the epoch containing it is stored,
+// and the epoch it becomes unlockable from the current stake lockup duration, falling back to the network default if
+// global params haven't been stored. Core checks the lockup duration when the stake is unlocked, so the unlockable
+// epoch of unstakes that haven't been unlocked yet is recomputed whenever the duration changes.
+func resolveUnstakeLifecycleEpochs(db bun.IDB, params *lib.DeSoParams) error {
+	if _, err := db.NewRaw(`
+		UPDATE unstake_lifecycle
+		SET locked_at_epoch_number = epoch_entry.epoch_number
+		FROM epoch_entry
+		WHERE unstake_lifecycle.locked_at_epoch_number IS NULL
+		AND unstake_lifecycle.unstake_block_height BETWEEN epoch_entry.initial_block_height AND epoch_entry.final_block_height
+	`).Exec(context.Background()); err != nil {
+		return errors.Wrapf(err, "entries.resolveUnstakeLifecycleEpochs: Error resolving locked epochs")
+	}
+
+	defaultStakeLockupEpochDuration := lib.MergeGlobalParamEntryDefaults(&lib.GlobalParamsEntry{}, params).StakeLockupEpochDuration
+	if _, err := db.NewRaw(`
+		UPDATE unstake_lifecycle
+		SET unlockable_at_epoch_number = unstake_lifecycle.locked_at_epoch_number + lockup.stake_lockup_epoch_duration
+		FROM (
+			SELECT COALESCE((SELECT stake_lockup_epoch_duration FROM global_params_entry LIMIT 1), ?) AS stake_lockup_epoch_duration
+		) AS lockup
+		WHERE unstake_lifecycle.locked_at_epoch_number IS NOT NULL
+		AND (unstake_lifecycle.status <> ? OR unstake_lifecycle.unlockable_at_epoch_number IS NULL)
+		AND unstake_lifecycle.unlockable_at_epoch_number IS DISTINCT FROM
+			unstake_lifecycle.locked_at_epoch_number + lockup.stake_lockup_epoch_duration
+	`, defaultStakeLockupEpochDuration, UnstakeStatusUnlocked).Exec(context.Background()); err != nil {
+		return errors.Wrapf(err, "entries.resolveUnstakeLifecycleEpochs: Error resolving unlockable epochs")
+	}
+
+	if err := updateUnstakeLifecycleStatuses(db); err != nil {
+		return errors.Wrapf(err, "entries.resolveUnstakeLifecycleEpochs: Error updating statuses")
+	}
+	return nil
+}
+
+// updateUnstakeLifecycleStatuses marks unstakes that haven't been unlocked as unlockable once the latest stored epoch
+// reaches the epoch they become unlockable, and as locked otherwise.
+func updateUnstakeLifecycleStatuses(db bun.IDB) error {
+	if _, err := db.NewRaw(`
+		UPDATE unstake_lifecycle
+		SET status = CASE WHEN unlockable_at_epoch_number <= (SELECT max(epoch_number) FROM epoch_entry) THEN ? ELSE ? END
+		WHERE status <> ?
+		AND status IS DISTINCT FROM
+			CASE WHEN unlockable_at_epoch_number <= (SELECT max(epoch_number) FROM epoch_entry) THEN ? ELSE ? END
+	`, UnstakeStatusUnlockable, UnstakeStatusLocked, UnstakeStatusUnlocked,
+		UnstakeStatusUnlockable, UnstakeStatusLocked).Exec(context.Background()); err != nil {
+		return errors.Wrapf(err, "entries.updateUnstakeLifecycleStatuses: Error updating statuses")
+	}
+	return nil
+}
+
+// deleteUnstakeLifecyclesForBlocks removes the unstakes made in the given blocks, and reverts the unlocks made in
+// them, so that the blocks can be replaced.
+func deleteUnstakeLifecyclesForBlocks(db bun.IDB, blockHashes []string) error {
+	if len(blockHashes) == 0 {
+		return nil
+	}
+	if _, err := db.NewDelete().
+		Model((*PGUnstakeLifecycle)(nil)).
+		Where("unstake_block_hash IN (?)", bun.In(blockHashes)).
+		Returning("").
+		Exec(context.Background()); err != nil {
+		return errors.Wrapf(err, "entries.deleteUnstakeLifecyclesForBlocks: Error deleting unstakes")
+	}
+	if _, err := db.NewUpdate().
+		Model((*PGUnstakeLifecycle)(nil)).
+		Set("status = ?", UnstakeStatusLocked).
+		Set("unlock_txn_hash = NULL").
+		Set("unlock_block_hash = NULL").
+		Set("unlock_block_height = NULL").
+		Set("unlock_timestamp = NULL").
+		Where("unlock_block_hash IN (?)", bun.In(blockHashes)).
+		Returning("").
+		Exec(context.Background()); err != nil {
+		return errors.Wrapf(err, "entries.deleteUnstakeLifecyclesForBlocks: Error reverting unlocks")
+	}
+	if err := updateUnstakeLifecycleStatuses(db); err != nil {
+		return errors.Wrapf(err, "entries.deleteUnstakeLifecyclesForBlocks: Error updating statuses")
+	}
+	return nil
+}
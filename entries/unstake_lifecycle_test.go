@@ -0,0 +1,85 @@
+package entries
+
+import (
+	"testing"
+	"time"
+
+	"github.com/deso-protocol/core/lib"
+	"github.com/deso-protocol/state-consumer/consumer"
+	"github.com/deso-protocol/uint256"
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun/extra/bunbig"
+)
+
+func testUnstakeTransaction() *PGTransactionEntry {
+	return &PGTransactionEntry{TransactionEntry: TransactionEntry{
+		TransactionHash: "txn",
+		PublicKey:       "staker",
+		BlockHash:       "block",
+		BlockHeight:     100,
+		Timestamp:       time.Unix(1700000000, 0),
+	}}
+}
+
+func TestUnstakeLifecycleAttributionFromTxn(t *testing.T) {
+	params := &lib.DeSoMainnetParams
+	validatorPublicKey := testPublicKeyBytes(2)
+	pgTransaction := testUnstakeTransaction()
+
+	attribution, ok := UnstakeLifecycleAttributionFromTxn(&lib.MsgDeSoTxn{TxnMeta: &lib.UnstakeMetadata{
+		ValidatorPublicKey: lib.NewPublicKey(validatorPublicKey),
+		UnstakeAmountNanos: uint256.NewInt(1000),
+	}}, pgTransaction, params)
+	require.True(t, ok)
+	require.Equal(t, &unstakeLifecycleAttribution{
+		UnstakeLifecycle: UnstakeLifecycle{
+			UnstakeTxnHash:     "txn",
+			UnstakeAmountNanos: bunbig.FromMathBig(uint256.NewInt(1000).ToBig()),
+			Status:             UnstakeStatusLocked,
+			UnstakeBlockHash:   "block",
+			UnstakeBlockHeight: 100,
+			UnstakeTimestamp:   pgTransaction.Timestamp,
+		},
+		StakerPublicKey:    "staker",
+		ValidatorPublicKey: consumer.PublicKeyBytesToBase58Check(validatorPublicKey, params),
+	}, attribution)
+
+	_, ok = UnstakeLifecycleAttributionFromTxn(&lib.MsgDeSoTxn{TxnMeta: &lib.StakeMetadata{
+		ValidatorPublicKey: lib.NewPublicKey(validatorPublicKey),
+		StakeAmountNanos:   uint256.NewInt(1000),
+	}}, pgTransaction, params)
+	require.False(t, ok)
+}
+
+func TestStakeUnlocksFromUtxoOps(t *testing.T) {
+	params := &lib.DeSoMainnetParams
+	stakerPKID := lib.PublicKeyToPKID(testPublicKeyBytes(1))
+	validatorPKID := lib.PublicKeyToPKID(testPublicKeyBytes(2))
+	pgTransaction := testUnstakeTransaction()
+
+	// Each claimed locked stake entry is a separate unlock, and incomplete entries are skipped.
+	stakeUnlocks := StakeUnlocksFromUtxoOps([]*lib.UtxoOperation{{
+		Type: lib.OperationTypeUnlockStake,
+		PrevLockedStakeEntries: []*lib.LockedStakeEntry{
+			{StakerPKID: stakerPKID, ValidatorPKID: validatorPKID, LockedAtEpochNumber: 5},
+			nil,
+			{StakerPKID: stakerPKID, LockedAtEpochNumber: 6},
+			{StakerPKID: stakerPKID, ValidatorPKID: validatorPKID, LockedAtEpochNumber: 7},
+		},
+	}}, pgTransaction, params)
+	require.Len(t, stakeUnlocks, 2)
+	for ii, lockedAtEpochNumber := range []uint64{5, 7} {
+		require.Equal(t, &stakeUnlock{
+			StakerPKID:          consumer.PublicKeyBytesToBase58Check(stakerPKID[:], params),
+			ValidatorPKID:       consumer.PublicKeyBytesToBase58Check(validatorPKID[:], params),
+			LockedAtEpochNumber: lockedAtEpochNumber,
+			TxnHash:             "txn",
+			BlockHash:           "block",
+			BlockHeight:         100,
+			Timestamp:           pgTransaction.Timestamp,
+		}, stakeUnlocks[ii])
+	}
+
+	require.Nil(t, StakeUnlocksFromUtxoOps(
+		[]*lib.UtxoOperation{{Type: lib.OperationTypeSpendBalance}}, pgTransaction, params))
+}
@@ -93,6 +93,25 @@ func bulkInsertUserAssociationEntry(entries []*lib.StateChangeEntry, db bun.IDB,
 		pgEntrySlice[ii] = &PGUserAssociationEntry{UserAssociationEntry: UserAssociationEncoderToPGStruct(entry.Encoder.(*lib.UserAssociationEntry), entry.KeyBytes, params)}
 	}
 
+	// Look up the associations being updated, so that their previous values can be removed from the counts.
+	// Associations can't already exist during the initial sync, so we skip the lookup there.
+	countDeltas := newAssociationCountDeltas()
+	if operationType == lib.DbOperationTypeUpsert {
+		var prevEntries []*PGUserAssociationEntry
+		if err := db.NewSelect().
+			Model(&prevEntries).
+			Where("badger_key IN (?)", bun.In(consumer.KeysToDelete(uniqueEntries))).
+			Scan(context.Background()); err != nil {
+			return errors.Wrapf(err, "entries.bulkInsertUserAssociationEntry: Error getting previous entries")
+		}
+		for _, prevEntry := range prevEntries {
+			countDeltas.add(prevEntry.TargetUserPKID, prevEntry.AssociationType, prevEntry.AssociationValue, prevEntry.AppPKID, -1)
+		}
+	}
+	for _, pgEntry := range pgEntrySlice {
+		countDeltas.add(pgEntry.TargetUserPKID, pgEntry.AssociationType, pgEntry.AssociationValue, pgEntry.AppPKID, 1)
+	}
+
 	query := db.NewInsert().Model(&pgEntrySlice)
 
 	if operationType == lib.DbOperationTypeUpsert {
@@ -102,6 +121,10 @@ func bulkInsertUserAssociationEntry(entries []*lib.StateChangeEntry, db bun.IDB,
 	if _, err := query.Returning("").Exec(context.Background()); err != nil {
 		return errors.Wrapf(err, "entries.bulkInsertUserAssociationEntry: Error inserting entries")
 	}
+
+	if err := applyAssociationCountDeltas(countDeltas, "user_association_count", "target_user_pkid", db); err != nil {
+		return errors.Wrapf(err, "entries.bulkInsertUserAssociationEntry: Error updating association counts")
+	}
 	return nil
 }
 
@@ -113,6 +136,15 @@ func bulkDeleteUserAssociationEntry(entries []*lib.StateChangeEntry, db bun.IDB,
 	// Transform the entries into a list of keys to delete.
 	keysToDelete := consumer.KeysToDelete(uniqueEntries)
 
+	// Look up the associations being deleted, so that they can be removed from the counts.
+	var prevEntries []*PGUserAssociationEntry
+	if err := db.NewSelect().
+		Model(&prevEntries).
+		Where("badger_key IN (?)", bun.In(keysToDelete)).
+		Scan(context.Background()); err != nil {
+		return errors.Wrapf(err, "entries.bulkDeleteUserAssociationEntry: Error getting previous entries")
+	}
+
 	// Execute the delete query.
 	if _, err := db.NewDelete().
 		Model(&PGUserAssociationEntry{}).
@@ -122,5 +154,13 @@ func bulkDeleteUserAssociationEntry(entries []*lib.StateChangeEntry, db bun.IDB,
 		return errors.Wrapf(err, "entries.bulkDeleteUserAssociationEntry: Error deleting entries")
 	}
 
+	countDeltas := newAssociationCountDeltas()
+	for _, prevEntry := range prevEntries {
+		countDeltas.add(prevEntry.TargetUserPKID, prevEntry.AssociationType, prevEntry.AssociationValue, prevEntry.AppPKID, -1)
+	}
+	if err := applyAssociationCountDeltas(countDeltas, "user_association_count", "target_user_pkid", db); err != nil {
+		return errors.Wrapf(err, "entries.bulkDeleteUserAssociationEntry: Error updating association counts")
+	}
+
 	return nil
 }
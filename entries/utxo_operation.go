@@ -89,13 +89,10 @@ func bulkInsertUtxoOperationsEntry(entries []*lib.StateChangeEntry, db bun.IDB,
 	// Track the unique entries we've inserted so we don't insert the same entry twice.
 	uniqueEntries := consumer.UniqueEntries(entries)
 
-	// Transactions added to this slice will have their txindex metadata updated.
-	transactionUpdates := make([]*PGTransactionEntry, 0)
-	affectedPublicKeys := make([]*PGAffectedPublicKeyEntry, 0)
+	// Transactions added to results.transactionUpdates will have their txindex metadata updated.
+	results := &utxoOperationBundleResults{}
 	blockEntries := make([]*PGBlockEntry, 0)
 	pgBlockSigners := make([]*PGBlockSigner, 0)
-	stakeRewardEntries := make([]*PGStakeReward, 0)
-	jailedHistoryEntries := make([]*PGJailedHistoryEvent, 0)
 
 	// Start timer to track how long it takes to insert the entries.
 	start := time.Now()
@@ -117,6 +114,10 @@ func bulkInsertUtxoOperationsEntry(entries []*lib.StateChangeEntry, db bun.IDB,
 		// We can use this function regardless of the db prefix, because both block_hash and transaction_hash
 		// are stored in the same blockHashHex format in the key.
 		blockHash := ConvertUtxoOperationKeyToBlockHashHex(entry.KeyBytes)
+		if bytes.Equal(entry.KeyBytes[:1], lib.Prefixes.PrefixBlockHashToUtxoOperations) {
+			results.balanceHistoryBlocks = append(results.balanceHistoryBlocks,
+				&BalanceHistoryBlock{BlockHeight: entry.BlockHeight, BlockHash: blockHash})
+		}
 
 		utxoOperations, ok := entry.Encoder.(*lib.UtxoOperationBundle)
 		if !ok {
@@ -197,6 +198,7 @@ func bulkInsertUtxoOperationsEntry(entries []*lib.StateChangeEntry, db bun.IDB,
 					"txn_type",
 					"block_hash",
 					"block_height",
+					"public_key",
 				).Where(fmt.Sprintf("%s = ?", filterField), blockHash).Where("wrapper_transaction_hash IS NULL").Order("index_in_block ASC").Scan(context.Background())
 			if err != nil {
 				return fmt.Errorf("entries.bulkInsertUtxoOperationsEntry: Problem getting transactions at block height %v: %v", entry.BlockHeight, err)
@@ -245,45 +247,33 @@ func bulkInsertUtxoOperationsEntry(entries []*lib.StateChangeEntry, db bun.IDB,
 		transactionCount += len(utxoOperations.UtxoOpBundle)
 
 		// TODO: Create a wait group to wait for all the goroutines to finish.
-		utxoBundleTransactionUpdates,
-			utxoBundleAffectedPublicKeys,
-			utxoBundleStakeRewardEntries,
-			utxoBundleJailedHistoryEntries,
-			err :=
-			parseUtxoOperationBundle(
-				entry,
-				utxoOperations.UtxoOpBundle,
-				transactions,
-				blockHash,
-				params,
-			)
+		utxoBundleResults, err := parseUtxoOperationBundle(
+			entry,
+			utxoOperations.UtxoOpBundle,
+			transactions,
+			blockHash,
+			params,
+		)
 		if err != nil {
 			return errors.Wrapf(err, "entries.bulkInsertUtxoOperationsEntry: Problem parsing utxo operation bundle")
 		}
-		transactionUpdates = append(transactionUpdates, utxoBundleTransactionUpdates...)
-		affectedPublicKeys = append(affectedPublicKeys, utxoBundleAffectedPublicKeys...)
-		stakeRewardEntries = append(stakeRewardEntries, utxoBundleStakeRewardEntries...)
-		jailedHistoryEntries = append(jailedHistoryEntries, utxoBundleJailedHistoryEntries...)
+		results.append(utxoBundleResults)
 
 		// Parse inner txns and their utxo operations
-		innerTransactionUpdates, innerAffectedPublicKeys, innerStakeRewardEntries, innerJailedHistoryEntries, err :=
-			parseUtxoOperationBundle(
-				entry,
-				innerTransactionsUtxoOperations,
-				innerTransactions,
-				blockHash,
-				params,
-			)
+		innerResults, err := parseUtxoOperationBundle(
+			entry,
+			innerTransactionsUtxoOperations,
+			innerTransactions,
+			blockHash,
+			params,
+		)
 		if err != nil {
 			return errors.Wrapf(
 				err,
 				"entries.bulkInsertUtxoOperationsEntry: Problem parsing inner utxo operation bundle",
 			)
 		}
-		transactionUpdates = append(transactionUpdates, innerTransactionUpdates...)
-		affectedPublicKeys = append(affectedPublicKeys, innerAffectedPublicKeys...)
-		stakeRewardEntries = append(stakeRewardEntries, innerStakeRewardEntries...)
-		jailedHistoryEntries = append(jailedHistoryEntries, innerJailedHistoryEntries...)
+		results.append(innerResults)
 		transactionCount += len(innerTransactionsUtxoOperations)
 		// Print how long it took to insert the entries.
 	}
@@ -291,14 +281,18 @@ func bulkInsertUtxoOperationsEntry(entries []*lib.StateChangeEntry, db bun.IDB,
 
 	start = time.Now()
 
-	if len(transactionUpdates) > 0 {
+	if len(results.transactionUpdates) > 0 {
 
 		if insertTransactions {
-			err := bulkInsertTransactionEntry(transactionUpdates, db, operationType)
+			err := bulkInsertTransactionEntry(results.transactionUpdates, db, operationType)
 			if err != nil {
 				return fmt.Errorf("entries.bulkInsertUtxoOperationsEntry: Problem inserting transaction entries: %v", err)
 			}
 
+			if err := resolveBlockValidators(db, blockEntries, pgBlockSigners); err != nil {
+				return errors.Wrapf(err, "entries.bulkInsertBlock: Error resolving block validators")
+			}
+
 			blockQuery := db.NewInsert().Model(&blockEntries)
 
 			if operationType == lib.DbOperationTypeUpsert {
@@ -322,7 +316,7 @@ func bulkInsertUtxoOperationsEntry(entries []*lib.StateChangeEntry, db bun.IDB,
 			}
 
 		} else {
-			values := db.NewValues(&transactionUpdates)
+			values := db.NewValues(&results.transactionUpdates)
 			_, err := db.NewUpdate().
 				With("_data", values).
 				Model((*PGTransactionEntry)(nil)).
@@ -339,38 +333,114 @@ func bulkInsertUtxoOperationsEntry(entries []*lib.StateChangeEntry, db bun.IDB,
 		}
 	}
 
-	glog.V(2).Infof("entries.bulkInsertUtxoOperationsEntry: Updated %v txns in %v s\n", len(transactionUpdates), time.Since(start))
+	glog.V(2).Infof("entries.bulkInsertUtxoOperationsEntry: Updated %v txns in %v s\n", len(results.transactionUpdates), time.Since(start))
 
 	start = time.Now()
 
 	// Insert affected public keys into db
-	if len(affectedPublicKeys) > 0 {
-		_, err := db.NewInsert().Model(&affectedPublicKeys).On("CONFLICT (public_key, transaction_hash, metadata) DO UPDATE").Exec(context.Background())
+	if len(results.affectedPublicKeys) > 0 {
+		_, err := db.NewInsert().Model(&results.affectedPublicKeys).On("CONFLICT (public_key, transaction_hash, metadata) DO UPDATE").Exec(context.Background())
 		if err != nil {
 			return errors.Wrapf(err, "InsertAffectedPublicKeys: Problem inserting affectedPublicKeys")
 		}
 	}
 
-	glog.V(2).Infof("entries.bulkInsertUtxoOperationsEntry: Inserted %v affected public keys in %v s\n", len(affectedPublicKeys), time.Since(start))
+	glog.V(2).Infof("entries.bulkInsertUtxoOperationsEntry: Inserted %v affected public keys in %v s\n", len(results.affectedPublicKeys), time.Since(start))
 
 	start = time.Now()
 
 	// Insert stake rewards into db
-	if len(stakeRewardEntries) > 0 {
-		_, err := db.NewInsert().Model(&stakeRewardEntries).On("CONFLICT (block_hash, utxo_op_index) DO UPDATE").Exec(context.Background())
+	if len(results.stakeRewardEntries) > 0 {
+		_, err := db.NewInsert().Model(&results.stakeRewardEntries).On("CONFLICT (block_hash, utxo_op_index) DO UPDATE").Exec(context.Background())
 		if err != nil {
 			return errors.Wrapf(err, "InsertStakeRewards: Problem inserting stake rewards")
 		}
+		rewardBlockHashSet := make(map[string]bool)
+		var rewardBlockHashes []string
+		for _, stakeReward := range results.stakeRewardEntries {
+			if !rewardBlockHashSet[stakeReward.BlockHash] {
+				rewardBlockHashSet[stakeReward.BlockHash] = true
+				rewardBlockHashes = append(rewardBlockHashes, stakeReward.BlockHash)
+			}
+		}
+		if err = upsertValidatorEpochSummariesForBlocks(db, rewardBlockHashes); err != nil {
+			return errors.Wrapf(err, "InsertStakeRewards: Problem updating validator epoch summaries")
+		}
 	}
-	glog.V(2).Infof("entries.bulkInsertUtxoOperationsEntry: Inserted %v stake rewards in %v s\n", len(stakeRewardEntries), time.Since(start))
+	glog.V(2).Infof("entries.bulkInsertUtxoOperationsEntry: Inserted %v stake rewards in %v s\n", len(results.stakeRewardEntries), time.Since(start))
 
-	if len(jailedHistoryEntries) > 0 {
-		_, err := db.NewInsert().Model(&jailedHistoryEntries).On("CONFLICT (validator_pkid, jailed_at_epoch_number, unjailed_at_epoch_number) DO NOTHING").Exec(context.Background())
+	if len(results.jailedHistoryEntries) > 0 {
+		_, err := db.NewInsert().Model(&results.jailedHistoryEntries).On("CONFLICT (validator_pkid, jailed_at_epoch_number, unjailed_at_epoch_number) DO NOTHING").Exec(context.Background())
 		if err != nil {
 			return errors.Wrapf(err, "InsertJailedHistory: Problem inserting jailed history")
 		}
 	}
 
+	// Insert nft sales into db
+	if len(results.nftSales) > 0 {
+		_, err := db.NewInsert().Model(&results.nftSales).On("CONFLICT (transaction_hash) DO UPDATE").Exec(context.Background())
+		if err != nil {
+			return errors.Wrapf(err, "InsertNftSales: Problem inserting nft sales")
+		}
+	}
+
+	// Insert creator coin trades into db
+	if len(results.creatorCoinTrades) > 0 {
+		_, err := db.NewInsert().Model(&results.creatorCoinTrades).On("CONFLICT (transaction_hash) DO UPDATE").Exec(context.Background())
+		if err != nil {
+			return errors.Wrapf(err, "InsertCreatorCoinTrades: Problem inserting creator coin trades")
+		}
+	}
+
+	// Link deso balance history to the transactions that caused each balance change.
+	if err := bulkInsertDesoBalanceHistoryAttributions(results.desoBalanceHistoryAttributions, db); err != nil {
+		return errors.Wrapf(err, "InsertDesoBalanceHistory: Problem inserting deso balance history attributions")
+	}
+
+	// Link balance history to the blocks that changed each balance.
+	if err := attributeBalanceHistoryToBlocks(results.balanceHistoryBlocks, db); err != nil {
+		return errors.Wrapf(err, "InsertBalanceHistory: Problem linking balance history to blocks")
+	}
+
+	// Link profile history to the transactions that caused each profile change.
+	if err := bulkInsertProfileHistoryAttributions(results.profileHistoryAttributions, db); err != nil {
+		return errors.Wrapf(err, "InsertProfileHistory: Problem inserting profile history attributions")
+	}
+
+	// Link follow events to the transactions that caused them.
+	if err := bulkInsertFollowEventAttributions(results.followEventAttributions, db); err != nil {
+		return errors.Wrapf(err, "InsertFollowEvents: Problem inserting follow event attributions")
+	}
+
+	// Link post revisions to the transactions that edited each post.
+	if err := bulkInsertPostRevisionAttributions(results.postRevisionAttributions, db); err != nil {
+		return errors.Wrapf(err, "InsertPostRevisions: Problem inserting post revision attributions")
+	}
+
+	// Link access group membership events to the transactions that caused them.
+	if err := bulkInsertAccessGroupMembershipAttributions(results.accessGroupMembershipAttributions, db); err != nil {
+		return errors.Wrapf(err, "InsertAccessGroupMembershipEvents: Problem inserting access group membership attributions")
+	}
+
+	// Insert validator voting key rotations into db
+	if err := bulkInsertValidatorKeyRotations(results.validatorKeyRotations, db); err != nil {
+		return errors.Wrapf(err, "InsertValidatorKeyRotations: Problem inserting validator key rotations")
+	}
+
+	// Link stake position history to the transactions and rewards that changed each position.
+	if err := bulkInsertStakePositionAttributions(results.stakePositionAttributions, db); err != nil {
+		return errors.Wrapf(err, "InsertStakePositionHistory: Problem inserting stake position attributions")
+	}
+
+	// Insert unstakes into db, then mark the unstakes claimed by unlocks. Unstakes are inserted first, since a batch
+	// can hold both an unstake and its unlock.
+	if err := bulkInsertUnstakeLifecycles(results.unstakeLifecycles, db, params); err != nil {
+		return errors.Wrapf(err, "InsertUnstakeLifecycles: Problem inserting unstakes")
+	}
+	if err := applyStakeUnlocks(results.stakeUnlocks, db); err != nil {
+		return errors.Wrapf(err, "InsertUnstakeLifecycles: Problem applying stake unlocks")
+	}
+
 	return nil
 }
 
@@ -394,6 +464,55 @@ func bulkDeleteUtxoOperationEntry(entries []*lib.StateChangeEntry, db bun.IDB, o
 	return nil
 }
 
+// utxoOperationBundleResults holds the rows extracted from a bundle of utxo operations, grouped by the table
+// they are written to.
+type utxoOperationBundleResults struct {
+	transactionUpdates   []*PGTransactionEntry
+	affectedPublicKeys   []*PGAffectedPublicKeyEntry
+	stakeRewardEntries   []*PGStakeReward
+	jailedHistoryEntries []*PGJailedHistoryEvent
+	nftSales             []*PGNftSale
+	creatorCoinTrades    []*PGCreatorCoinTrade
+	// Deso balance history rows that only carry the block and transaction that changed the balance.
+	desoBalanceHistoryAttributions []*PGDesoBalanceHistoryEntry
+	// Profile history rows that only carry the block and transaction that changed the profile.
+	profileHistoryAttributions []*PGProfileHistoryEntry
+	followEventAttributions    []*followEventAttribution
+	// Post revision rows that only carry the block and transaction that edited the post.
+	postRevisionAttributions []*PGPostRevision
+	// Access group membership events, attributed to the transaction that changed the membership.
+	accessGroupMembershipAttributions []*PGAccessGroupMembershipEvent
+	validatorKeyRotations             []*validatorKeyRotationAttribution
+	// Stake position history rows that only carry the action, block and transaction that changed the position.
+	stakePositionAttributions []*stakePositionAttribution
+	unstakeLifecycles         []*unstakeLifecycleAttribution
+	stakeUnlocks              []*stakeUnlock
+	// The blocks whose balance history snapshots are linked to them.
+	balanceHistoryBlocks []*BalanceHistoryBlock
+}
+
+// append adds the rows extracted from another utxo operation bundle to these results.
+func (results *utxoOperationBundleResults) append(other *utxoOperationBundleResults) {
+	results.transactionUpdates = append(results.transactionUpdates, other.transactionUpdates...)
+	results.affectedPublicKeys = append(results.affectedPublicKeys, other.affectedPublicKeys...)
+	results.stakeRewardEntries = append(results.stakeRewardEntries, other.stakeRewardEntries...)
+	results.jailedHistoryEntries = append(results.jailedHistoryEntries, other.jailedHistoryEntries...)
+	results.nftSales = append(results.nftSales, other.nftSales...)
+	results.creatorCoinTrades = append(results.creatorCoinTrades, other.creatorCoinTrades...)
+	results.desoBalanceHistoryAttributions = append(
+		results.desoBalanceHistoryAttributions, other.desoBalanceHistoryAttributions...)
+	results.profileHistoryAttributions = append(results.profileHistoryAttributions, other.profileHistoryAttributions...)
+	results.followEventAttributions = append(results.followEventAttributions, other.followEventAttributions...)
+	results.postRevisionAttributions = append(results.postRevisionAttributions, other.postRevisionAttributions...)
+	results.accessGroupMembershipAttributions = append(
+		results.accessGroupMembershipAttributions, other.accessGroupMembershipAttributions...)
+	results.validatorKeyRotations = append(results.validatorKeyRotations, other.validatorKeyRotations...)
+	results.stakePositionAttributions = append(results.stakePositionAttributions, other.stakePositionAttributions...)
+	results.unstakeLifecycles = append(results.unstakeLifecycles, other.unstakeLifecycles...)
+	results.stakeUnlocks = append(results.stakeUnlocks, other.stakeUnlocks...)
+	results.balanceHistoryBlocks = append(results.balanceHistoryBlocks, other.balanceHistoryBlocks...)
+}
+
 func parseUtxoOperationBundle(
 	entry *lib.StateChangeEntry,
 	utxoOpBundle [][]*lib.UtxoOperation,
@@ -401,16 +520,10 @@ func parseUtxoOperationBundle(
 	blockHashHex string,
 	params *lib.DeSoParams,
 ) (
-	[]*PGTransactionEntry,
-	[]*PGAffectedPublicKeyEntry,
-	[]*PGStakeReward,
-	[]*PGJailedHistoryEvent,
+	*utxoOperationBundleResults,
 	error,
 ) {
-	var affectedPublicKeys []*PGAffectedPublicKeyEntry
-	var transactionUpdates []*PGTransactionEntry
-	var jailedHistoryEntries []*PGJailedHistoryEvent
-	var stakeRewardEntries []*PGStakeReward
+	results := &utxoOperationBundleResults{}
 	for jj := range utxoOpBundle {
 		utxoOps := utxoOpBundle[jj]
 		// Update the transaction metadata for this transaction.
@@ -419,9 +532,6 @@ func parseUtxoOperationBundle(
 			err := transaction.FromBytes(transactions[jj].TxnBytes)
 			if err != nil {
 				return nil,
-					nil,
-					nil,
-					nil,
 					errors.Wrapf(
 						err,
 						"parseUtxoOperationBundle: Problem decoding transaction for entry %+v at "+
@@ -436,7 +546,7 @@ func parseUtxoOperationBundle(
 				// We still append this txn to the transactionUpdates slice so that we can have it in the db.
 				glog.Errorf("parseUtxoOperationBundle: Problem computing transaction metadata for "+
 					"entry %+v at block height %v: %v", entry, entry.BlockHeight, err)
-				transactionUpdates = append(transactionUpdates, transactions[jj])
+				results.transactionUpdates = append(results.transactionUpdates, transactions[jj])
 				continue
 			}
 			metadata := txIndexMetadata.GetEncoderForTxType(transaction.TxnMeta.GetTxnType())
@@ -460,11 +570,63 @@ func parseUtxoOperationBundle(
 
 			transactions[jj].TxIndexBasicTransferMetadata = txIndexMetadata.GetEncoderForTxType(lib.TxnTypeBasicTransfer)
 
+			// Link any deso balance changes made by this transaction to it.
+			results.desoBalanceHistoryAttributions = append(results.desoBalanceHistoryAttributions,
+				DesoBalanceHistoryAttributionsFromUtxoOps(utxoOps, transactions[jj], params)...)
+
 			// Track which public keys have already been added to the affected public keys slice, to avoid duplicates.
 			affectedPublicKeyMetadataSet := make(map[string]bool)
 			affectedPublicKeySet := make(map[string]bool)
 
 			switch transaction.TxnMeta.GetTxnType() {
+			case lib.TxnTypeUpdateProfile, lib.TxnTypeDAOCoin:
+				// Link the profile change made by this transaction to it.
+				profileHistoryAttribution, changedProfile := ProfileHistoryAttributionFromUtxoOps(
+					transaction, utxoOps, transactions[jj], params)
+				if changedProfile {
+					results.profileHistoryAttributions = append(results.profileHistoryAttributions, profileHistoryAttribution)
+				}
+			case lib.TxnTypeFollow:
+				// Link the follow event made by this transaction to it.
+				followEventAttribution, isFollow := FollowEventAttributionFromUtxoOps(
+					transaction, utxoOps, transactions[jj], params)
+				if isFollow {
+					results.followEventAttributions = append(results.followEventAttributions, followEventAttribution)
+				}
+			case lib.TxnTypeSubmitPost:
+				// Link the post edit made by this transaction to it.
+				postRevisionAttribution, isEdit := PostRevisionAttributionFromUtxoOps(transaction, utxoOps, transactions[jj])
+				if isEdit {
+					results.postRevisionAttributions = append(results.postRevisionAttributions, postRevisionAttribution)
+				}
+			case lib.TxnTypeAccessGroupMembers:
+				// Link the membership changes made by this transaction to it.
+				results.accessGroupMembershipAttributions = append(results.accessGroupMembershipAttributions,
+					AccessGroupMembershipAttributionsFromUtxoOps(transaction, utxoOps, transactions[jj], params)...)
+			case lib.TxnTypeRegisterAsValidator:
+				registerAsValidatorMetadata, ok := transaction.TxnMeta.(*lib.RegisterAsValidatorMetadata)
+				if !ok {
+					glog.Error("parseUtxoOperationBundle: Problem with txn meta for register as validator")
+					break
+				}
+				validatorKeyRotation, isRotation := ValidatorKeyRotationAttributionFromUtxoOps(
+					registerAsValidatorMetadata, utxoOps, transactions[jj], params)
+				if isRotation {
+					results.validatorKeyRotations = append(results.validatorKeyRotations, validatorKeyRotation)
+				}
+			case lib.TxnTypeStake, lib.TxnTypeUnstake, lib.TxnTypeUnlockStake:
+				// Link the stake position changes made by this transaction to it.
+				results.stakePositionAttributions = append(results.stakePositionAttributions,
+					StakePositionAttributionsFromUtxoOps(transaction, utxoOps, transactions[jj], params)...)
+				// Track the lifecycle of unstaked DESO, from being locked to being unlocked.
+				if unstakeLifecycle, isUnstake := UnstakeLifecycleAttributionFromTxn(
+					transaction, transactions[jj], params); isUnstake {
+					results.unstakeLifecycles = append(results.unstakeLifecycles, unstakeLifecycle)
+				}
+				if transaction.TxnMeta.GetTxnType() == lib.TxnTypeUnlockStake {
+					results.stakeUnlocks = append(results.stakeUnlocks,
+						StakeUnlocksFromUtxoOps(utxoOps, transactions[jj], params)...)
+				}
 			case lib.TxnTypeUnjailValidator:
 				// Find the unjail utxo op
 				var unjailUtxoOp *lib.UtxoOperation
@@ -484,11 +646,44 @@ func parseUtxoOperationBundle(
 					continue
 				}
 				// Parse the jailed history event and add it to the slice.
-				jailedHistoryEntries = append(jailedHistoryEntries,
+				results.jailedHistoryEntries = append(results.jailedHistoryEntries,
 					&PGJailedHistoryEvent{
 						JailedHistoryEntry: UnjailValidatorStateChangeMetadataEncoderToPGStruct(scm, params),
 					},
 				)
+			case lib.TxnTypeAcceptNFTBid:
+				acceptNftBidMetadata := txIndexMetadata.AcceptNFTBidTxindexMetadata
+				if acceptNftBidMetadata == nil {
+					glog.Error("parseUtxoOperationBundle: Problem finding accept nft bid txindex metadata")
+					break
+				}
+				results.nftSales = append(results.nftSales, &PGNftSale{
+					NftSale: AcceptNftBidTxindexMetadataToPGStruct(
+						acceptNftBidMetadata, nftBidderPublicKey(txIndexMetadata.AffectedPublicKeys), transactions[jj]),
+				})
+			case lib.TxnTypeNFTBid:
+				nftBidMetadata := txIndexMetadata.NFTBidTxindexMetadata
+				// Only buy now bids result in a sale, regular bids are tracked in the nft_bid_entry table.
+				if nftBidMetadata == nil || !nftBidMetadata.IsBuyNowBid {
+					break
+				}
+				results.nftSales = append(results.nftSales, &PGNftSale{
+					NftSale: BuyNowNftBidTxindexMetadataToPGStruct(nftBidMetadata, transactions[jj]),
+				})
+			case lib.TxnTypeCreatorCoin:
+				creatorCoinMetadata, ok := transaction.TxnMeta.(*lib.CreatorCoinMetadataa)
+				if !ok {
+					glog.Error("parseUtxoOperationBundle: Problem with txn meta for creator coin")
+					break
+				}
+				creatorCoinTrade, isTrade := CreatorCoinUtxoOpToPGStruct(
+					creatorCoinMetadata, utxoOps, transactions[jj], params)
+				if !isTrade {
+					break
+				}
+				results.creatorCoinTrades = append(results.creatorCoinTrades, &PGCreatorCoinTrade{
+					CreatorCoinTrade: creatorCoinTrade,
+				})
 			}
 
 			// Loop through the affected public keys and add them to the affected public keys slice.
@@ -518,9 +713,9 @@ func parseUtxoOperationBundle(
 						TransactionHash: transactions[jj].TransactionHash,
 					},
 				}
-				affectedPublicKeys = append(affectedPublicKeys, affectedPublicKeyEntry)
+				results.affectedPublicKeys = append(results.affectedPublicKeys, affectedPublicKeyEntry)
 			}
-			transactionUpdates = append(transactionUpdates, transactions[jj])
+			results.transactionUpdates = append(results.transactionUpdates, transactions[jj])
 		} else if jj == len(transactions) {
 			// TODO: parse utxo operations for the block level index.
 			// Examples: deletion of expired nonces, staking rewards (restaked
@@ -538,12 +733,16 @@ func parseUtxoOperationBundle(
 					stakeReward := PGStakeReward{
 						StakeReward: StakeRewardEncoderToPGStruct(stateChangeMetadata, params, blockHashHex, uint64(ii)),
 					}
-					stakeRewardEntries = append(stakeRewardEntries, &stakeReward)
+					results.stakeRewardEntries = append(results.stakeRewardEntries, &stakeReward)
+					if utxoOp.Type == lib.OperationTypeStakeDistributionRestake {
+						results.stakePositionAttributions = append(results.stakePositionAttributions,
+							StakePositionRestakeAttributionFromStakeReward(&stakeReward.StakeReward, entry.BlockHeight))
+					}
 				}
 			}
 		}
 	}
-	return transactionUpdates, affectedPublicKeys, stakeRewardEntries, jailedHistoryEntries, nil
+	return results, nil
 }
 
 func getInnerTxnsFromAtomicTxn(
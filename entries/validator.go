@@ -160,6 +160,15 @@ func bulkInsertValidatorEntry(entries []*lib.StateChangeEntry, db bun.IDB, opera
 		if _, err := query.Returning("").Exec(context.Background()); err != nil {
 			return errors.Wrapf(err, "entries.bulkInsertValidatorEntry: Error inserting snapshot validator entries")
 		}
+
+		// Block signers stored before their validator set can now be resolved.
+		snapshotAtEpochNumbers := make([]uint64, len(pgSnapshotEntrySlice))
+		for ii, pgSnapshotEntry := range pgSnapshotEntrySlice {
+			snapshotAtEpochNumbers[ii] = pgSnapshotEntry.SnapshotAtEpochNumber
+		}
+		if err := resolveUnresolvedBlockValidators(db, snapshotAtEpochNumbers); err != nil {
+			return errors.Wrapf(err, "entries.bulkInsertValidatorEntry: Error resolving block validators")
+		}
 	}
 
 	// Add any new entries to the cache.
@@ -0,0 +1,59 @@
+package entries
+
+import (
+	"context"
+
+	"github.com/deso-protocol/postgres-data-handler/migrations/migration_utils"
+	"github.com/pkg/errors"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/extra/bunbig"
+)
+
+// ValidatorEpochSummary is the stake and rewards of a validator over a single completed epoch.
+type ValidatorEpochSummary struct {
+	EpochNumber   uint64 `pg:",pk,use_zero"`
+	ValidatorPKID string `pg:",pk,use_zero"`
+	// The validator's total stake in the snapshot the epoch used.
+	TotalStakeAmountNanos *bunbig.Int `pg:",use_zero"`
+	TotalRewardNanos      uint64      `pg:",use_zero"`
+	CommissionNanos       uint64      `pg:",use_zero"`
+	// Rewards paid to stakers other than the validator itself, after commission.
+	DelegatorRewardNanos uint64 `pg:",use_zero"`
+	StakerCount          uint64 `pg:",use_zero"`
+	// The annualized yield to the validator's stakers over the epoch, net of commission.
+	RealizedApy float64 `bun:",nullzero"`
+}
+
+type PGValidatorEpochSummary struct {
+	bun.BaseModel `bun:"table:validator_epoch_summary"`
+	ValidatorEpochSummary
+}
+
+// upsertValidatorEpochSummaries computes the validator summaries of the given epochs.
+func upsertValidatorEpochSummaries(db bun.IDB, epochNumbers []uint64) error {
+	if err := migration_utils.UpsertValidatorEpochSummaries(context.Background(), db, epochNumbers); err != nil {
+		return errors.Wrapf(err, "entries.upsertValidatorEpochSummaries: Error upserting summaries")
+	}
+	return nil
+}
+
+// upsertValidatorEpochSummariesForBlocks recomputes the validator summaries of the epochs the given blocks belong to.
+// Stake rewards can be stored after the next epoch has started, so the summaries are refreshed as they arrive.
+func upsertValidatorEpochSummariesForBlocks(db bun.IDB, blockHashes []string) error {
+	if len(blockHashes) == 0 {
+		return nil
+	}
+	var epochNumbers []uint64
+	if err := db.NewSelect().
+		ColumnExpr("DISTINCT epoch_entry.epoch_number").
+		TableExpr("block").
+		Join("JOIN epoch_entry ON block.height BETWEEN epoch_entry.initial_block_height AND epoch_entry.final_block_height").
+		Where("block.block_hash IN (?)", bun.In(blockHashes)).
+		Scan(context.Background(), &epochNumbers); err != nil {
+		return errors.Wrapf(err, "entries.upsertValidatorEpochSummariesForBlocks: Error getting epoch numbers")
+	}
+	if err := upsertValidatorEpochSummaries(db, epochNumbers); err != nil {
+		return errors.Wrapf(err, "entries.upsertValidatorEpochSummariesForBlocks: Error upserting summaries")
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package entries
+
+import (
+	"context"
+	"time"
+
+	"github.com/deso-protocol/core/lib"
+	"github.com/deso-protocol/state-consumer/consumer"
+	"github.com/pkg/errors"
+	"github.com/uptrace/bun"
+)
+
+// ValidatorKeyRotation is a record of a validator registering with, or switching to, a new BLS voting public key.
+type ValidatorKeyRotation struct {
+	TransactionHash string `pg:",pk,use_zero"`
+	ValidatorPKID   string `pg:",use_zero"`
+	// The voting public key the validator used before the transaction. Empty for a first registration.
+	OldVotingPublicKey string    `bun:",nullzero"`
+	NewVotingPublicKey string    `pg:",use_zero"`
+	EpochNumber        uint64    `bun:",nullzero"`
+	BlockHash          string    `pg:",use_zero"`
+	BlockHeight        uint64    `pg:",use_zero"`
+	Timestamp          time.Time `pg:",use_zero"`
+}
+
+type PGValidatorKeyRotation struct {
+	bun.BaseModel `bun:"table:validator_key_rotation"`
+	ValidatorKeyRotation
+}
+
+// validatorKeyRotationAttribution is a key rotation along with the public key of the transactor, which identifies
+// validators registering for the first time.
+type validatorKeyRotationAttribution struct {
+	ValidatorKeyRotation
+	TransactorPublicKey string
+}
+
+// ValidatorKeyRotationAttributionFromUtxoOps returns the voting key change made by a register as validator
+// transaction. The second return value is false if the transaction kept the validator's voting key.
+func ValidatorKeyRotationAttributionFromUtxoOps(
+	registerAsValidatorMetadata *lib.RegisterAsValidatorMetadata,
+	utxoOps []*lib.UtxoOperation,
+	transaction *PGTransactionEntry,
+	params *lib.DeSoParams,
+) (*validatorKeyRotationAttribution, bool) {
+	registerAsValidatorUtxoOp := consumer.GetUtxoOpByOperationType(utxoOps, lib.OperationTypeRegisterAsValidator)
+	if registerAsValidatorUtxoOp == nil || registerAsValidatorMetadata.VotingPublicKey == nil {
+		return nil, false
+	}
+	validatorKeyRotation := ValidatorKeyRotation{
+		TransactionHash:    transaction.TransactionHash,
+		NewVotingPublicKey: registerAsValidatorMetadata.VotingPublicKey.ToString(),
+		BlockHash:          transaction.BlockHash,
+		BlockHeight:        transaction.BlockHeight,
+		Timestamp:          transaction.Timestamp,
+	}
+	if prevValidatorEntry := registerAsValidatorUtxoOp.PrevValidatorEntry; prevValidatorEntry != nil {
+		if prevValidatorEntry.ValidatorPKID != nil {
+			validatorKeyRotation.ValidatorPKID = consumer.PublicKeyBytesToBase58Check(
+				(*prevValidatorEntry.ValidatorPKID)[:], params)
+		}
+		if prevValidatorEntry.VotingPublicKey != nil {
+			validatorKeyRotation.OldVotingPublicKey = prevValidatorEntry.VotingPublicKey.ToString()
+		}
+	}
+	if validatorKeyRotation.OldVotingPublicKey == validatorKeyRotation.NewVotingPublicKey {
+		return nil, false
+	}
+	return &validatorKeyRotationAttribution{
+		ValidatorKeyRotation: validatorKeyRotation,
+		TransactorPublicKey:  transaction.PublicKey,
+	}, true
+}
+
+// bulkInsertValidatorKeyRotations stores the given key rotations, along with the epoch each one was made in.
+// Validators registering for the first time are identified by the PKID of the transactor.
+func bulkInsertValidatorKeyRotations(attributions []*validatorKeyRotationAttribution, db bun.IDB) error {
+	if len(attributions) == 0 {
+		return nil
+	}
+	transactorPublicKeys := make([]string, len(attributions))
+	for ii, attribution := range attributions {
+		transactorPublicKeys[ii] = attribution.TransactorPublicKey
+	}
+	pkids, err := getPkidsByPublicKey(db, transactorPublicKeys)
+	if err != nil {
+		return errors.Wrapf(err, "entries.bulkInsertValidatorKeyRotations: Error getting pkids")
+	}
+	pgRotationSlice := make([]*PGValidatorKeyRotation, len(attributions))
+	transactionHashes := make([]string, len(attributions))
+	for ii, attribution := range attributions {
+		pgRotationSlice[ii] = &PGValidatorKeyRotation{ValidatorKeyRotation: attribution.ValidatorKeyRotation}
+		if pgRotationSlice[ii].ValidatorPKID == "" {
+			pgRotationSlice[ii].ValidatorPKID = pkids[attribution.TransactorPublicKey]
+		}
+		transactionHashes[ii] = attribution.TransactionHash
+	}
+
+	if _, err := db.NewInsert().
+		Model(&pgRotationSlice).
+		On("CONFLICT (transaction_hash) DO UPDATE").
+		Returning("").
+		Exec(context.Background()); err != nil {
+		return errors.Wrapf(err, "entries.bulkInsertValidatorKeyRotations: Error inserting key rotations")
+	}
+
+	if _, err := db.NewUpdate().
+		Model((*PGValidatorKeyRotation)(nil)).
+		TableExpr("epoch_entry").
+		Set("epoch_number = epoch_entry.epoch_number").
+		Where("?TableAlias.transaction_hash IN (?)", bun.In(transactionHashes)).
+		Where("?TableAlias.block_height BETWEEN epoch_entry.initial_block_height AND epoch_entry.final_block_height").
+		Returning("").
+		Exec(context.Background()); err != nil {
+		return errors.Wrapf(err, "entries.bulkInsertValidatorKeyRotations: Error setting epoch numbers")
+	}
+	return nil
+}
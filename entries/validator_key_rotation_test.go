@@ -0,0 +1,71 @@
+package entries
+
+import (
+	"testing"
+	"time"
+
+	"github.com/deso-protocol/core/bls"
+	"github.com/deso-protocol/core/lib"
+	"github.com/deso-protocol/state-consumer/consumer"
+	"github.com/stretchr/testify/require"
+)
+
+func testVotingPublicKey(t *testing.T) *bls.PublicKey {
+	privateKey, err := bls.NewPrivateKey()
+	require.NoError(t, err)
+	return privateKey.PublicKey()
+}
+
+func TestValidatorKeyRotationAttributionFromUtxoOps(t *testing.T) {
+	params := &lib.DeSoMainnetParams
+	validatorPKID := lib.PublicKeyToPKID(testPublicKeyBytes(1))
+	oldVotingPublicKey := testVotingPublicKey(t)
+	newVotingPublicKey := testVotingPublicKey(t)
+	transaction := &PGTransactionEntry{TransactionEntry: TransactionEntry{
+		TransactionHash: "txn",
+		PublicKey:       consumer.PublicKeyBytesToBase58Check(testPublicKeyBytes(1), params),
+		BlockHash:       "block",
+		BlockHeight:     100,
+		Timestamp:       time.Unix(1700000000, 0),
+	}}
+	registerUtxoOps := func(prevValidatorEntry *lib.ValidatorEntry) []*lib.UtxoOperation {
+		return []*lib.UtxoOperation{{Type: lib.OperationTypeRegisterAsValidator, PrevValidatorEntry: prevValidatorEntry}}
+	}
+
+	attribution, ok := ValidatorKeyRotationAttributionFromUtxoOps(
+		&lib.RegisterAsValidatorMetadata{VotingPublicKey: newVotingPublicKey},
+		registerUtxoOps(&lib.ValidatorEntry{ValidatorPKID: validatorPKID, VotingPublicKey: oldVotingPublicKey}),
+		transaction, params)
+	require.True(t, ok)
+	require.Equal(t, &validatorKeyRotationAttribution{
+		ValidatorKeyRotation: ValidatorKeyRotation{
+			TransactionHash:    "txn",
+			ValidatorPKID:      consumer.PublicKeyBytesToBase58Check(validatorPKID[:], params),
+			OldVotingPublicKey: oldVotingPublicKey.ToString(),
+			NewVotingPublicKey: newVotingPublicKey.ToString(),
+			BlockHash:          "block",
+			BlockHeight:        100,
+			Timestamp:          transaction.Timestamp,
+		},
+		TransactorPublicKey: transaction.PublicKey,
+	}, attribution)
+
+	// A first registration has no old key, and its validator is identified by the transactor.
+	attribution, ok = ValidatorKeyRotationAttributionFromUtxoOps(
+		&lib.RegisterAsValidatorMetadata{VotingPublicKey: newVotingPublicKey}, registerUtxoOps(nil), transaction, params)
+	require.True(t, ok)
+	require.Equal(t, "", attribution.ValidatorPKID)
+	require.Equal(t, "", attribution.OldVotingPublicKey)
+
+	// Re-registering with the same key isn't a rotation.
+	_, ok = ValidatorKeyRotationAttributionFromUtxoOps(
+		&lib.RegisterAsValidatorMetadata{VotingPublicKey: oldVotingPublicKey},
+		registerUtxoOps(&lib.ValidatorEntry{ValidatorPKID: validatorPKID, VotingPublicKey: oldVotingPublicKey}),
+		transaction, params)
+	require.False(t, ok)
+
+	_, ok = ValidatorKeyRotationAttributionFromUtxoOps(
+		&lib.RegisterAsValidatorMetadata{VotingPublicKey: newVotingPublicKey},
+		[]*lib.UtxoOperation{{Type: lib.OperationTypeSpendBalance}}, transaction, params)
+	require.False(t, ok)
+}
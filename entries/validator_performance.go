@@ -0,0 +1,64 @@
+package entries
+
+import (
+	"context"
+
+	"github.com/deso-protocol/postgres-data-handler/migrations/migration_utils"
+	"github.com/pkg/errors"
+	"github.com/uptrace/bun"
+)
+
+// ValidatorPerformance is how reliably a validator led its scheduled views and signed quorum certificates over a
+// single completed epoch.
+type ValidatorPerformance struct {
+	EpochNumber          uint64 `pg:",pk,use_zero"`
+	ValidatorPKID        string `pg:",pk,use_zero"`
+	ScheduledLeaderSlots uint64 `pg:",use_zero"`
+	BlocksProposed       uint64 `pg:",use_zero"`
+	// Scheduled views for which no block was committed.
+	Timeouts             uint64 `pg:",use_zero"`
+	QcSignatures         uint64 `pg:",use_zero"`
+	QcSignaturesExpected uint64 `pg:",use_zero"`
+	// The share of expected quorum certificate signatures that the validator provided.
+	ParticipationRate float64 `bun:",nullzero"`
+}
+
+type PGValidatorPerformance struct {
+	bun.BaseModel `bun:"table:validator_performance"`
+	ValidatorPerformance
+}
+
+// upsertValidatorPerformance computes the validator performance of the given epochs. The quorum certificate for an
+// epoch's final block is stored in the first block of the next epoch, so the epoch is measured again once that block
+// arrives.
+func upsertValidatorPerformance(db bun.IDB, epochNumbers []uint64) error {
+	if err := migration_utils.UpsertValidatorPerformance(context.Background(), db, epochNumbers); err != nil {
+		return errors.Wrapf(err, "entries.upsertValidatorPerformance: Error upserting performance")
+	}
+	return nil
+}
+
+// upsertValidatorPerformanceForBlocks measures the epochs completed by the given blocks. A block completes the
+// previous epoch when it's the first block of its own epoch.
+func upsertValidatorPerformanceForBlocks(db bun.IDB, pgBlockEntrySlice []*PGBlockEntry) error {
+	if len(pgBlockEntrySlice) == 0 {
+		return nil
+	}
+	heights := make([]uint64, len(pgBlockEntrySlice))
+	for ii, pgBlockEntry := range pgBlockEntrySlice {
+		heights[ii] = pgBlockEntry.Height
+	}
+	var completedEpochNumbers []uint64
+	if err := db.NewSelect().
+		Model((*PGEpochEntry)(nil)).
+		ColumnExpr("epoch_number - 1").
+		Where("epoch_number > 0").
+		Where("initial_block_height IN (?)", bun.In(heights)).
+		Scan(context.Background(), &completedEpochNumbers); err != nil {
+		return errors.Wrapf(err, "entries.upsertValidatorPerformanceForBlocks: Error getting epoch numbers")
+	}
+	if err := upsertValidatorPerformance(db, completedEpochNumbers); err != nil {
+		return errors.Wrapf(err, "entries.upsertValidatorPerformanceForBlocks: Error upserting performance")
+	}
+	return nil
+}
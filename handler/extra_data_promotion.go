@@ -0,0 +1,269 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"github.com/uptrace/bun"
+)
+
+// ExtraDataPromotion declares an ExtraData key that should be copied into its own typed, indexed column.
+type ExtraDataPromotion struct {
+	// The ExtraData key to promote, e.g. "BlogTitleSlug".
+	Key string `mapstructure:"key"`
+	// The type of the column, one of the keys of extraDataPromotionColumnTypes.
+	Type string `mapstructure:"type"`
+	// The name of the column. Defaults to "extra_data_" followed by the key in snake case.
+	Column string `mapstructure:"column"`
+}
+
+// ExtraDataPromotionConfig maps table names to the ExtraData keys promoted on that table.
+type ExtraDataPromotionConfig struct {
+	Tables map[string][]ExtraDataPromotion `mapstructure:"tables"`
+}
+
+// The expression used to compute each column type from the ExtraData value, which is substituted for {value}.
+// Values that don't parse as the column type are stored as null rather than failing the write. Question marks that
+// are part of the expression rather than placeholders are escaped, so that bun leaves them as is.
+var extraDataPromotionTypeExpressions = map[string]string{
+	"text":    `{value}`,
+	"bigint":  `(CASE WHEN {value} ~ '^-\?[0-9]{1,18}$' THEN {value}::BIGINT END)`,
+	"numeric": `(CASE WHEN {value} ~ '^-\?[0-9]+(\.[0-9]+)\?$' THEN {value}::NUMERIC END)`,
+	"double":  `(CASE WHEN {value} ~ '^-\?[0-9]+(\.[0-9]+)\?([eE][-+]\?[0-9]+)\?$' THEN {value}::DOUBLE PRECISION END)`,
+	"boolean": `(CASE lower({value}) WHEN 'true' THEN true WHEN 'false' THEN false END)`,
+}
+
+// The postgres type of the column for each promotion type.
+var extraDataPromotionColumnTypes = map[string]string{
+	"text":    "TEXT",
+	"bigint":  "BIGINT",
+	"numeric": "NUMERIC",
+	"double":  "DOUBLE PRECISION",
+	"boolean": "BOOLEAN",
+}
+
+// The number of table pages updated per statement when backfilling promoted columns.
+const extraDataBackfillBatchPages = 1000
+
+var (
+	extraDataIdentifierRegex = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+	camelCaseBoundaryRegex   = regexp.MustCompile(`([a-z0-9])([A-Z])`)
+	nonIdentifierCharRegex   = regexp.MustCompile(`[^a-z0-9_]+`)
+)
+
+// LoadExtraDataPromotionConfig reads an ExtraData promotion config file. Any format supported by viper, such as
+// yaml or json, can be used.
+func LoadExtraDataPromotionConfig(path string) (*ExtraDataPromotionConfig, error) {
+	configReader := viper.New()
+	configReader.SetConfigFile(path)
+	if err := configReader.ReadInConfig(); err != nil {
+		return nil, errors.Wrapf(err, "LoadExtraDataPromotionConfig: Error reading config file %v", path)
+	}
+	config := &ExtraDataPromotionConfig{}
+	if err := configReader.Unmarshal(config); err != nil {
+		return nil, errors.Wrapf(err, "LoadExtraDataPromotionConfig: Error parsing config file %v", path)
+	}
+	for tableName, promotions := range config.Tables {
+		if !extraDataIdentifierRegex.MatchString(tableName) {
+			return nil, fmt.Errorf("LoadExtraDataPromotionConfig: Invalid table name %q", tableName)
+		}
+		for ii := range promotions {
+			promotion := &promotions[ii]
+			if promotion.Key == "" {
+				return nil, fmt.Errorf("LoadExtraDataPromotionConfig: Missing key for table %v", tableName)
+			}
+			if _, ok := extraDataPromotionColumnTypes[promotion.Type]; !ok {
+				return nil, fmt.Errorf("LoadExtraDataPromotionConfig: Invalid type %q for key %v", promotion.Type, promotion.Key)
+			}
+			if promotion.Column == "" {
+				promotion.Column = defaultExtraDataColumnName(promotion.Key)
+			}
+			if !extraDataIdentifierRegex.MatchString(promotion.Column) {
+				return nil, fmt.Errorf("LoadExtraDataPromotionConfig: Invalid column name %q for key %v", promotion.Column, promotion.Key)
+			}
+		}
+	}
+	return config, nil
+}
+
+// defaultExtraDataColumnName converts an ExtraData key such as "BlogTitleSlug" to "extra_data_blog_title_slug".
+func defaultExtraDataColumnName(key string) string {
+	snakeCaseKey := camelCaseBoundaryRegex.ReplaceAllString(key, "${1}_${2}")
+	snakeCaseKey = nonIdentifierCharRegex.ReplaceAllString(strings.ToLower(snakeCaseKey), "_")
+	return "extra_data_" + strings.Trim(snakeCaseKey, "_")
+}
+
+// extraDataPromotionExpression returns the expression computing a promoted column from the given jsonb value, such
+// as NEW.extra_data, along with the args to format it with.
+func extraDataPromotionExpression(promotion ExtraDataPromotion, source string) (string, []interface{}) {
+	template := extraDataPromotionTypeExpressions[promotion.Type]
+	args := make([]interface{}, strings.Count(template, "{value}"))
+	for ii := range args {
+		args[ii] = promotion.Key
+	}
+	return strings.ReplaceAll(template, "{value}", fmt.Sprintf("(%s ->> ?)", source)), args
+}
+
+// extraDataPromotionTriggerName returns the name of the trigger, and of its function, that keeps the promoted
+// columns of a table in sync with its extra_data column.
+func extraDataPromotionTriggerName(tableName string) string {
+	return tableName + "_promote_extra_data"
+}
+
+// extraDataPromotionFunctionQuery returns the query that creates the trigger function computing the promoted columns
+// of a table from its extra_data column, along with the args to format it with.
+func extraDataPromotionFunctionQuery(tableName string, promotions []ExtraDataPromotion) (string, []interface{}) {
+	var assignments strings.Builder
+	var args []interface{}
+	for _, promotion := range promotions {
+		expression, expressionArgs := extraDataPromotionExpression(promotion, "NEW.extra_data")
+		assignments.WriteString(fmt.Sprintf("\t\t\t\tNEW.%s := %s;\n", promotion.Column, expression))
+		args = append(args, expressionArgs...)
+	}
+	return fmt.Sprintf(`
+			CREATE OR REPLACE FUNCTION %s() RETURNS trigger AS $$
+			BEGIN
+%s				RETURN NEW;
+			END;
+			$$ LANGUAGE plpgsql;
+		`, extraDataPromotionTriggerName(tableName), assignments.String()), args
+}
+
+// extraDataPromotionBackfillQuery returns the query that fills in the promoted columns of a range of table pages,
+// along with the args to format it with. The start and end of the range are formatted after the returned args.
+func extraDataPromotionBackfillQuery(tableName string, promotions []ExtraDataPromotion) (string, []interface{}) {
+	assignments := make([]string, len(promotions))
+	var args []interface{}
+	for ii, promotion := range promotions {
+		expression, expressionArgs := extraDataPromotionExpression(promotion, "extra_data")
+		assignments[ii] = fmt.Sprintf("%s = %s", promotion.Column, expression)
+		args = append(args, expressionArgs...)
+	}
+	return fmt.Sprintf(
+		"UPDATE %s SET %s WHERE ctid >= ?::tid AND ctid < ?::tid AND extra_data IS NOT NULL",
+		tableName, strings.Join(assignments, ", "),
+	), args
+}
+
+// ApplyExtraDataPromotions adds a column and index for each promoted ExtraData key. The columns are kept in sync by
+// a trigger on the extra_data column, so every insert and update made by the handler keeps them populated without
+// the batch operations knowing about them. Adding a nullable column doesn't rewrite the table, so the columns of
+// existing rows are only filled in when backfill is true, a batch of pages at a time. Indexes are built
+// concurrently, so that the tables stay writable while they're built.
+func ApplyExtraDataPromotions(db *bun.DB, config *ExtraDataPromotionConfig, backfill bool) error {
+	if config == nil {
+		return nil
+	}
+	ctx := context.Background()
+	for tableName, promotions := range config.Tables {
+		var existingColumns []string
+		if err := db.NewSelect().
+			TableExpr("information_schema.columns").
+			Column("column_name").
+			Where("table_schema = current_schema()").
+			Where("table_name = ?", tableName).
+			Scan(ctx, &existingColumns); err != nil {
+			return errors.Wrapf(err, "ApplyExtraDataPromotions: Error checking table %v", tableName)
+		}
+		existingColumnSet := make(map[string]bool)
+		for _, existingColumn := range existingColumns {
+			existingColumnSet[existingColumn] = true
+		}
+		if !existingColumnSet["extra_data"] {
+			return fmt.Errorf("ApplyExtraDataPromotions: Table %v doesn't have an extra_data column", tableName)
+		}
+
+		// Only alter the table for new columns, since altering it takes a lock on the table.
+		for _, promotion := range promotions {
+			if existingColumnSet[promotion.Column] {
+				continue
+			}
+			if _, err := db.NewRaw(
+				fmt.Sprintf("ALTER TABLE ? ADD COLUMN IF NOT EXISTS ? %s", extraDataPromotionColumnTypes[promotion.Type]),
+				bun.Ident(tableName), bun.Ident(promotion.Column),
+			).Exec(ctx); err != nil {
+				return errors.Wrapf(err, "ApplyExtraDataPromotions: Error adding column %v to table %v", promotion.Column, tableName)
+			}
+		}
+
+		// Replacing the function updates the trigger without locking the table, so only the trigger is created once.
+		functionQuery, functionArgs := extraDataPromotionFunctionQuery(tableName, promotions)
+		if _, err := db.NewRaw(functionQuery, functionArgs...).Exec(ctx); err != nil {
+			return errors.Wrapf(err, "ApplyExtraDataPromotions: Error creating trigger function for table %v", tableName)
+		}
+		triggerName := extraDataPromotionTriggerName(tableName)
+		hasTrigger, err := db.NewSelect().
+			TableExpr("pg_trigger").
+			Where("tgrelid = ?::regclass", tableName).
+			Where("tgname = ?", triggerName).
+			Exists(ctx)
+		if err != nil {
+			return errors.Wrapf(err, "ApplyExtraDataPromotions: Error checking trigger for table %v", tableName)
+		}
+		if !hasTrigger {
+			if _, err = db.NewRaw(
+				"CREATE TRIGGER ? BEFORE INSERT OR UPDATE OF extra_data ON ? FOR EACH ROW EXECUTE FUNCTION ?()",
+				bun.Ident(triggerName), bun.Ident(tableName), bun.Ident(triggerName),
+			).Exec(ctx); err != nil {
+				return errors.Wrapf(err, "ApplyExtraDataPromotions: Error creating trigger for table %v", tableName)
+			}
+		}
+
+		if backfill {
+			if err = backfillExtraDataPromotions(db, tableName, promotions); err != nil {
+				return errors.Wrapf(err, "ApplyExtraDataPromotions: Error backfilling table %v", tableName)
+			}
+		}
+
+		for _, promotion := range promotions {
+			if _, err = db.NewRaw(
+				"CREATE INDEX CONCURRENTLY IF NOT EXISTS ? ON ? (?)",
+				bun.Ident(fmt.Sprintf("%s_%s_idx", tableName, promotion.Column)), bun.Ident(tableName), bun.Ident(promotion.Column),
+			).Exec(ctx); err != nil {
+				return errors.Wrapf(err, "ApplyExtraDataPromotions: Error indexing column %v of table %v", promotion.Column, tableName)
+			}
+			glog.Infof("ApplyExtraDataPromotions: Promoted extra data key %v to %v.%v", promotion.Key, tableName, promotion.Column)
+		}
+	}
+	return nil
+}
+
+// backfillExtraDataPromotions fills in the promoted columns of a table's existing rows. Each batch of pages is
+// updated in its own statement, so that no single statement holds its row locks for long.
+func backfillExtraDataPromotions(db *bun.DB, tableName string, promotions []ExtraDataPromotion) error {
+	ctx := context.Background()
+	var numPages uint64
+	if err := db.NewRaw(
+		"SELECT pg_relation_size(?) / current_setting('block_size')::bigint", tableName,
+	).Scan(ctx, &numPages); err != nil {
+		return errors.Wrapf(err, "backfillExtraDataPromotions: Error getting size of table %v", tableName)
+	}
+	backfillQuery, backfillArgs := extraDataPromotionBackfillQuery(tableName, promotions)
+	for startPage := uint64(0); startPage <= numPages; startPage += extraDataBackfillBatchPages {
+		endPage := startPage + extraDataBackfillBatchPages
+		args := append([]interface{}{}, backfillArgs...)
+		args = append(args, fmt.Sprintf("(%d,0)", startPage), fmt.Sprintf("(%d,0)", endPage))
+		if _, err := db.NewRaw(backfillQuery, args...).Exec(ctx); err != nil {
+			return errors.Wrapf(err, "backfillExtraDataPromotions: Error backfilling pages %v to %v of table %v",
+				startPage, endPage, tableName)
+		}
+		glog.Infof("backfillExtraDataPromotions: Backfilled %v of %v pages of table %v", endPage, numPages, tableName)
+	}
+	return nil
+}
+
+// AnalyzeExtraDataPromotionTables refreshes the planner statistics of the tables with promoted ExtraData keys, so
+// that queries filtering on the new columns make use of their indexes.
+func AnalyzeExtraDataPromotionTables(db *bun.DB, config *ExtraDataPromotionConfig) error {
+	for tableName := range config.Tables {
+		if _, err := db.NewRaw("ANALYZE ?", bun.Ident(tableName)).Exec(context.Background()); err != nil {
+			return errors.Wrapf(err, "AnalyzeExtraDataPromotionTables: Error analyzing table %v", tableName)
+		}
+	}
+	return nil
+}
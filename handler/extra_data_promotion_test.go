@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun/dialect/pgdialect"
+	"github.com/uptrace/bun/schema"
+)
+
+func TestExtraDataPromotionFunctionQuery(t *testing.T) {
+	formatter := schema.NewFormatter(pgdialect.New())
+	expectedAssignments := map[string]string{
+		"text": `NEW.promoted := (NEW.extra_data ->> 'Some''Key');`,
+		"bigint": `NEW.promoted := (CASE WHEN (NEW.extra_data ->> 'Some''Key') ~ '^-?[0-9]{1,18}$' ` +
+			`THEN (NEW.extra_data ->> 'Some''Key')::BIGINT END);`,
+		"numeric": `NEW.promoted := (CASE WHEN (NEW.extra_data ->> 'Some''Key') ~ '^-?[0-9]+(\.[0-9]+)?$' ` +
+			`THEN (NEW.extra_data ->> 'Some''Key')::NUMERIC END);`,
+		"double": `NEW.promoted := (CASE WHEN (NEW.extra_data ->> 'Some''Key') ~ '^-?[0-9]+(\.[0-9]+)?([eE][-+]?[0-9]+)?$' ` +
+			`THEN (NEW.extra_data ->> 'Some''Key')::DOUBLE PRECISION END);`,
+		"boolean": `NEW.promoted := (CASE lower((NEW.extra_data ->> 'Some''Key')) WHEN 'true' THEN true WHEN 'false' THEN false END);`,
+	}
+	require.Len(t, expectedAssignments, len(extraDataPromotionColumnTypes))
+	for promotionType, expectedAssignment := range expectedAssignments {
+		promotions := []ExtraDataPromotion{{Key: "Some'Key", Type: promotionType, Column: "promoted"}}
+		query, args := extraDataPromotionFunctionQuery("post_entry", promotions)
+		formattedQuery := formatter.FormatQuery(query, args...)
+		require.Contains(t, formattedQuery, expectedAssignment, promotionType)
+		require.Contains(t, formattedQuery, "CREATE OR REPLACE FUNCTION post_entry_promote_extra_data()", promotionType)
+		require.NotContains(t, formattedQuery, "?'", promotionType)
+	}
+}
+
+func TestExtraDataPromotionBackfillQuery(t *testing.T) {
+	formatter := schema.NewFormatter(pgdialect.New())
+	promotions := []ExtraDataPromotion{
+		{Key: "Node", Type: "bigint", Column: "extra_data_node"},
+		{Key: "BlogTitleSlug", Type: "text", Column: "extra_data_blog_title_slug"},
+	}
+	query, args := extraDataPromotionBackfillQuery("post_entry", promotions)
+	args = append(args, "(0,0)", "(1000,0)")
+	formattedQuery := formatter.FormatQuery(query, args...)
+	require.Equal(t, "UPDATE post_entry SET "+
+		"extra_data_node = (CASE WHEN (extra_data ->> 'Node') ~ '^-?[0-9]{1,18}$' THEN (extra_data ->> 'Node')::BIGINT END), "+
+		"extra_data_blog_title_slug = (extra_data ->> 'BlogTitleSlug') "+
+		"WHERE ctid >= '(0,0)'::tid AND ctid < '(1000,0)'::tid AND extra_data IS NOT NULL", formattedQuery)
+}
+
+func TestDefaultExtraDataColumnName(t *testing.T) {
+	require.Equal(t, "extra_data_blog_title_slug", defaultExtraDataColumnName("BlogTitleSlug"))
+	require.Equal(t, "extra_data_node", defaultExtraDataColumnName("Node"))
+	require.Equal(t, "extra_data_nft_type", defaultExtraDataColumnName("nft-type"))
+	require.True(t, strings.HasPrefix(defaultExtraDataColumnName("Some Key!"), "extra_data_some_key"))
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/deso-protocol/core/lib"
 	"github.com/deso-protocol/postgres-data-handler/handler"
 	"github.com/deso-protocol/postgres-data-handler/migrations/initial_migrations"
+	"github.com/deso-protocol/postgres-data-handler/migrations/migration_utils"
 	"github.com/deso-protocol/postgres-data-handler/migrations/post_sync_migrations"
 	"github.com/deso-protocol/state-consumer/consumer"
 	"github.com/golang/glog"
@@ -27,7 +28,7 @@ func main() {
 	// Initialize flags and get config values.
 	setupFlags()
 	pgURI, stateChangeDir, consumerProgressDir, batchBytes, threadLimit, logQueries, readOnlyUserPassword,
-		explorerStatistics, datadogProfiler, isTestnet, isRegtest, isAcceleratedRegtest, syncMempool := getConfigValues()
+		explorerStatistics, datadogProfiler, isTestnet, isRegtest, isAcceleratedRegtest, syncMempool, textSearchLanguage := getConfigValues()
 
 	dbName := "postgres"
 	if viper.GetString("DB_NAME") != "" {
@@ -53,17 +54,39 @@ func main() {
 		REGTEST: %t
 		ACCELERATED_REGTEST: %t
 		SYNC_MEMPOOL: %t
+		TEXT_SEARCH_LANGUAGE: %s
 		`, viper.GetString("DB_HOST"), viper.GetString("DB_PORT"),
 		viper.GetString("DB_USERNAME"), dbName,
 		stateChangeDir, consumerProgressDir, batchBytes, threadLimit,
-		logQueries, explorerStatistics, datadogProfiler, isTestnet, isRegtest, isAcceleratedRegtest, syncMempool)
+		logQueries, explorerStatistics, datadogProfiler, isTestnet, isRegtest, isAcceleratedRegtest, syncMempool, textSearchLanguage)
 
 	// Initialize the DB.
-	db, err := setupDb(pgURI, threadLimit, logQueries, readOnlyUserPassword, explorerStatistics)
+	db, err := setupDb(pgURI, threadLimit, logQueries, readOnlyUserPassword, explorerStatistics, textSearchLanguage)
 	if err != nil {
 		glog.Fatalf("Error setting up DB: %v", err)
 	}
 
+	// Promote the configured extra data keys to their own columns.
+	if extraDataPromotionsConfig := viper.GetString("EXTRA_DATA_PROMOTIONS_CONFIG"); extraDataPromotionsConfig != "" {
+		promotionConfig, err := handler.LoadExtraDataPromotionConfig(extraDataPromotionsConfig)
+		if err != nil {
+			glog.Fatalf("Error loading extra data promotions config: %v", err)
+		}
+		backfillExtraData := viper.GetBool("BACKFILL_EXTRA_DATA")
+		if err = handler.ApplyExtraDataPromotions(db, promotionConfig, backfillExtraData); err != nil {
+			glog.Fatalf("Error applying extra data promotions: %v", err)
+		}
+		// When backfilling, the promoted columns of existing rows are filled in and indexed above, so there's
+		// nothing left to do but refresh the planner statistics for the promoted tables.
+		if backfillExtraData {
+			if err = handler.AnalyzeExtraDataPromotionTables(db, promotionConfig); err != nil {
+				glog.Fatalf("Error analyzing extra data promotion tables: %v", err)
+			}
+			glog.Infof("Finished backfilling extra data promotions")
+			return
+		}
+	}
+
 	// Setup profiler if enabled.
 	if datadogProfiler {
 		tracer.Start()
@@ -119,7 +142,7 @@ func setupFlags() {
 	viper.AutomaticEnv()
 }
 
-func getConfigValues() (pgURI string, stateChangeDir string, consumerProgressDir string, batchBytes uint64, threadLimit int, logQueries bool, readonlyUserPassword string, explorerStatistics bool, datadogProfiler bool, isTestnet bool, isRegtest bool, isAcceleratedRegtest bool, syncMempool bool) {
+func getConfigValues() (pgURI string, stateChangeDir string, consumerProgressDir string, batchBytes uint64, threadLimit int, logQueries bool, readonlyUserPassword string, explorerStatistics bool, datadogProfiler bool, isTestnet bool, isRegtest bool, isAcceleratedRegtest bool, syncMempool bool, textSearchLanguage string) {
 
 	dbHost := viper.GetString("DB_HOST")
 	dbPort := viper.GetString("DB_PORT")
@@ -164,7 +187,13 @@ func getConfigValues() (pgURI string, stateChangeDir string, consumerProgressDir
 	isRegtest = viper.GetBool("REGTEST")
 	isAcceleratedRegtest = viper.GetBool("ACCELERATED_REGTEST")
 
-	return pgURI, stateChangeDir, consumerProgressDir, batchBytes, threadLimit, logQueries, readonlyUserPassword, explorerStatistics, datadogProfiler, isTestnet, isRegtest, isAcceleratedRegtest, syncMempool
+	// The text search configuration used to build and query the full-text search indexes.
+	textSearchLanguage = viper.GetString("TEXT_SEARCH_LANGUAGE")
+	if textSearchLanguage == "" {
+		textSearchLanguage = "english"
+	}
+
+	return pgURI, stateChangeDir, consumerProgressDir, batchBytes, threadLimit, logQueries, readonlyUserPassword, explorerStatistics, datadogProfiler, isTestnet, isRegtest, isAcceleratedRegtest, syncMempool, textSearchLanguage
 }
 
 type CustomQueryHook struct {
@@ -197,7 +226,7 @@ func (h *CustomQueryHook) AfterQuery(ctx context.Context, event *bun.QueryEvent)
 	h.QueryHook.AfterQuery(ctx, event)
 }
 
-func setupDb(pgURI string, threadLimit int, logQueries bool, readonlyUserPassword string, calculateExplorerStatistics bool) (*bun.DB, error) {
+func setupDb(pgURI string, threadLimit int, logQueries bool, readonlyUserPassword string, calculateExplorerStatistics bool, textSearchLanguage string) (*bun.DB, error) {
 	// Open a PostgreSQL database.
 	pgdb := sql.OpenDB(pgdriver.NewConnector(pgdriver.WithDSN(pgURI)))
 	if pgdb == nil {
@@ -224,6 +253,9 @@ func setupDb(pgURI string, threadLimit int, logQueries bool, readonlyUserPasswor
 
 	post_sync_migrations.SetCalculateExplorerStatistics(calculateExplorerStatistics)
 
+	// Set the text search language for the full-text search columns, indexes and functions.
+	migration_utils.SetTextSearchLanguage(textSearchLanguage)
+
 	// Apply db migrations.
 	err := handler.RunMigrations(db, false, handler.MigrationTypeInitial)
 	if err != nil {
@@ -0,0 +1,51 @@
+package initial_migrations
+
+import (
+	"context"
+	"strings"
+
+	"github.com/uptrace/bun"
+)
+
+func createNftSaleTable(db *bun.DB, tableName string) error {
+	_, err := db.Exec(strings.Replace(`
+			CREATE TABLE {tableName} (
+				transaction_hash VARCHAR PRIMARY KEY NOT NULL,
+				nft_post_hash VARCHAR NOT NULL,
+				serial_number BIGINT NOT NULL,
+				seller_public_key VARCHAR NOT NULL,
+				buyer_public_key VARCHAR NOT NULL,
+				sale_price_nanos BIGINT NOT NULL,
+				is_buy_now BOOLEAN NOT NULL,
+				creator_public_key VARCHAR,
+				creator_royalty_nanos BIGINT NOT NULL,
+				creator_coin_royalty_nanos BIGINT NOT NULL,
+				additional_deso_royalties JSONB,
+				additional_coin_royalties JSONB,
+				block_hash VARCHAR NOT NULL,
+				block_height BIGINT NOT NULL,
+				timestamp TIMESTAMP NOT NULL
+			);
+			CREATE INDEX {tableName}_nft_post_hash_serial_number_idx ON {tableName} (nft_post_hash, serial_number, timestamp desc);
+			CREATE INDEX {tableName}_seller_public_key_idx ON {tableName} (seller_public_key);
+			CREATE INDEX {tableName}_buyer_public_key_idx ON {tableName} (buyer_public_key);
+			CREATE INDEX {tableName}_creator_public_key_idx ON {tableName} (creator_public_key);
+			CREATE INDEX {tableName}_block_hash_idx ON {tableName} (block_hash);
+			CREATE INDEX {tableName}_timestamp_idx ON {tableName} (timestamp desc);
+		`, "{tableName}", tableName, -1))
+	return err
+}
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		return createNftSaleTable(db, "nft_sale")
+	}, func(ctx context.Context, db *bun.DB) error {
+		_, err := db.Exec(`
+			DROP TABLE IF EXISTS nft_sale;
+		`)
+		if err != nil {
+			return err
+		}
+		return nil
+	})
+}
@@ -0,0 +1,49 @@
+package initial_migrations
+
+import (
+	"context"
+	"strings"
+
+	"github.com/uptrace/bun"
+)
+
+func createCreatorCoinTradeTable(db *bun.DB, tableName string) error {
+	_, err := db.Exec(strings.Replace(`
+			CREATE TABLE {tableName} (
+				transaction_hash VARCHAR PRIMARY KEY NOT NULL,
+				trader_public_key VARCHAR NOT NULL,
+				creator_public_key VARCHAR NOT NULL,
+				direction VARCHAR NOT NULL,
+				deso_amount_nanos BIGINT NOT NULL,
+				creator_coin_amount_nanos BIGINT NOT NULL,
+				founder_reward_nanos BIGINT NOT NULL,
+				trading_fee_nanos BIGINT NOT NULL,
+				price_before_nanos DOUBLE PRECISION NOT NULL,
+				price_after_nanos DOUBLE PRECISION NOT NULL,
+				deso_locked_nanos_before BIGINT NOT NULL,
+				deso_locked_nanos_after BIGINT NOT NULL,
+				coins_in_circulation_nanos BIGINT NOT NULL,
+				block_hash VARCHAR NOT NULL,
+				block_height BIGINT NOT NULL,
+				timestamp TIMESTAMP NOT NULL
+			);
+			CREATE INDEX {tableName}_trader_public_key_creator_public_key_idx ON {tableName} (trader_public_key, creator_public_key, timestamp);
+			CREATE INDEX {tableName}_creator_public_key_idx ON {tableName} (creator_public_key, timestamp desc);
+			CREATE INDEX {tableName}_block_hash_idx ON {tableName} (block_hash);
+		`, "{tableName}", tableName, -1))
+	return err
+}
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		return createCreatorCoinTradeTable(db, "creator_coin_trade")
+	}, func(ctx context.Context, db *bun.DB) error {
+		_, err := db.Exec(`
+			DROP TABLE IF EXISTS creator_coin_trade;
+		`)
+		if err != nil {
+			return err
+		}
+		return nil
+	})
+}
@@ -0,0 +1,53 @@
+package initial_migrations
+
+import (
+	"context"
+	"strings"
+
+	"github.com/uptrace/bun"
+)
+
+// The deso balance history is partitioned by block height, in ranges of 10M blocks. Any heights past the last
+// range fall into the default partition until a new range is added.
+func createDesoBalanceHistoryTable(db *bun.DB, tableName string) error {
+	_, err := db.Exec(strings.Replace(`
+			CREATE TABLE {tableName} (
+				public_key      VARCHAR NOT NULL,
+				block_height    BIGINT NOT NULL,
+				block_hash      VARCHAR,
+				txn_hash        VARCHAR,
+				balance_nanos   BIGINT,
+				delta_nanos     BIGINT NOT NULL DEFAULT 0,
+				PRIMARY KEY (public_key, block_height)
+			) PARTITION BY RANGE (block_height);
+			CREATE INDEX {tableName}_block_hash_idx ON {tableName} (block_hash);
+			CREATE INDEX {tableName}_block_height_idx ON {tableName} (block_height);
+			CREATE INDEX {tableName}_txn_hash_idx ON {tableName} (txn_hash);
+			CREATE TABLE {tableName}_00 PARTITION OF {tableName} FOR VALUES FROM (0) TO (10000000);
+			CREATE TABLE {tableName}_01 PARTITION OF {tableName} FOR VALUES FROM (10000000) TO (20000000);
+			CREATE TABLE {tableName}_02 PARTITION OF {tableName} FOR VALUES FROM (20000000) TO (30000000);
+			CREATE TABLE {tableName}_03 PARTITION OF {tableName} FOR VALUES FROM (30000000) TO (40000000);
+			CREATE TABLE {tableName}_04 PARTITION OF {tableName} FOR VALUES FROM (40000000) TO (50000000);
+			CREATE TABLE {tableName}_05 PARTITION OF {tableName} FOR VALUES FROM (50000000) TO (60000000);
+			CREATE TABLE {tableName}_06 PARTITION OF {tableName} FOR VALUES FROM (60000000) TO (70000000);
+			CREATE TABLE {tableName}_07 PARTITION OF {tableName} FOR VALUES FROM (70000000) TO (80000000);
+			CREATE TABLE {tableName}_08 PARTITION OF {tableName} FOR VALUES FROM (80000000) TO (90000000);
+			CREATE TABLE {tableName}_09 PARTITION OF {tableName} FOR VALUES FROM (90000000) TO (100000000);
+			CREATE TABLE {tableName}_default PARTITION OF {tableName} DEFAULT;
+		`, "{tableName}", tableName, -1))
+	return err
+}
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		return createDesoBalanceHistoryTable(db, "deso_balance_history")
+	}, func(ctx context.Context, db *bun.DB) error {
+		_, err := db.Exec(`
+			DROP TABLE IF EXISTS deso_balance_history;
+		`)
+		if err != nil {
+			return err
+		}
+		return nil
+	})
+}
@@ -0,0 +1,41 @@
+package initial_migrations
+
+import (
+	"context"
+	"strings"
+
+	"github.com/uptrace/bun"
+)
+
+func createBalanceHistoryTable(db *bun.DB, tableName string) error {
+	_, err := db.Exec(strings.Replace(`
+			CREATE TABLE {tableName} (
+				hodler_pkid VARCHAR NOT NULL,
+				creator_pkid VARCHAR NOT NULL,
+				is_dao_coin BOOLEAN NOT NULL,
+				block_height BIGINT NOT NULL,
+				balance_nanos NUMERIC(78, 0) NOT NULL,
+				has_purchased BOOLEAN NOT NULL,
+				block_hash VARCHAR,
+				PRIMARY KEY (hodler_pkid, creator_pkid, is_dao_coin, block_height)
+			);
+			CREATE INDEX {tableName}_creator_pkid_is_dao_coin_block_height_idx ON {tableName} (creator_pkid, is_dao_coin, block_height desc);
+			CREATE INDEX {tableName}_block_height_idx ON {tableName} (block_height);
+			CREATE INDEX {tableName}_block_hash_idx ON {tableName} (block_hash);
+		`, "{tableName}", tableName, -1))
+	return err
+}
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		return createBalanceHistoryTable(db, "balance_history")
+	}, func(ctx context.Context, db *bun.DB) error {
+		_, err := db.Exec(`
+			DROP TABLE IF EXISTS balance_history;
+		`)
+		if err != nil {
+			return err
+		}
+		return nil
+	})
+}
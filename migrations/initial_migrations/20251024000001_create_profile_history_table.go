@@ -0,0 +1,46 @@
+package initial_migrations
+
+import (
+	"context"
+	"strings"
+
+	"github.com/uptrace/bun"
+)
+
+func createProfileHistoryTable(db *bun.DB, tableName string) error {
+	_, err := db.Exec(strings.Replace(`
+			CREATE TABLE {tableName} (
+				public_key VARCHAR NOT NULL,
+				block_height BIGINT NOT NULL,
+				pkid VARCHAR,
+				block_hash VARCHAR,
+				txn_hash VARCHAR,
+				changed_fields VARCHAR[] NOT NULL DEFAULT '{}',
+				previous_values JSONB NOT NULL DEFAULT '{}',
+				new_values JSONB NOT NULL DEFAULT '{}',
+				PRIMARY KEY (public_key, block_height)
+			);
+			CREATE INDEX {tableName}_pkid_idx ON {tableName} (pkid, block_height desc);
+			CREATE INDEX {tableName}_block_hash_idx ON {tableName} (block_hash);
+			CREATE INDEX {tableName}_block_height_idx ON {tableName} (block_height);
+			CREATE INDEX {tableName}_txn_hash_idx ON {tableName} (txn_hash);
+			CREATE INDEX {tableName}_changed_fields_idx ON {tableName} USING GIN (changed_fields);
+			CREATE INDEX {tableName}_previous_username_idx ON {tableName} (lower(previous_values->>'username'));
+			CREATE INDEX {tableName}_new_username_idx ON {tableName} (lower(new_values->>'username'));
+		`, "{tableName}", tableName, -1))
+	return err
+}
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		return createProfileHistoryTable(db, "profile_history")
+	}, func(ctx context.Context, db *bun.DB) error {
+		_, err := db.Exec(`
+			DROP TABLE IF EXISTS profile_history;
+		`)
+		if err != nil {
+			return err
+		}
+		return nil
+	})
+}
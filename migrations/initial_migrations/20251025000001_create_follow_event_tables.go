@@ -0,0 +1,76 @@
+package initial_migrations
+
+import (
+	"context"
+	"strings"
+
+	"github.com/uptrace/bun"
+)
+
+func createFollowEventTable(db *bun.DB, tableName string) error {
+	_, err := db.Exec(strings.Replace(`
+			CREATE TABLE {tableName} (
+				follower_pkid VARCHAR NOT NULL,
+				followed_pkid VARCHAR NOT NULL,
+				block_height BIGINT NOT NULL,
+				is_unfollow BOOLEAN NOT NULL,
+				block_hash VARCHAR,
+				txn_hash VARCHAR,
+				timestamp TIMESTAMP,
+				PRIMARY KEY (follower_pkid, followed_pkid, block_height)
+			);
+			CREATE INDEX {tableName}_followed_pkid_idx ON {tableName} (followed_pkid, block_height desc);
+			CREATE INDEX {tableName}_block_hash_idx ON {tableName} (block_hash);
+			CREATE INDEX {tableName}_block_height_idx ON {tableName} (block_height);
+			CREATE INDEX {tableName}_txn_hash_idx ON {tableName} (txn_hash);
+		`, "{tableName}", tableName, -1))
+	return err
+}
+
+func createFollowerCountDailyTable(db *bun.DB, tableName string) error {
+	_, err := db.Exec(strings.Replace(`
+			CREATE TABLE {tableName} (
+				pkid VARCHAR NOT NULL,
+				day DATE NOT NULL,
+				follows BIGINT NOT NULL DEFAULT 0,
+				unfollows BIGINT NOT NULL DEFAULT 0,
+				follower_count BIGINT NOT NULL DEFAULT 0,
+				PRIMARY KEY (pkid, day)
+			);
+			CREATE INDEX {tableName}_day_idx ON {tableName} (day);
+		`, "{tableName}", tableName, -1))
+	return err
+}
+
+// Follows stored before follow events were recorded are backfilled as follows at the latest stored block, so that
+// the daily follower counts backfilled from follow events start from the current followers.
+func backfillFollowEvents(db *bun.DB, tableName string) error {
+	_, err := db.Exec(strings.Replace(`
+			INSERT INTO {tableName} (follower_pkid, followed_pkid, block_height, is_unfollow)
+			SELECT follower_pkid, followed_pkid, COALESCE((SELECT MAX(height) FROM block), 0), false
+			FROM follow_entry
+			ON CONFLICT DO NOTHING;
+		`, "{tableName}", tableName, -1))
+	return err
+}
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		if err := createFollowEventTable(db, "follow_event"); err != nil {
+			return err
+		}
+		if err := backfillFollowEvents(db, "follow_event"); err != nil {
+			return err
+		}
+		return createFollowerCountDailyTable(db, "follower_count_daily")
+	}, func(ctx context.Context, db *bun.DB) error {
+		_, err := db.Exec(`
+			DROP TABLE IF EXISTS follow_event;
+			DROP TABLE IF EXISTS follower_count_daily;
+		`)
+		if err != nil {
+			return err
+		}
+		return nil
+	})
+}
@@ -0,0 +1,46 @@
+package initial_migrations
+
+import (
+	"context"
+	"strings"
+
+	"github.com/uptrace/bun"
+)
+
+func createPostRevisionTable(db *bun.DB, tableName string) error {
+	_, err := db.Exec(strings.Replace(`
+			CREATE TABLE {tableName} (
+				post_hash VARCHAR NOT NULL,
+				block_height BIGINT NOT NULL,
+				revision_number BIGINT,
+				body TEXT,
+				image_urls VARCHAR[],
+				video_urls VARCHAR[],
+				extra_data JSONB,
+				editor_public_key VARCHAR,
+				block_hash VARCHAR,
+				txn_hash VARCHAR,
+				timestamp TIMESTAMP,
+				PRIMARY KEY (post_hash, block_height)
+			);
+			CREATE UNIQUE INDEX {tableName}_post_hash_revision_number_idx ON {tableName} (post_hash, revision_number);
+			CREATE INDEX {tableName}_block_hash_idx ON {tableName} (block_hash);
+			CREATE INDEX {tableName}_block_height_idx ON {tableName} (block_height);
+			CREATE INDEX {tableName}_txn_hash_idx ON {tableName} (txn_hash);
+		`, "{tableName}", tableName, -1))
+	return err
+}
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		return createPostRevisionTable(db, "post_revision")
+	}, func(ctx context.Context, db *bun.DB) error {
+		_, err := db.Exec(`
+			DROP TABLE IF EXISTS post_revision;
+		`)
+		if err != nil {
+			return err
+		}
+		return nil
+	})
+}
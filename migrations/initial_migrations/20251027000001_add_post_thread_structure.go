@@ -0,0 +1,60 @@
+package initial_migrations
+
+import (
+	"context"
+	"strings"
+
+	"github.com/deso-protocol/postgres-data-handler/migrations/migration_utils"
+	"github.com/uptrace/bun"
+)
+
+func addPostThreadColumns(db *bun.DB, tableName string) error {
+	_, err := db.Exec(strings.Replace(`
+			ALTER TABLE {tableName}
+			ADD COLUMN root_post_hash VARCHAR,
+			ADD COLUMN depth BIGINT NOT NULL DEFAULT 0;
+			CREATE INDEX {tableName}_root_post_hash_idx ON {tableName} (root_post_hash, depth, timestamp);
+		`, "{tableName}", tableName, -1))
+	return err
+}
+
+func createPostStatsTable(db *bun.DB, tableName string) error {
+	_, err := db.Exec(strings.Replace(`
+			CREATE TABLE {tableName} (
+				post_hash VARCHAR PRIMARY KEY NOT NULL,
+				direct_reply_count BIGINT NOT NULL DEFAULT 0,
+				total_reply_count BIGINT NOT NULL DEFAULT 0,
+				repost_count BIGINT NOT NULL DEFAULT 0,
+				quote_repost_count BIGINT NOT NULL DEFAULT 0,
+				like_count BIGINT NOT NULL DEFAULT 0,
+				diamond_count BIGINT NOT NULL DEFAULT 0
+			);
+			CREATE INDEX {tableName}_total_reply_count_idx ON {tableName} (total_reply_count desc);
+			CREATE INDEX {tableName}_like_count_idx ON {tableName} (like_count desc);
+		`, "{tableName}", tableName, -1))
+	return err
+}
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		if err := addPostThreadColumns(db, "post_entry"); err != nil {
+			return err
+		}
+		if err := createPostStatsTable(db, "post_stats"); err != nil {
+			return err
+		}
+		return migration_utils.BackfillPostThreadStructure(ctx, db)
+	}, func(ctx context.Context, db *bun.DB) error {
+		_, err := db.Exec(`
+			DROP TABLE IF EXISTS post_stats;
+			DROP INDEX IF EXISTS post_entry_root_post_hash_idx;
+			ALTER TABLE post_entry
+			DROP COLUMN root_post_hash,
+			DROP COLUMN depth;
+		`)
+		if err != nil {
+			return err
+		}
+		return nil
+	})
+}
@@ -0,0 +1,45 @@
+package initial_migrations
+
+import (
+	"context"
+	"strings"
+
+	"github.com/deso-protocol/postgres-data-handler/migrations/migration_utils"
+	"github.com/uptrace/bun"
+)
+
+// The search columns are generated from the text they index, so the handler never has to write them. Usernames are
+// indexed with the simple configuration, as they shouldn't be stemmed or have stop words removed. The GIN indexes on
+// the columns are created after the initial sync, so the sync doesn't pay to maintain them.
+func addFullTextSearchColumns(db *bun.DB, language string) error {
+	_, err := db.Exec(strings.Replace(`
+			ALTER TABLE post_entry
+			ADD COLUMN body_tsv TSVECTOR GENERATED ALWAYS AS (to_tsvector('{language}'::regconfig, COALESCE(body, ''))) STORED;
+
+			ALTER TABLE profile_entry
+			ADD COLUMN description_tsv TSVECTOR GENERATED ALWAYS AS (to_tsvector('{language}'::regconfig, COALESCE(description, ''))) STORED,
+			ADD COLUMN username_tsv TSVECTOR GENERATED ALWAYS AS (to_tsvector('simple'::regconfig, COALESCE(username, ''))) STORED;
+		`, "{language}", language, -1))
+	return err
+}
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		language, err := migration_utils.GetTextSearchLanguage()
+		if err != nil {
+			return err
+		}
+		return addFullTextSearchColumns(db, language)
+	}, func(ctx context.Context, db *bun.DB) error {
+		_, err := db.Exec(`
+			ALTER TABLE post_entry DROP COLUMN IF EXISTS body_tsv;
+			ALTER TABLE profile_entry
+			DROP COLUMN IF EXISTS description_tsv,
+			DROP COLUMN IF EXISTS username_tsv;
+		`)
+		if err != nil {
+			return err
+		}
+		return nil
+	})
+}
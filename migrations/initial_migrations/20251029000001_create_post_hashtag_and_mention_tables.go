@@ -0,0 +1,94 @@
+package initial_migrations
+
+import (
+	"context"
+	"strings"
+
+	"github.com/uptrace/bun"
+)
+
+func createPostHashtagTable(db *bun.DB, tableName string) error {
+	_, err := db.Exec(strings.Replace(`
+			CREATE TABLE {tableName} (
+				post_hash VARCHAR NOT NULL,
+				tag VARCHAR NOT NULL,
+				timestamp TIMESTAMP NOT NULL,
+				PRIMARY KEY (post_hash, tag)
+			);
+			CREATE INDEX {tableName}_tag_timestamp_idx ON {tableName} (tag, timestamp desc);
+			CREATE INDEX {tableName}_timestamp_idx ON {tableName} (timestamp desc);
+		`, "{tableName}", tableName, -1))
+	return err
+}
+
+func createPostMentionTable(db *bun.DB, tableName string) error {
+	_, err := db.Exec(strings.Replace(`
+			CREATE TABLE {tableName} (
+				post_hash VARCHAR NOT NULL,
+				mentioned_username VARCHAR NOT NULL,
+				mentioned_public_key VARCHAR,
+				PRIMARY KEY (post_hash, mentioned_username)
+			);
+			CREATE INDEX {tableName}_mentioned_public_key_idx ON {tableName} (mentioned_public_key);
+			CREATE INDEX {tableName}_mentioned_username_lower_idx ON {tableName} (LOWER(mentioned_username));
+		`, "{tableName}", tableName, -1))
+	return err
+}
+
+// The backfills extract hashtags and mentions from the posts already stored, with the same rules the handler uses
+// for new posts. Posts stored from here on are handled by the post batch operation.
+func backfillPostHashtags(db *bun.DB, tableName string) error {
+	_, err := db.Exec(strings.Replace(`
+			INSERT INTO {tableName} (post_hash, tag, timestamp)
+			SELECT DISTINCT post_entry.post_hash, LOWER(hashtag.captures[1]), post_entry.timestamp
+			FROM post_entry
+			CROSS JOIN LATERAL regexp_matches(post_entry.body, '(?:^|[^[:alnum:]_&/])#([[:alnum:]_]+)', 'g') AS hashtag (captures)
+			WHERE post_entry.body LIKE '%#%' AND NOT COALESCE(post_entry.is_hidden, false)
+				AND hashtag.captures[1] !~ '^[0-9]+$'
+			ON CONFLICT DO NOTHING;
+		`, "{tableName}", tableName, -1))
+	return err
+}
+
+func backfillPostMentions(db *bun.DB, tableName string) error {
+	_, err := db.Exec(strings.Replace(`
+			INSERT INTO {tableName} (post_hash, mentioned_username, mentioned_public_key)
+			SELECT DISTINCT ON (mention.post_hash, LOWER(mention.username))
+				mention.post_hash, COALESCE(profile_entry.username, mention.username), profile_entry.public_key
+			FROM (
+				SELECT post_entry.post_hash, match.captures[1] AS username, match.position
+				FROM post_entry
+				CROSS JOIN LATERAL regexp_matches(post_entry.body, '(?:^|[^A-Za-z0-9_])@([A-Za-z0-9_]{1,25})', 'g')
+					WITH ORDINALITY AS match (captures, position)
+				WHERE post_entry.body LIKE '%@%' AND NOT COALESCE(post_entry.is_hidden, false)
+			) AS mention
+			LEFT JOIN profile_entry ON LOWER(profile_entry.username) = LOWER(mention.username)
+			ORDER BY mention.post_hash, LOWER(mention.username), mention.position
+			ON CONFLICT DO NOTHING;
+		`, "{tableName}", tableName, -1))
+	return err
+}
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		if err := createPostHashtagTable(db, "post_hashtag"); err != nil {
+			return err
+		}
+		if err := createPostMentionTable(db, "post_mention"); err != nil {
+			return err
+		}
+		if err := backfillPostHashtags(db, "post_hashtag"); err != nil {
+			return err
+		}
+		return backfillPostMentions(db, "post_mention")
+	}, func(ctx context.Context, db *bun.DB) error {
+		_, err := db.Exec(`
+			DROP TABLE IF EXISTS post_hashtag;
+			DROP TABLE IF EXISTS post_mention;
+		`)
+		if err != nil {
+			return err
+		}
+		return nil
+	})
+}
@@ -0,0 +1,99 @@
+package initial_migrations
+
+import (
+	"context"
+	"strings"
+
+	"github.com/uptrace/bun"
+)
+
+func createPostMediaTable(db *bun.DB, tableName string) error {
+	_, err := db.Exec(strings.Replace(`
+			CREATE TABLE {tableName} (
+				post_hash VARCHAR NOT NULL,
+				media_type VARCHAR NOT NULL,
+				position BIGINT NOT NULL,
+				url TEXT NOT NULL,
+				host VARCHAR,
+				is_deso_image BOOLEAN NOT NULL DEFAULT false,
+				is_livepeer BOOLEAN NOT NULL DEFAULT false,
+				is_youtube BOOLEAN NOT NULL DEFAULT false,
+				width BIGINT,
+				height BIGINT,
+				duration_seconds DOUBLE PRECISION,
+				poster_public_key VARCHAR NOT NULL,
+				timestamp TIMESTAMP NOT NULL,
+				PRIMARY KEY (post_hash, media_type, position)
+			);
+			CREATE INDEX {tableName}_poster_public_key_media_type_idx ON {tableName} (poster_public_key, media_type, timestamp desc);
+			CREATE INDEX {tableName}_media_type_timestamp_idx ON {tableName} (media_type, timestamp desc);
+			CREATE INDEX {tableName}_host_idx ON {tableName} (host);
+		`, "{tableName}", tableName, -1))
+	return err
+}
+
+// backfillPostMedia extracts the media of the posts already stored, with the same rules the handler uses for new
+// posts. Dimensions and durations apply to the first media of their type.
+func backfillPostMedia(db *bun.DB, tableName string) error {
+	_, err := db.Exec(strings.Replace(`
+			WITH media AS (
+				SELECT post_entry.post_hash, 'image' AS media_type, image.position - 1 AS position, image.url,
+					post_entry.extra_data, post_entry.poster_public_key, post_entry.timestamp
+				FROM post_entry
+				CROSS JOIN LATERAL unnest(post_entry.image_urls) WITH ORDINALITY AS image (url, position)
+				WHERE image.url IS NOT NULL AND NOT COALESCE(post_entry.is_hidden, false)
+				UNION ALL
+				SELECT post_entry.post_hash, 'video', video.position - 1, video.url,
+					post_entry.extra_data, post_entry.poster_public_key, post_entry.timestamp
+				FROM post_entry
+				CROSS JOIN LATERAL unnest(post_entry.video_urls) WITH ORDINALITY AS video (url, position)
+				WHERE video.url IS NOT NULL AND NOT COALESCE(post_entry.is_hidden, false)
+				UNION ALL
+				SELECT post_entry.post_hash, 'embed', 0, post_entry.extra_data ->> 'EmbedVideoURL',
+					post_entry.extra_data, post_entry.poster_public_key, post_entry.timestamp
+				FROM post_entry
+				WHERE COALESCE(post_entry.extra_data ->> 'EmbedVideoURL', '') <> '' AND NOT COALESCE(post_entry.is_hidden, false)
+			), media_host AS (
+				SELECT media.*,
+					NULLIF(regexp_replace(LOWER(substring(TRIM(media.url) FROM '^[A-Za-z][A-Za-z0-9+.-]*://(?:[^/?#@]*@)?([^/?#:]+)')), '^www\.', ''), '') AS host,
+					TRIM(media.extra_data ->> CASE media.media_type WHEN 'image' THEN 'ImageWidth' WHEN 'video' THEN 'VideoWidth' END) AS width,
+					TRIM(media.extra_data ->> CASE media.media_type WHEN 'image' THEN 'ImageHeight' WHEN 'video' THEN 'VideoHeight' END) AS height,
+					TRIM(media.extra_data ->> CASE media.media_type WHEN 'video' THEN 'VideoDuration' END) AS duration_seconds
+				FROM media
+			)
+			INSERT INTO {tableName} (
+				post_hash, media_type, position, url, host, is_deso_image, is_livepeer, is_youtube,
+				width, height, duration_seconds, poster_public_key, timestamp
+			)
+			SELECT
+				post_hash, media_type, position, url, host,
+				COALESCE(host = 'images.deso.org', false),
+				COALESCE(host = 'lvpr.tv' OR host ~ '(^|\.)(livepeer\.studio|livepeercdn\.com|livepeer\.com)$', false),
+				COALESCE(host = 'youtu.be' OR host ~ '(^|\.)youtube\.com$', false),
+				CASE WHEN position = 0 AND width ~ '^[0-9]{1,18}$' THEN NULLIF(width::BIGINT, 0) END,
+				CASE WHEN position = 0 AND height ~ '^[0-9]{1,18}$' THEN NULLIF(height::BIGINT, 0) END,
+				CASE WHEN position = 0 AND duration_seconds ~ '^[-+]?([0-9]+\.?[0-9]*|\.[0-9]+)([eE][-+]?[0-9]+)?$'
+					THEN NULLIF(duration_seconds::DOUBLE PRECISION, 0) END,
+				poster_public_key, timestamp
+			FROM media_host
+			ON CONFLICT DO NOTHING;
+		`, "{tableName}", tableName, -1))
+	return err
+}
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		if err := createPostMediaTable(db, "post_media"); err != nil {
+			return err
+		}
+		return backfillPostMedia(db, "post_media")
+	}, func(ctx context.Context, db *bun.DB) error {
+		_, err := db.Exec(`
+			DROP TABLE IF EXISTS post_media;
+		`)
+		if err != nil {
+			return err
+		}
+		return nil
+	})
+}
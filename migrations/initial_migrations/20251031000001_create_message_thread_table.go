@@ -0,0 +1,112 @@
+package initial_migrations
+
+import (
+	"context"
+	"strings"
+
+	"github.com/uptrace/bun"
+)
+
+// The thread key of each message. DM participants are ordered bytewise, which matches the ordering used by the
+// handler when it computes the thread key of new messages.
+const newMessageThreadKeyExpression = `
+	CASE
+		WHEN is_group_chat_message THEN
+			'group:' || COALESCE(recipient_access_group_owner_public_key, '') || ':' || COALESCE(recipient_access_group_key_name, '')
+		WHEN (COALESCE(sender_access_group_owner_public_key, '') || ':' || COALESCE(sender_access_group_key_name, '')) COLLATE "C" <=
+			(COALESCE(recipient_access_group_owner_public_key, '') || ':' || COALESCE(recipient_access_group_key_name, '')) COLLATE "C" THEN
+			'dm:' || COALESCE(sender_access_group_owner_public_key, '') || ':' || COALESCE(sender_access_group_key_name, '') ||
+			'|' || COALESCE(recipient_access_group_owner_public_key, '') || ':' || COALESCE(recipient_access_group_key_name, '')
+		ELSE
+			'dm:' || COALESCE(recipient_access_group_owner_public_key, '') || ':' || COALESCE(recipient_access_group_key_name, '') ||
+			'|' || COALESCE(sender_access_group_owner_public_key, '') || ':' || COALESCE(sender_access_group_key_name, '')
+	END
+`
+
+func createMessageThreadTable(db *bun.DB, tableName string) error {
+	_, err := db.Exec(strings.Replace(`
+			ALTER TABLE new_message_entry ADD COLUMN thread_key VARCHAR;
+			UPDATE new_message_entry SET thread_key = `+newMessageThreadKeyExpression+`;
+			CREATE INDEX new_message_entry_thread_key_timestamp_idx ON new_message_entry (thread_key, timestamp desc);
+
+			CREATE TABLE {tableName} (
+				thread_key VARCHAR PRIMARY KEY NOT NULL,
+				is_group_chat BOOLEAN NOT NULL,
+				access_group_owner_public_key VARCHAR,
+				access_group_key_name VARCHAR,
+				party_access_group_owner_public_key VARCHAR,
+				party_access_group_key_name VARCHAR,
+				participant_public_keys VARCHAR[] NOT NULL DEFAULT '{}',
+				last_message_timestamp TIMESTAMP NOT NULL,
+				last_message_sender_public_key VARCHAR,
+				message_count BIGINT NOT NULL
+			);
+			CREATE INDEX {tableName}_participant_public_keys_idx ON {tableName} USING GIN (participant_public_keys);
+			CREATE INDEX {tableName}_last_message_timestamp_idx ON {tableName} (last_message_timestamp desc);
+			CREATE INDEX {tableName}_access_group_idx ON {tableName} (access_group_owner_public_key, access_group_key_name);
+			CREATE INDEX {tableName}_party_access_group_idx ON {tableName} (party_access_group_owner_public_key, party_access_group_key_name);
+
+			WITH last_message AS (
+				SELECT DISTINCT ON (thread_key)
+					thread_key, is_group_chat_message, timestamp,
+					sender_access_group_owner_public_key, sender_access_group_key_name,
+					recipient_access_group_owner_public_key, recipient_access_group_key_name,
+					(COALESCE(sender_access_group_owner_public_key, '') || ':' || COALESCE(sender_access_group_key_name, '')) COLLATE "C" <=
+						(COALESCE(recipient_access_group_owner_public_key, '') || ':' || COALESCE(recipient_access_group_key_name, '')) COLLATE "C" AS sender_sorts_first
+				FROM new_message_entry
+				ORDER BY thread_key, timestamp desc
+			), thread_count AS (
+				SELECT thread_key, COUNT(*) AS message_count FROM new_message_entry GROUP BY thread_key
+			), thread_participants AS (
+				SELECT new_message_entry.thread_key,
+					ARRAY_AGG(DISTINCT participant.public_key ORDER BY participant.public_key) AS participant_public_keys
+				FROM new_message_entry
+				CROSS JOIN LATERAL (VALUES
+					(new_message_entry.sender_access_group_owner_public_key),
+					(CASE WHEN new_message_entry.is_group_chat_message THEN NULL ELSE new_message_entry.recipient_access_group_owner_public_key END)
+				) AS participant (public_key)
+				WHERE participant.public_key IS NOT NULL
+				GROUP BY new_message_entry.thread_key
+			)
+			INSERT INTO {tableName} (
+				thread_key, is_group_chat, access_group_owner_public_key, access_group_key_name,
+				party_access_group_owner_public_key, party_access_group_key_name, participant_public_keys,
+				last_message_timestamp, last_message_sender_public_key, message_count
+			)
+			SELECT
+				last_message.thread_key,
+				last_message.is_group_chat_message,
+				CASE WHEN last_message.is_group_chat_message OR NOT last_message.sender_sorts_first
+					THEN last_message.recipient_access_group_owner_public_key ELSE last_message.sender_access_group_owner_public_key END,
+				CASE WHEN last_message.is_group_chat_message OR NOT last_message.sender_sorts_first
+					THEN last_message.recipient_access_group_key_name ELSE last_message.sender_access_group_key_name END,
+				CASE WHEN last_message.is_group_chat_message THEN NULL WHEN last_message.sender_sorts_first
+					THEN last_message.recipient_access_group_owner_public_key ELSE last_message.sender_access_group_owner_public_key END,
+				CASE WHEN last_message.is_group_chat_message THEN NULL WHEN last_message.sender_sorts_first
+					THEN last_message.recipient_access_group_key_name ELSE last_message.sender_access_group_key_name END,
+				COALESCE(thread_participants.participant_public_keys, '{}'),
+				last_message.timestamp,
+				last_message.sender_access_group_owner_public_key,
+				thread_count.message_count
+			FROM last_message
+			JOIN thread_count USING (thread_key)
+			LEFT JOIN thread_participants USING (thread_key);
+		`, "{tableName}", tableName, -1))
+	return err
+}
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		return createMessageThreadTable(db, "message_thread")
+	}, func(ctx context.Context, db *bun.DB) error {
+		_, err := db.Exec(`
+			DROP TABLE IF EXISTS message_thread;
+			DROP INDEX IF EXISTS new_message_entry_thread_key_timestamp_idx;
+			ALTER TABLE new_message_entry DROP COLUMN IF EXISTS thread_key;
+		`)
+		if err != nil {
+			return err
+		}
+		return nil
+	})
+}
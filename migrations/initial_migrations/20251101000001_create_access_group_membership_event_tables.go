@@ -0,0 +1,68 @@
+package initial_migrations
+
+import (
+	"context"
+	"strings"
+
+	"github.com/uptrace/bun"
+)
+
+func createAccessGroupMembershipEventTable(db *bun.DB, tableName string) error {
+	_, err := db.Exec(strings.Replace(`
+			CREATE TABLE {tableName} (
+				access_group_owner_public_key VARCHAR NOT NULL,
+				access_group_key_name VARCHAR NOT NULL,
+				access_group_member_public_key VARCHAR NOT NULL,
+				block_height BIGINT NOT NULL,
+				access_group_member_key_name VARCHAR,
+				event_type VARCHAR NOT NULL,
+				actor_public_key VARCHAR,
+				block_hash VARCHAR,
+				txn_hash VARCHAR,
+				timestamp TIMESTAMP,
+				PRIMARY KEY (access_group_owner_public_key, access_group_key_name, access_group_member_public_key, block_height)
+			);
+			CREATE INDEX {tableName}_group_block_height_idx ON {tableName} (access_group_owner_public_key, access_group_key_name, block_height desc);
+			CREATE INDEX {tableName}_member_public_key_idx ON {tableName} (access_group_member_public_key, block_height desc);
+			CREATE INDEX {tableName}_block_hash_idx ON {tableName} (block_hash);
+			CREATE INDEX {tableName}_block_height_idx ON {tableName} (block_height);
+			CREATE INDEX {tableName}_txn_hash_idx ON {tableName} (txn_hash);
+		`, "{tableName}", tableName, -1))
+	return err
+}
+
+func createAccessGroupMemberCountTable(db *bun.DB, tableName string) error {
+	_, err := db.Exec(strings.Replace(`
+			CREATE TABLE {tableName} (
+				access_group_owner_public_key VARCHAR NOT NULL,
+				access_group_key_name VARCHAR NOT NULL,
+				member_count BIGINT NOT NULL DEFAULT 0,
+				PRIMARY KEY (access_group_owner_public_key, access_group_key_name)
+			);
+			CREATE INDEX {tableName}_member_count_idx ON {tableName} (member_count desc);
+
+			INSERT INTO {tableName} (access_group_owner_public_key, access_group_key_name, member_count)
+			SELECT access_group_owner_public_key, access_group_key_name, COUNT(*)
+			FROM access_group_member_entry
+			GROUP BY access_group_owner_public_key, access_group_key_name;
+		`, "{tableName}", tableName, -1))
+	return err
+}
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		if err := createAccessGroupMembershipEventTable(db, "access_group_membership_event"); err != nil {
+			return err
+		}
+		return createAccessGroupMemberCountTable(db, "access_group_member_count")
+	}, func(ctx context.Context, db *bun.DB) error {
+		_, err := db.Exec(`
+			DROP TABLE IF EXISTS access_group_membership_event;
+			DROP TABLE IF EXISTS access_group_member_count;
+		`)
+		if err != nil {
+			return err
+		}
+		return nil
+	})
+}
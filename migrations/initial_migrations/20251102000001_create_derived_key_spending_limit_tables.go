@@ -0,0 +1,231 @@
+package initial_migrations
+
+import (
+	"context"
+	"strings"
+
+	"github.com/uptrace/bun"
+)
+
+// Each spending limit table is created and then backfilled from the transaction_spending_limits column of the
+// existing derived keys. After that, the handler keeps the tables in sync as derived keys are updated.
+var derivedKeySpendingLimitTables = []struct {
+	tableName string
+	query     string
+}{
+	{"derived_key_txn_count_limit", `
+			CREATE TABLE {tableName} (
+				owner_public_key VARCHAR NOT NULL,
+				derived_public_key VARCHAR NOT NULL,
+				txn_type VARCHAR NOT NULL,
+				op_count BIGINT NOT NULL,
+				PRIMARY KEY (owner_public_key, derived_public_key, txn_type)
+			);
+			CREATE INDEX {tableName}_txn_type_idx ON {tableName} (txn_type);
+
+			INSERT INTO {tableName} (owner_public_key, derived_public_key, txn_type, op_count)
+			SELECT dk.owner_public_key, dk.derived_public_key, limits.key, limits.value::BIGINT
+			FROM derived_key_entry dk,
+				jsonb_each_text(CASE WHEN jsonb_typeof(dk.transaction_spending_limits -> 'TransactionCountLimitMap') = 'object' THEN dk.transaction_spending_limits -> 'TransactionCountLimitMap' ELSE '{}' END) limits
+			ON CONFLICT DO NOTHING;
+		`},
+	{"derived_key_creator_coin_limit", `
+			CREATE TABLE {tableName} (
+				owner_public_key VARCHAR NOT NULL,
+				derived_public_key VARCHAR NOT NULL,
+				creator_public_key VARCHAR NOT NULL,
+				operation VARCHAR NOT NULL,
+				op_count BIGINT NOT NULL,
+				PRIMARY KEY (owner_public_key, derived_public_key, creator_public_key, operation)
+			);
+			CREATE INDEX {tableName}_creator_public_key_idx ON {tableName} (creator_public_key, operation);
+
+			INSERT INTO {tableName} (owner_public_key, derived_public_key, creator_public_key, operation, op_count)
+			SELECT dk.owner_public_key, dk.derived_public_key, creators.key, operations.key, operations.value::BIGINT
+			FROM derived_key_entry dk,
+				jsonb_each(CASE WHEN jsonb_typeof(dk.transaction_spending_limits -> 'CreatorCoinOperationLimitMap') = 'object' THEN dk.transaction_spending_limits -> 'CreatorCoinOperationLimitMap' ELSE '{}' END) creators,
+				jsonb_each_text(CASE WHEN jsonb_typeof(creators.value) = 'object' THEN creators.value ELSE '{}' END) operations
+			ON CONFLICT DO NOTHING;
+		`},
+	{"derived_key_dao_coin_limit", `
+			CREATE TABLE {tableName} (
+				owner_public_key VARCHAR NOT NULL,
+				derived_public_key VARCHAR NOT NULL,
+				creator_public_key VARCHAR NOT NULL,
+				operation VARCHAR NOT NULL,
+				op_count BIGINT NOT NULL,
+				PRIMARY KEY (owner_public_key, derived_public_key, creator_public_key, operation)
+			);
+			CREATE INDEX {tableName}_creator_public_key_idx ON {tableName} (creator_public_key, operation);
+
+			INSERT INTO {tableName} (owner_public_key, derived_public_key, creator_public_key, operation, op_count)
+			SELECT dk.owner_public_key, dk.derived_public_key, creators.key, operations.key, operations.value::BIGINT
+			FROM derived_key_entry dk,
+				jsonb_each(CASE WHEN jsonb_typeof(dk.transaction_spending_limits -> 'DAOCoinOperationLimitMap') = 'object' THEN dk.transaction_spending_limits -> 'DAOCoinOperationLimitMap' ELSE '{}' END) creators,
+				jsonb_each_text(CASE WHEN jsonb_typeof(creators.value) = 'object' THEN creators.value ELSE '{}' END) operations
+			ON CONFLICT DO NOTHING;
+		`},
+	{"derived_key_nft_limit", `
+			CREATE TABLE {tableName} (
+				owner_public_key VARCHAR NOT NULL,
+				derived_public_key VARCHAR NOT NULL,
+				nft_post_hash VARCHAR NOT NULL,
+				serial_number BIGINT NOT NULL,
+				operation VARCHAR NOT NULL,
+				op_count BIGINT NOT NULL,
+				PRIMARY KEY (owner_public_key, derived_public_key, nft_post_hash, serial_number, operation)
+			);
+			CREATE INDEX {tableName}_operation_idx ON {tableName} (operation, nft_post_hash);
+
+			INSERT INTO {tableName} (owner_public_key, derived_public_key, nft_post_hash, serial_number, operation, op_count)
+			SELECT dk.owner_public_key, dk.derived_public_key, posts.key, serial_numbers.key::BIGINT, operations.key, operations.value::BIGINT
+			FROM derived_key_entry dk,
+				jsonb_each(CASE WHEN jsonb_typeof(dk.transaction_spending_limits -> 'NFTOperationLimitMap') = 'object' THEN dk.transaction_spending_limits -> 'NFTOperationLimitMap' ELSE '{}' END) posts,
+				jsonb_each(CASE WHEN jsonb_typeof(posts.value) = 'object' THEN posts.value ELSE '{}' END) serial_numbers,
+				jsonb_each_text(CASE WHEN jsonb_typeof(serial_numbers.value) = 'object' THEN serial_numbers.value ELSE '{}' END) operations
+			ON CONFLICT DO NOTHING;
+		`},
+	{"derived_key_dao_coin_limit_order_limit", `
+			CREATE TABLE {tableName} (
+				owner_public_key VARCHAR NOT NULL,
+				derived_public_key VARCHAR NOT NULL,
+				buying_dao_coin_creator_public_key VARCHAR NOT NULL,
+				selling_dao_coin_creator_public_key VARCHAR NOT NULL,
+				op_count BIGINT NOT NULL,
+				PRIMARY KEY (owner_public_key, derived_public_key, buying_dao_coin_creator_public_key, selling_dao_coin_creator_public_key)
+			);
+			CREATE INDEX {tableName}_coin_pair_idx ON {tableName} (buying_dao_coin_creator_public_key, selling_dao_coin_creator_public_key);
+
+			INSERT INTO {tableName} (owner_public_key, derived_public_key, buying_dao_coin_creator_public_key, selling_dao_coin_creator_public_key, op_count)
+			SELECT dk.owner_public_key, dk.derived_public_key, buying.key, selling.key, selling.value::BIGINT
+			FROM derived_key_entry dk,
+				jsonb_each(CASE WHEN jsonb_typeof(dk.transaction_spending_limits -> 'DAOCoinLimitOrderLimitMap') = 'object' THEN dk.transaction_spending_limits -> 'DAOCoinLimitOrderLimitMap' ELSE '{}' END) buying,
+				jsonb_each_text(CASE WHEN jsonb_typeof(buying.value) = 'object' THEN buying.value ELSE '{}' END) selling
+			ON CONFLICT DO NOTHING;
+		`},
+	{"derived_key_association_limit", `
+			CREATE TABLE {tableName} (
+				owner_public_key VARCHAR NOT NULL,
+				derived_public_key VARCHAR NOT NULL,
+				association_class VARCHAR NOT NULL,
+				association_type VARCHAR NOT NULL,
+				app_scope_type VARCHAR NOT NULL,
+				app_public_key VARCHAR NOT NULL,
+				operation VARCHAR NOT NULL,
+				op_count BIGINT NOT NULL,
+				PRIMARY KEY (owner_public_key, derived_public_key, association_class, association_type, app_scope_type, app_public_key, operation)
+			);
+			CREATE INDEX {tableName}_association_type_idx ON {tableName} (association_class, association_type, operation);
+			CREATE INDEX {tableName}_app_public_key_idx ON {tableName} (app_public_key);
+
+			INSERT INTO {tableName} (owner_public_key, derived_public_key, association_class, association_type, app_scope_type, app_public_key, operation, op_count)
+			SELECT dk.owner_public_key, dk.derived_public_key,
+				COALESCE(limits ->> 'AssociationClass', ''), COALESCE(limits ->> 'AssociationType', ''),
+				COALESCE(limits ->> 'AppScopeType', ''), COALESCE(limits ->> 'AppPublicKeyBase58Check', ''),
+				COALESCE(limits ->> 'AssociationOperation', ''), COALESCE((limits ->> 'OpCount')::BIGINT, 0)
+			FROM derived_key_entry dk,
+				jsonb_array_elements(CASE WHEN jsonb_typeof(dk.transaction_spending_limits -> 'AssociationLimitMap') = 'array' THEN dk.transaction_spending_limits -> 'AssociationLimitMap' ELSE '[]' END) limits
+			ON CONFLICT DO NOTHING;
+		`},
+	{"derived_key_access_group_limit", `
+			CREATE TABLE {tableName} (
+				owner_public_key VARCHAR NOT NULL,
+				derived_public_key VARCHAR NOT NULL,
+				access_group_owner_public_key VARCHAR NOT NULL,
+				access_group_key_name VARCHAR NOT NULL,
+				scope_type VARCHAR NOT NULL,
+				is_member_operation BOOLEAN NOT NULL,
+				operation VARCHAR NOT NULL,
+				op_count BIGINT NOT NULL,
+				PRIMARY KEY (owner_public_key, derived_public_key, access_group_owner_public_key, access_group_key_name, scope_type, is_member_operation, operation)
+			);
+			CREATE INDEX {tableName}_access_group_idx ON {tableName} (access_group_owner_public_key, access_group_key_name);
+
+			INSERT INTO {tableName} (owner_public_key, derived_public_key, access_group_owner_public_key, access_group_key_name, scope_type, is_member_operation, operation, op_count)
+			SELECT dk.owner_public_key, dk.derived_public_key,
+				COALESCE(limits.item ->> 'AccessGroupOwnerPublicKeyBase58Check', ''), COALESCE(limits.item ->> 'AccessGroupKeyName', ''),
+				COALESCE(limits.item ->> 'ScopeType', ''), limits.is_member_operation,
+				COALESCE(limits.item ->> 'OperationType', ''), COALESCE((limits.item ->> 'OpCount')::BIGINT, 0)
+			FROM derived_key_entry dk,
+				LATERAL (
+					SELECT item, false AS is_member_operation
+					FROM jsonb_array_elements(CASE WHEN jsonb_typeof(dk.transaction_spending_limits -> 'AccessGroupLimitMap') = 'array'
+						THEN dk.transaction_spending_limits -> 'AccessGroupLimitMap' ELSE '[]' END) item
+					UNION ALL
+					SELECT item, true AS is_member_operation
+					FROM jsonb_array_elements(CASE WHEN jsonb_typeof(dk.transaction_spending_limits -> 'AccessGroupMemberLimitMap') = 'array'
+						THEN dk.transaction_spending_limits -> 'AccessGroupMemberLimitMap' ELSE '[]' END) item
+				) limits
+			ON CONFLICT DO NOTHING;
+		`},
+	{"derived_key_lockup_limit", `
+			CREATE TABLE {tableName} (
+				owner_public_key VARCHAR NOT NULL,
+				derived_public_key VARCHAR NOT NULL,
+				profile_public_key VARCHAR NOT NULL,
+				scope_type VARCHAR NOT NULL,
+				operation VARCHAR NOT NULL,
+				op_count BIGINT NOT NULL,
+				PRIMARY KEY (owner_public_key, derived_public_key, profile_public_key, scope_type, operation)
+			);
+			CREATE INDEX {tableName}_profile_public_key_idx ON {tableName} (profile_public_key, operation);
+
+			INSERT INTO {tableName} (owner_public_key, derived_public_key, profile_public_key, scope_type, operation, op_count)
+			SELECT dk.owner_public_key, dk.derived_public_key,
+				COALESCE(limits ->> 'ProfilePublicKeyBase58Check', ''), COALESCE(limits ->> 'ScopeType', ''),
+				COALESCE(limits ->> 'Operation', ''), COALESCE((limits ->> 'OpCount')::BIGINT, 0)
+			FROM derived_key_entry dk,
+				jsonb_array_elements(CASE WHEN jsonb_typeof(dk.transaction_spending_limits -> 'LockupLimitMap') = 'array' THEN dk.transaction_spending_limits -> 'LockupLimitMap' ELSE '[]' END) limits
+			ON CONFLICT DO NOTHING;
+		`},
+	// Stake amounts are only backfilled when they're stored as decimal numbers, the rest are filled in the next time
+	// the derived key is updated.
+	{"derived_key_stake_limit", `
+			CREATE TABLE {tableName} (
+				owner_public_key VARCHAR NOT NULL,
+				derived_public_key VARCHAR NOT NULL,
+				validator_public_key VARCHAR NOT NULL,
+				operation VARCHAR NOT NULL,
+				amount_nanos NUMERIC,
+				op_count BIGINT NOT NULL,
+				PRIMARY KEY (owner_public_key, derived_public_key, validator_public_key, operation)
+			);
+			CREATE INDEX {tableName}_validator_public_key_idx ON {tableName} (validator_public_key, operation);
+
+			INSERT INTO {tableName} (owner_public_key, derived_public_key, validator_public_key, operation, amount_nanos, op_count)
+			SELECT dk.owner_public_key, dk.derived_public_key,
+				COALESCE(limits.item ->> 'ValidatorPublicKeyBase58Check', ''), limits.operation,
+				CASE WHEN (limits.item ->> 'StakeLimit') ~ '^[0-9]+$' THEN (limits.item ->> 'StakeLimit')::NUMERIC END,
+				COALESCE((limits.item ->> 'OpCount')::BIGINT, 0)
+			FROM derived_key_entry dk,
+				LATERAL (
+					SELECT item, limit_maps.operation
+					FROM (VALUES ('StakeLimitMap', 'stake'), ('UnstakeLimitMap', 'unstake'), ('UnlockStakeLimitMap', 'unlock_stake')) limit_maps (field, operation),
+						jsonb_array_elements(CASE WHEN jsonb_typeof(dk.transaction_spending_limits -> limit_maps.field) = 'array'
+							THEN dk.transaction_spending_limits -> limit_maps.field ELSE '[]' END) item
+				) limits
+			ON CONFLICT DO NOTHING;
+		`},
+}
+
+func createDerivedKeySpendingLimitTables(db *bun.DB) error {
+	for _, table := range derivedKeySpendingLimitTables {
+		if _, err := db.Exec(strings.Replace(table.query, "{tableName}", table.tableName, -1)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		return createDerivedKeySpendingLimitTables(db)
+	}, func(ctx context.Context, db *bun.DB) error {
+		for _, table := range derivedKeySpendingLimitTables {
+			if _, err := db.Exec("DROP TABLE IF EXISTS " + table.tableName); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
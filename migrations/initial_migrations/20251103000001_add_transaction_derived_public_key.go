@@ -0,0 +1,61 @@
+package initial_migrations
+
+import (
+	"context"
+	"strings"
+
+	"github.com/uptrace/bun"
+)
+
+func createAppUsageDailyTable(db *bun.DB, tableName string) error {
+	_, err := db.Exec(strings.Replace(`
+			CREATE TABLE {tableName} (
+				day DATE NOT NULL,
+				derived_public_key VARCHAR NOT NULL,
+				owner_public_key VARCHAR NOT NULL,
+				transaction_count BIGINT NOT NULL DEFAULT 0,
+				PRIMARY KEY (day, derived_public_key, owner_public_key)
+			);
+			CREATE INDEX {tableName}_derived_public_key_idx ON {tableName} (derived_public_key, day desc);
+			CREATE INDEX {tableName}_owner_public_key_idx ON {tableName} (owner_public_key, day desc);
+		`, "{tableName}", tableName, -1))
+	return err
+}
+
+// Transactions synced before this migration aren't attributed to a derived key, as recovering the signer requires
+// decoding the transaction.
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		_, err := db.Exec(`
+			ALTER TABLE transaction_partitioned ADD COLUMN derived_public_key VARCHAR;
+			CREATE INDEX transaction_derived_public_key_idx ON transaction_partitioned (derived_public_key, timestamp desc);
+		`)
+		if err != nil {
+			return err
+		}
+		_, err = db.Exec(`
+			CREATE OR REPLACE VIEW transaction AS
+			SELECT * FROM transaction_partitioned;
+		`)
+		if err != nil {
+			return err
+		}
+		return createAppUsageDailyTable(db, "app_usage_daily")
+	}, func(ctx context.Context, db *bun.DB) error {
+		// The transaction view selects every column, so it's dropped before the column and recreated after. The view is
+		// dropped without cascading, so that views depending on it, which are created by post sync migrations, fail
+		// the rollback instead of being dropped silently.
+		_, err := db.Exec(`
+			DROP TABLE IF EXISTS app_usage_daily;
+			DROP VIEW IF EXISTS transaction;
+			DROP INDEX IF EXISTS transaction_derived_public_key_idx;
+			ALTER TABLE transaction_partitioned DROP COLUMN IF EXISTS derived_public_key;
+			CREATE VIEW transaction AS
+			SELECT * FROM transaction_partitioned;
+		`)
+		if err != nil {
+			return err
+		}
+		return nil
+	})
+}
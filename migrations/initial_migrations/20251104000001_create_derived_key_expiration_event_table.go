@@ -0,0 +1,43 @@
+package initial_migrations
+
+import (
+	"context"
+	"strings"
+
+	"github.com/uptrace/bun"
+)
+
+func createDerivedKeyExpirationEventTable(db *bun.DB, tableName string) error {
+	_, err := db.Exec(strings.Replace(`
+			CREATE TABLE {tableName} (
+				owner_public_key VARCHAR NOT NULL,
+				derived_public_key VARCHAR NOT NULL,
+				expiration_block BIGINT NOT NULL,
+				block_height BIGINT NOT NULL,
+				block_hash VARCHAR NOT NULL,
+				timestamp TIMESTAMP NOT NULL,
+				PRIMARY KEY (owner_public_key, derived_public_key, expiration_block)
+			);
+			CREATE INDEX {tableName}_block_height_idx ON {tableName} (block_height desc);
+			CREATE INDEX {tableName}_block_hash_idx ON {tableName} (block_hash);
+			CREATE INDEX {tableName}_derived_public_key_idx ON {tableName} (derived_public_key);
+
+			CREATE INDEX derived_key_entry_expiration_block_idx ON derived_key_entry (expiration_block);
+		`, "{tableName}", tableName, -1))
+	return err
+}
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		return createDerivedKeyExpirationEventTable(db, "derived_key_expiration_event")
+	}, func(ctx context.Context, db *bun.DB) error {
+		_, err := db.Exec(`
+			DROP TABLE IF EXISTS derived_key_expiration_event;
+			DROP INDEX IF EXISTS derived_key_entry_expiration_block_idx;
+		`)
+		if err != nil {
+			return err
+		}
+		return nil
+	})
+}
@@ -0,0 +1,68 @@
+package initial_migrations
+
+import (
+	"context"
+	"strings"
+
+	"github.com/uptrace/bun"
+)
+
+func createPostAssociationCountTable(db *bun.DB, tableName string) error {
+	_, err := db.Exec(strings.Replace(`
+			CREATE TABLE {tableName} (
+				post_hash VARCHAR NOT NULL,
+				association_type VARCHAR NOT NULL,
+				association_value VARCHAR NOT NULL,
+				app_pkid VARCHAR NOT NULL,
+				count BIGINT NOT NULL,
+				PRIMARY KEY (post_hash, association_type, association_value, app_pkid)
+			);
+			CREATE INDEX {tableName}_type_value_idx ON {tableName} (association_type, association_value, count desc);
+
+			INSERT INTO {tableName} (post_hash, association_type, association_value, app_pkid, count)
+			SELECT post_hash, association_type, association_value, COALESCE(app_pkid, ''), COUNT(*)
+			FROM post_association_entry
+			WHERE post_hash IS NOT NULL
+			GROUP BY 1, 2, 3, 4;
+		`, "{tableName}", tableName, -1))
+	return err
+}
+
+func createUserAssociationCountTable(db *bun.DB, tableName string) error {
+	_, err := db.Exec(strings.Replace(`
+			CREATE TABLE {tableName} (
+				target_user_pkid VARCHAR NOT NULL,
+				association_type VARCHAR NOT NULL,
+				association_value VARCHAR NOT NULL,
+				app_pkid VARCHAR NOT NULL,
+				count BIGINT NOT NULL,
+				PRIMARY KEY (target_user_pkid, association_type, association_value, app_pkid)
+			);
+			CREATE INDEX {tableName}_type_value_idx ON {tableName} (association_type, association_value, count desc);
+
+			INSERT INTO {tableName} (target_user_pkid, association_type, association_value, app_pkid, count)
+			SELECT target_user_pkid, association_type, association_value, COALESCE(app_pkid, ''), COUNT(*)
+			FROM user_association_entry
+			WHERE target_user_pkid IS NOT NULL
+			GROUP BY 1, 2, 3, 4;
+		`, "{tableName}", tableName, -1))
+	return err
+}
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		if err := createPostAssociationCountTable(db, "post_association_count"); err != nil {
+			return err
+		}
+		return createUserAssociationCountTable(db, "user_association_count")
+	}, func(ctx context.Context, db *bun.DB) error {
+		_, err := db.Exec(`
+			DROP TABLE IF EXISTS post_association_count;
+			DROP TABLE IF EXISTS user_association_count;
+		`)
+		if err != nil {
+			return err
+		}
+		return nil
+	})
+}
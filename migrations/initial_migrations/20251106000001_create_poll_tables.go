@@ -0,0 +1,152 @@
+package initial_migrations
+
+import (
+	"context"
+	"strings"
+
+	"github.com/deso-protocol/postgres-data-handler/entries"
+	"github.com/uptrace/bun"
+)
+
+func createPollTables(db *bun.DB, tableName string) error {
+	_, err := db.Exec(strings.Replace(`
+			ALTER TABLE post_entry ADD COLUMN is_poll BOOLEAN NOT NULL DEFAULT false;
+
+			CREATE TABLE {tableName} (
+				post_hash VARCHAR PRIMARY KEY NOT NULL,
+				poster_public_key VARCHAR NOT NULL,
+				weight_type VARCHAR,
+				weight_token_public_key VARCHAR,
+				expiration_block_height BIGINT,
+				total_votes BIGINT NOT NULL DEFAULT 0,
+				timestamp TIMESTAMP NOT NULL
+			);
+			CREATE INDEX {tableName}_poster_public_key_timestamp_idx ON {tableName} (poster_public_key, timestamp desc);
+			CREATE INDEX {tableName}_timestamp_idx ON {tableName} (timestamp desc);
+
+			CREATE TABLE {tableName}_option (
+				post_hash VARCHAR NOT NULL,
+				position BIGINT NOT NULL,
+				option_text TEXT NOT NULL,
+				vote_count BIGINT NOT NULL DEFAULT 0,
+				PRIMARY KEY (post_hash, position)
+			);
+		`, "{tableName}", tableName, -1))
+	return err
+}
+
+// backfillPolls extracts the polls of the posts already stored, parsing their extra data exactly as live posts are
+// parsed. Posts are read in batches ordered by post hash, so that the whole table isn't held in memory.
+func backfillPolls(ctx context.Context, db *bun.DB) error {
+	const batchSize = 10000
+	lastPostHash := ""
+	for {
+		var pgPostEntrySlice []*entries.PGPostEntry
+		if err := db.NewSelect().
+			Model(&pgPostEntrySlice).
+			Column("post_hash", "poster_public_key", "is_hidden", "extra_data", "timestamp").
+			Where("extra_data->>'PollOptions' IS NOT NULL").
+			Where("post_hash > ?", lastPostHash).
+			Order("post_hash").
+			Limit(batchSize).
+			Scan(ctx); err != nil {
+			return err
+		}
+		if len(pgPostEntrySlice) == 0 {
+			return nil
+		}
+		lastPostHash = pgPostEntrySlice[len(pgPostEntrySlice)-1].PostHash
+
+		var pollPostHashes []string
+		var pgPollSlice []*entries.PGPoll
+		var pgPollOptionSlice []*entries.PGPollOption
+		for _, pgPostEntry := range pgPostEntrySlice {
+			poll, pollOptions := entries.PostEntryToPoll(&pgPostEntry.PostEntry)
+			if poll == nil {
+				continue
+			}
+			pollPostHashes = append(pollPostHashes, poll.PostHash)
+			// Hidden posts have no poll.
+			if pgPostEntry.IsHidden {
+				continue
+			}
+			pgPollSlice = append(pgPollSlice, &entries.PGPoll{Poll: *poll})
+			for _, pollOption := range pollOptions {
+				pgPollOptionSlice = append(pgPollOptionSlice, &entries.PGPollOption{PollOption: *pollOption})
+			}
+		}
+
+		if len(pollPostHashes) > 0 {
+			if _, err := db.NewUpdate().
+				Table("post_entry").
+				Set("is_poll = true").
+				Where("post_hash IN (?)", bun.In(pollPostHashes)).
+				Exec(ctx); err != nil {
+				return err
+			}
+		}
+		if len(pgPollSlice) > 0 {
+			if _, err := db.NewInsert().Model(&pgPollSlice).Returning("").Exec(ctx); err != nil {
+				return err
+			}
+			if _, err := db.NewInsert().Model(&pgPollOptionSlice).Returning("").Exec(ctx); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func countPollVotes(db *bun.DB, tableName string) error {
+	_, err := db.Exec(strings.Replace(`
+			-- Each voter's earliest response counts once, provided it names an option and was cast before the poll expired.
+			WITH poll_vote AS (
+				SELECT DISTINCT ON (pae.post_hash, pae.transactor_pkid) pae.post_hash, pae.association_value
+				FROM post_association_entry pae
+				JOIN {tableName} ON {tableName}.post_hash = pae.post_hash
+				WHERE upper(pae.association_type) = 'POLL_RESPONSE'
+				AND ({tableName}.expiration_block_height IS NULL OR pae.block_height <= {tableName}.expiration_block_height)
+				AND EXISTS (
+					SELECT 1 FROM {tableName}_option
+					WHERE {tableName}_option.post_hash = pae.post_hash AND {tableName}_option.option_text = pae.association_value
+				)
+				ORDER BY pae.post_hash, pae.transactor_pkid, pae.block_height, pae.association_id
+			), option_vote_count AS (
+				SELECT post_hash, association_value, COUNT(*) AS vote_count FROM poll_vote GROUP BY post_hash, association_value
+			), poll_vote_count AS (
+				SELECT post_hash, COUNT(*) AS total_votes FROM poll_vote GROUP BY post_hash
+			), updated_options AS (
+				UPDATE {tableName}_option
+				SET vote_count = option_vote_count.vote_count
+				FROM option_vote_count
+				WHERE {tableName}_option.post_hash = option_vote_count.post_hash
+				AND {tableName}_option.option_text = option_vote_count.association_value
+			)
+			UPDATE {tableName}
+			SET total_votes = poll_vote_count.total_votes
+			FROM poll_vote_count
+			WHERE {tableName}.post_hash = poll_vote_count.post_hash;
+		`, "{tableName}", tableName, -1))
+	return err
+}
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		if err := createPollTables(db, "poll"); err != nil {
+			return err
+		}
+		if err := backfillPolls(ctx, db); err != nil {
+			return err
+		}
+		return countPollVotes(db, "poll")
+	}, func(ctx context.Context, db *bun.DB) error {
+		_, err := db.Exec(`
+			DROP TABLE IF EXISTS poll_option;
+			DROP TABLE IF EXISTS poll;
+			ALTER TABLE post_entry DROP COLUMN IF EXISTS is_poll;
+		`)
+		if err != nil {
+			return err
+		}
+		return nil
+	})
+}
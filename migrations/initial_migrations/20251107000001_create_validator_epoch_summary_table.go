@@ -0,0 +1,53 @@
+package initial_migrations
+
+import (
+	"context"
+	"strings"
+
+	"github.com/deso-protocol/postgres-data-handler/migrations/migration_utils"
+	"github.com/uptrace/bun"
+)
+
+func createValidatorEpochSummaryTable(db *bun.DB, tableName string) error {
+	_, err := db.Exec(strings.Replace(`
+			CREATE TABLE {tableName} (
+				epoch_number BIGINT NOT NULL,
+				validator_pkid VARCHAR NOT NULL,
+				total_stake_amount_nanos NUMERIC(78, 0) NOT NULL,
+				total_reward_nanos BIGINT NOT NULL,
+				commission_nanos BIGINT NOT NULL,
+				delegator_reward_nanos BIGINT NOT NULL,
+				staker_count BIGINT NOT NULL,
+				realized_apy DOUBLE PRECISION,
+				PRIMARY KEY (epoch_number, validator_pkid)
+			);
+			CREATE INDEX {tableName}_validator_pkid_epoch_number_idx ON {tableName} (validator_pkid, epoch_number desc);
+		`, "{tableName}", tableName, -1))
+	return err
+}
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		if err := createValidatorEpochSummaryTable(db, "validator_epoch_summary"); err != nil {
+			return err
+		}
+
+		// Summarize every epoch that has completed, using the rewards distributed in its blocks.
+		var epochNumbers []uint64
+		if err := db.NewSelect().
+			Table("epoch_entry").
+			Column("epoch_number").
+			Scan(ctx, &epochNumbers); err != nil {
+			return err
+		}
+		return migration_utils.UpsertValidatorEpochSummaries(ctx, db, epochNumbers)
+	}, func(ctx context.Context, db *bun.DB) error {
+		_, err := db.Exec(`
+			DROP TABLE IF EXISTS validator_epoch_summary;
+		`)
+		if err != nil {
+			return err
+		}
+		return nil
+	})
+}
@@ -0,0 +1,42 @@
+package initial_migrations
+
+import (
+	"context"
+	"strings"
+
+	"github.com/uptrace/bun"
+)
+
+func createValidatorPerformanceTable(db *bun.DB, tableName string) error {
+	_, err := db.Exec(strings.Replace(`
+			CREATE TABLE {tableName} (
+				epoch_number BIGINT NOT NULL,
+				validator_pkid VARCHAR NOT NULL,
+				scheduled_leader_slots BIGINT NOT NULL,
+				blocks_proposed BIGINT NOT NULL,
+				timeouts BIGINT NOT NULL,
+				qc_signatures BIGINT NOT NULL,
+				qc_signatures_expected BIGINT NOT NULL,
+				participation_rate DOUBLE PRECISION,
+				PRIMARY KEY (epoch_number, validator_pkid)
+			);
+			CREATE INDEX {tableName}_validator_pkid_epoch_number_idx ON {tableName} (validator_pkid, epoch_number desc);
+		`, "{tableName}", tableName, -1))
+	return err
+}
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		// Completed epochs are measured by 20251109000001_add_block_validator_pkids, once the block proposers and
+		// signers they're measured from have been resolved to PKIDs.
+		return createValidatorPerformanceTable(db, "validator_performance")
+	}, func(ctx context.Context, db *bun.DB) error {
+		_, err := db.Exec(`
+			DROP TABLE IF EXISTS validator_performance;
+		`)
+		if err != nil {
+			return err
+		}
+		return nil
+	})
+}
@@ -0,0 +1,79 @@
+package initial_migrations
+
+import (
+	"context"
+
+	"github.com/deso-protocol/postgres-data-handler/migrations/migration_utils"
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		_, err := db.Exec(`
+			ALTER TABLE block ADD COLUMN proposer_pkid VARCHAR;
+			ALTER TABLE block_signer ADD COLUMN signer_pkid VARCHAR;
+			ALTER TABLE block_signer ADD COLUMN stake_amount_nanos NUMERIC(78, 0);
+
+			-- Blocks are proposed by a validator of their own epoch.
+			UPDATE block
+			SET proposer_pkid = bls.pkid
+			FROM epoch_entry, bls_public_key_pkid_pair_snapshot_entry bls
+			WHERE block.height BETWEEN epoch_entry.initial_block_height AND epoch_entry.final_block_height
+			AND bls.snapshot_at_epoch_number = epoch_entry.snapshot_at_epoch_number
+			AND bls.bls_public_key = block.proposer_voting_public_key;
+
+			-- Signer indexes refer to the validator set of the signed parent's epoch, ordered by stake. Ties are broken by
+			-- descending PKID bytes, which for base58 encoded PKIDs is by length and then bytewise.
+			WITH validator_set AS (
+				SELECT
+					snapshot_at_epoch_number,
+					validator_pkid,
+					total_stake_amount_nanos,
+					row_number() OVER (
+						PARTITION BY snapshot_at_epoch_number ORDER BY total_stake_amount_nanos DESC,
+							length(validator_pkid) DESC, validator_pkid COLLATE "C" DESC
+					) - 1 AS signer_index
+				FROM snapshot_validator_entry
+			), signer AS (
+				SELECT block_signer.block_hash, block_signer.signer_index, validator_set.validator_pkid, validator_set.total_stake_amount_nanos
+				FROM block_signer
+				JOIN block ON block.block_hash = block_signer.block_hash
+				JOIN epoch_entry ON block.height - 1 BETWEEN epoch_entry.initial_block_height AND epoch_entry.final_block_height
+				JOIN validator_set ON validator_set.snapshot_at_epoch_number = epoch_entry.snapshot_at_epoch_number
+					AND validator_set.signer_index = block_signer.signer_index
+			)
+			UPDATE block_signer
+			SET signer_pkid = signer.validator_pkid, stake_amount_nanos = signer.total_stake_amount_nanos
+			FROM signer
+			WHERE block_signer.block_hash = signer.block_hash AND block_signer.signer_index = signer.signer_index;
+
+			CREATE INDEX block_proposer_pkid_idx ON block (proposer_pkid);
+			CREATE INDEX block_signer_signer_pkid_idx ON block_signer (signer_pkid);
+		`)
+		if err != nil {
+			return err
+		}
+
+		// Measure every completed epoch from the resolved proposers and signers.
+		var epochNumbers []uint64
+		if err := db.NewSelect().
+			Table("epoch_entry").
+			Column("epoch_number").
+			Scan(ctx, &epochNumbers); err != nil {
+			return err
+		}
+		return migration_utils.UpsertValidatorPerformance(ctx, db, epochNumbers)
+	}, func(ctx context.Context, db *bun.DB) error {
+		_, err := db.Exec(`
+			DROP INDEX IF EXISTS block_proposer_pkid_idx;
+			DROP INDEX IF EXISTS block_signer_signer_pkid_idx;
+			ALTER TABLE block DROP COLUMN IF EXISTS proposer_pkid;
+			ALTER TABLE block_signer DROP COLUMN IF EXISTS signer_pkid;
+			ALTER TABLE block_signer DROP COLUMN IF EXISTS stake_amount_nanos;
+		`)
+		if err != nil {
+			return err
+		}
+		return nil
+	})
+}
@@ -0,0 +1,43 @@
+package initial_migrations
+
+import (
+	"context"
+	"strings"
+
+	"github.com/uptrace/bun"
+)
+
+func createValidatorKeyRotationTable(db *bun.DB, tableName string) error {
+	_, err := db.Exec(strings.Replace(`
+			CREATE TABLE {tableName} (
+				transaction_hash VARCHAR PRIMARY KEY NOT NULL,
+				validator_pkid VARCHAR NOT NULL,
+				old_voting_public_key VARCHAR,
+				new_voting_public_key VARCHAR NOT NULL,
+				epoch_number BIGINT,
+				block_hash VARCHAR NOT NULL,
+				block_height BIGINT NOT NULL,
+				timestamp TIMESTAMP NOT NULL
+			);
+			CREATE INDEX {tableName}_validator_pkid_block_height_idx ON {tableName} (validator_pkid, block_height desc);
+			CREATE INDEX {tableName}_epoch_number_idx ON {tableName} (epoch_number);
+			CREATE INDEX {tableName}_block_hash_idx ON {tableName} (block_hash);
+			CREATE INDEX {tableName}_old_voting_public_key_idx ON {tableName} (old_voting_public_key);
+			CREATE INDEX {tableName}_new_voting_public_key_idx ON {tableName} (new_voting_public_key);
+		`, "{tableName}", tableName, -1))
+	return err
+}
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		return createValidatorKeyRotationTable(db, "validator_key_rotation")
+	}, func(ctx context.Context, db *bun.DB) error {
+		_, err := db.Exec(`
+			DROP TABLE IF EXISTS validator_key_rotation;
+		`)
+		if err != nil {
+			return err
+		}
+		return nil
+	})
+}
@@ -0,0 +1,50 @@
+package initial_migrations
+
+import (
+	"context"
+	"strings"
+
+	"github.com/uptrace/bun"
+)
+
+// Stake position history is only recorded going forward, since the previous amounts of stake and locked stake
+// entries aren't stored.
+func createStakePositionHistoryTable(db *bun.DB, tableName string) error {
+	_, err := db.Exec(strings.Replace(`
+			CREATE TABLE {tableName} (
+				staker_pkid VARCHAR NOT NULL,
+				validator_pkid VARCHAR NOT NULL,
+				position_type VARCHAR NOT NULL,
+				locked_at_epoch_number BIGINT NOT NULL DEFAULT 0,
+				block_height BIGINT NOT NULL,
+				action VARCHAR NOT NULL,
+				reward_method VARCHAR,
+				amount_before_nanos NUMERIC(78, 0),
+				amount_after_nanos NUMERIC(78, 0),
+				delta_nanos NUMERIC(78, 0),
+				block_hash VARCHAR,
+				txn_hash VARCHAR,
+				PRIMARY KEY (staker_pkid, validator_pkid, position_type, locked_at_epoch_number, block_height)
+			);
+			CREATE INDEX {tableName}_staker_pkid_block_height_idx ON {tableName} (staker_pkid, block_height desc);
+			CREATE INDEX {tableName}_validator_pkid_block_height_idx ON {tableName} (validator_pkid, block_height desc);
+			CREATE INDEX {tableName}_block_height_idx ON {tableName} (block_height);
+			CREATE INDEX {tableName}_block_hash_idx ON {tableName} (block_hash);
+			CREATE INDEX {tableName}_txn_hash_idx ON {tableName} (txn_hash);
+		`, "{tableName}", tableName, -1))
+	return err
+}
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		return createStakePositionHistoryTable(db, "stake_position_history")
+	}, func(ctx context.Context, db *bun.DB) error {
+		_, err := db.Exec(`
+			DROP TABLE IF EXISTS stake_position_history;
+		`)
+		if err != nil {
+			return err
+		}
+		return nil
+	})
+}
@@ -0,0 +1,53 @@
+package initial_migrations
+
+import (
+	"context"
+	"strings"
+
+	"github.com/uptrace/bun"
+)
+
+// Unstakes are recorded from their transactions as they're synced. Unstakes made before this table existed are
+// backfilled from the transaction table after the sync.
+func createUnstakeLifecycleTable(db *bun.DB, tableName string) error {
+	_, err := db.Exec(strings.Replace(`
+			CREATE TABLE {tableName} (
+				unstake_txn_hash VARCHAR PRIMARY KEY NOT NULL,
+				staker_pkid VARCHAR NOT NULL,
+				validator_pkid VARCHAR NOT NULL,
+				locked_at_epoch_number BIGINT,
+				unstake_amount_nanos NUMERIC(78, 0) NOT NULL,
+				unlockable_at_epoch_number BIGINT,
+				status VARCHAR NOT NULL,
+				unstake_block_hash VARCHAR NOT NULL,
+				unstake_block_height BIGINT NOT NULL,
+				unstake_timestamp TIMESTAMP NOT NULL,
+				unlock_txn_hash VARCHAR,
+				unlock_block_hash VARCHAR,
+				unlock_block_height BIGINT,
+				unlock_timestamp TIMESTAMP
+			);
+			CREATE INDEX {tableName}_staker_pkid_unstake_block_height_idx ON {tableName} (staker_pkid, unstake_block_height desc);
+			CREATE INDEX {tableName}_locked_stake_idx ON {tableName} (staker_pkid, validator_pkid, locked_at_epoch_number);
+			CREATE INDEX {tableName}_validator_pkid_idx ON {tableName} (validator_pkid);
+			CREATE INDEX {tableName}_status_unlockable_at_epoch_number_idx ON {tableName} (status, unlockable_at_epoch_number);
+			CREATE INDEX {tableName}_unstake_block_hash_idx ON {tableName} (unstake_block_hash);
+			CREATE INDEX {tableName}_unlock_block_hash_idx ON {tableName} (unlock_block_hash);
+			CREATE INDEX {tableName}_unlock_txn_hash_idx ON {tableName} (unlock_txn_hash);
+		`, "{tableName}", tableName, -1))
+	return err
+}
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		return createUnstakeLifecycleTable(db, "unstake_lifecycle")
+	}, func(ctx context.Context, db *bun.DB) error {
+		_, err := db.Exec(`
+			DROP TABLE IF EXISTS unstake_lifecycle;
+		`)
+		if err != nil {
+			return err
+		}
+		return nil
+	})
+}
@@ -0,0 +1,86 @@
+package migration_utils
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+)
+
+// BackfillPostThreadStructure computes the root post hash and depth of every stored post, and the post_stats counts
+// of every stored post, from the posts, likes and diamonds already stored. It's run when the thread columns are
+// added to an existing database, and after the initial sync, which skips maintaining them. Posts whose stats were
+// stored by the initial sync keep the counts copied from consensus, apart from the reply counts, which are always
+// counted from the stored posts.
+func BackfillPostThreadStructure(ctx context.Context, db bun.IDB) error {
+	if _, err := db.ExecContext(ctx, `
+		WITH RECURSIVE threads AS (
+			SELECT post_hash, post_hash AS root_post_hash, 0::BIGINT AS depth
+			FROM post_entry WHERE parent_post_hash IS NULL
+			UNION ALL
+			SELECT child.post_hash, threads.root_post_hash, threads.depth + 1
+			FROM threads JOIN post_entry AS child ON child.parent_post_hash = threads.post_hash
+		)
+		UPDATE post_entry
+		SET root_post_hash = threads.root_post_hash, depth = threads.depth
+		FROM threads
+		WHERE post_entry.post_hash = threads.post_hash
+			AND (post_entry.root_post_hash IS DISTINCT FROM threads.root_post_hash OR post_entry.depth <> threads.depth);
+	`); err != nil {
+		return err
+	}
+
+	if _, err := db.ExecContext(ctx, `
+		INSERT INTO post_stats (post_hash, direct_reply_count, repost_count, quote_repost_count, like_count, diamond_count)
+		SELECT
+			post_entry.post_hash,
+			COALESCE(replies.direct_reply_count, 0),
+			COALESCE(reposts.repost_count, 0),
+			COALESCE(reposts.quote_repost_count, 0),
+			COALESCE(likes.like_count, 0),
+			COALESCE(diamonds.diamond_count, 0)
+		FROM post_entry
+		LEFT JOIN (
+			SELECT parent_post_hash AS post_hash, COUNT(*) AS direct_reply_count
+			FROM post_entry WHERE parent_post_hash IS NOT NULL AND NOT COALESCE(is_hidden, false)
+			GROUP BY parent_post_hash
+		) AS replies USING (post_hash)
+		LEFT JOIN (
+			SELECT reposted_post_hash AS post_hash,
+				COUNT(*) FILTER (WHERE NOT COALESCE(is_quoted_repost, false)) AS repost_count,
+				COUNT(*) FILTER (WHERE COALESCE(is_quoted_repost, false)) AS quote_repost_count
+			FROM post_entry WHERE reposted_post_hash IS NOT NULL AND NOT COALESCE(is_hidden, false)
+			GROUP BY reposted_post_hash
+		) AS reposts USING (post_hash)
+		LEFT JOIN (
+			SELECT post_hash, COUNT(*) AS like_count FROM like_entry GROUP BY post_hash
+		) AS likes USING (post_hash)
+		LEFT JOIN (
+			SELECT post_hash, SUM(diamond_level) AS diamond_count FROM diamond_entry GROUP BY post_hash
+		) AS diamonds USING (post_hash)
+		ON CONFLICT (post_hash) DO UPDATE SET direct_reply_count = EXCLUDED.direct_reply_count;
+	`); err != nil {
+		return err
+	}
+
+	// Each visible reply counts towards the total reply count of every one of its ancestors.
+	_, err := db.ExecContext(ctx, `
+		WITH RECURSIVE ancestors AS (
+			SELECT parent_post_hash AS post_hash
+			FROM post_entry WHERE parent_post_hash IS NOT NULL AND NOT COALESCE(is_hidden, false)
+			UNION ALL
+			SELECT post_entry.parent_post_hash
+			FROM ancestors JOIN post_entry ON post_entry.post_hash = ancestors.post_hash
+			WHERE post_entry.parent_post_hash IS NOT NULL
+		)
+		UPDATE post_stats
+		SET total_reply_count = reply_counts.total_reply_count
+		FROM (
+			SELECT post_stats.post_hash, COUNT(ancestors.post_hash) AS total_reply_count
+			FROM post_stats LEFT JOIN ancestors USING (post_hash)
+			GROUP BY post_stats.post_hash
+		) AS reply_counts
+		WHERE post_stats.post_hash = reply_counts.post_hash
+			AND post_stats.total_reply_count <> reply_counts.total_reply_count;
+	`)
+	return err
+}
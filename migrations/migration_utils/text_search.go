@@ -0,0 +1,25 @@
+package migration_utils
+
+import (
+	"fmt"
+	"regexp"
+)
+
+var textSearchLanguage = "english"
+
+// The text search language is interpolated into migrations as a text search configuration name, so it's restricted
+// to plain identifiers.
+var textSearchLanguageRegex = regexp.MustCompile(`^[a-z_]+$`)
+
+// SetTextSearchLanguage sets the language used by the full-text search columns, indexes and functions.
+func SetTextSearchLanguage(language string) {
+	textSearchLanguage = language
+}
+
+// GetTextSearchLanguage returns the configured text search language, or an error if it isn't a plain identifier.
+func GetTextSearchLanguage() (string, error) {
+	if !textSearchLanguageRegex.MatchString(textSearchLanguage) {
+		return "", fmt.Errorf("invalid text search language %q", textSearchLanguage)
+	}
+	return textSearchLanguage, nil
+}
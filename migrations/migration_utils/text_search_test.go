@@ -0,0 +1,27 @@
+package migration_utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetTextSearchLanguage(t *testing.T) {
+	defer SetTextSearchLanguage(textSearchLanguage)
+
+	language, err := GetTextSearchLanguage()
+	require.NoError(t, err)
+	require.Equal(t, "english", language)
+
+	SetTextSearchLanguage("simple")
+	language, err = GetTextSearchLanguage()
+	require.NoError(t, err)
+	require.Equal(t, "simple", language)
+
+	// Anything that isn't a plain identifier could break out of the migrations it's interpolated into.
+	for _, invalidLanguage := range []string{"", "English", "english'; DROP TABLE post_entry; --", "pg_catalog.english"} {
+		SetTextSearchLanguage(invalidLanguage)
+		_, err = GetTextSearchLanguage()
+		require.Error(t, err)
+	}
+}
@@ -0,0 +1,84 @@
+package migration_utils
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+)
+
+// nanoSecsPerYear is used to annualize the yield of a single epoch.
+const nanoSecsPerYear = 365.25 * 24 * 60 * 60 * 1e9
+
+// maxApyLogGrowth is the largest natural log of the annualized growth whose exponent still fits in a float8. Short
+// epochs or large rewards relative to stake can compound past it, in which case the APY is left NULL.
+const maxApyLogGrowth = 709
+
+// UpsertValidatorEpochSummaries computes the validator summaries of the given epochs. An epoch is only summarized
+// once the next epoch has started, since its rewards are distributed in its final block and its duration is known
+// from the start of the next epoch.
+func UpsertValidatorEpochSummaries(ctx context.Context, db bun.IDB, epochNumbers []uint64) error {
+	if len(epochNumbers) == 0 {
+		return nil
+	}
+	_, err := db.NewRaw(`
+		WITH summary_epoch AS (
+			SELECT
+				epoch.epoch_number,
+				epoch.initial_block_height,
+				epoch.final_block_height,
+				epoch.snapshot_at_epoch_number,
+				next_epoch.created_at_block_timestamp_nano_secs - epoch.created_at_block_timestamp_nano_secs AS duration_nano_secs
+			FROM epoch_entry epoch
+			JOIN epoch_entry next_epoch ON next_epoch.epoch_number = epoch.epoch_number + 1
+			WHERE epoch.epoch_number IN (?0)
+		), epoch_reward AS (
+			SELECT
+				summary_epoch.epoch_number,
+				stake_reward.validator_pkid,
+				SUM(stake_reward.reward_nanos) AS total_reward_nanos,
+				SUM(CASE WHEN stake_reward.is_validator_commission THEN stake_reward.reward_nanos ELSE 0 END) AS commission_nanos,
+				SUM(CASE WHEN NOT stake_reward.is_validator_commission AND stake_reward.staker_pkid <> stake_reward.validator_pkid
+					THEN stake_reward.reward_nanos ELSE 0 END) AS delegator_reward_nanos,
+				COUNT(DISTINCT stake_reward.staker_pkid) FILTER (WHERE NOT stake_reward.is_validator_commission) AS staker_count
+			FROM summary_epoch
+			JOIN block ON block.height BETWEEN summary_epoch.initial_block_height AND summary_epoch.final_block_height
+			JOIN stake_reward ON stake_reward.block_hash = block.block_hash
+			GROUP BY summary_epoch.epoch_number, stake_reward.validator_pkid
+		)
+		INSERT INTO validator_epoch_summary (
+			epoch_number, validator_pkid, total_stake_amount_nanos, total_reward_nanos, commission_nanos,
+			delegator_reward_nanos, staker_count, realized_apy
+		)
+		SELECT
+			summary_epoch.epoch_number,
+			snapshot_validator_entry.validator_pkid,
+			snapshot_validator_entry.total_stake_amount_nanos,
+			COALESCE(epoch_reward.total_reward_nanos, 0),
+			COALESCE(epoch_reward.commission_nanos, 0),
+			COALESCE(epoch_reward.delegator_reward_nanos, 0),
+			COALESCE(epoch_reward.staker_count, 0),
+			CASE WHEN apy.log_growth <= ?2 THEN exp(apy.log_growth) - 1 END
+		FROM summary_epoch
+		JOIN snapshot_validator_entry ON snapshot_validator_entry.snapshot_at_epoch_number = summary_epoch.snapshot_at_epoch_number
+		LEFT JOIN epoch_reward ON epoch_reward.epoch_number = summary_epoch.epoch_number
+			AND epoch_reward.validator_pkid = snapshot_validator_entry.validator_pkid
+		-- The epoch's yield compounded over a year, computed in log space so that it can be checked for overflow.
+		CROSS JOIN LATERAL (
+			SELECT CASE WHEN snapshot_validator_entry.total_stake_amount_nanos > 0 AND summary_epoch.duration_nano_secs > 0 THEN
+				ln(
+					1 + (COALESCE(epoch_reward.total_reward_nanos, 0) - COALESCE(epoch_reward.commission_nanos, 0))::float /
+						snapshot_validator_entry.total_stake_amount_nanos::float
+				) * (?1::float / summary_epoch.duration_nano_secs)
+			END AS log_growth
+		) AS apy
+		ON CONFLICT (epoch_number, validator_pkid) DO UPDATE SET
+			total_stake_amount_nanos = EXCLUDED.total_stake_amount_nanos,
+			total_reward_nanos = EXCLUDED.total_reward_nanos,
+			commission_nanos = EXCLUDED.commission_nanos,
+			delegator_reward_nanos = EXCLUDED.delegator_reward_nanos,
+			staker_count = EXCLUDED.staker_count,
+			realized_apy = EXCLUDED.realized_apy`,
+		bun.In(epochNumbers), nanoSecsPerYear, maxApyLogGrowth,
+	).Exec(ctx)
+	return err
+}
@@ -0,0 +1,122 @@
+package migration_utils
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+)
+
+// UpsertValidatorPerformance computes the validator performance of the given epochs. An epoch is only measured once
+// the next epoch has started, since its last view is known from the start of the next epoch.
+//
+// The leader of each view is taken from the epoch's leader schedule, starting at the epoch's leader index offset.
+// Proposers and signers are the validators resolved when their blocks were stored, so historical epochs are only
+// measured correctly once the block proposer and signer PKIDs have been resolved.
+func UpsertValidatorPerformance(ctx context.Context, db bun.IDB, epochNumbers []uint64) error {
+	if len(epochNumbers) == 0 {
+		return nil
+	}
+	_, err := db.NewRaw(`
+		WITH performance_epoch AS (
+			SELECT
+				epoch.epoch_number,
+				epoch.initial_block_height,
+				epoch.final_block_height,
+				epoch.initial_view,
+				epoch.initial_leader_index_offset,
+				epoch.snapshot_at_epoch_number,
+				next_epoch.initial_view AS next_initial_view,
+				(SELECT COUNT(*) FROM leader_schedule_entry
+					WHERE leader_schedule_entry.snapshot_at_epoch_number = epoch.snapshot_at_epoch_number) AS num_leaders
+			FROM epoch_entry epoch
+			JOIN epoch_entry next_epoch ON next_epoch.epoch_number = epoch.epoch_number + 1
+			WHERE epoch.epoch_number IN (?)
+		), epoch_view AS (
+			SELECT
+				performance_epoch.epoch_number,
+				performance_epoch.initial_block_height,
+				performance_epoch.final_block_height,
+				performance_epoch.snapshot_at_epoch_number,
+				epoch_view.view,
+				(epoch_view.view - performance_epoch.initial_view + performance_epoch.initial_leader_index_offset) %
+					performance_epoch.num_leaders AS leader_index
+			FROM performance_epoch
+			CROSS JOIN LATERAL generate_series(performance_epoch.initial_view, performance_epoch.next_initial_view - 1) AS epoch_view (view)
+			WHERE performance_epoch.num_leaders > 0
+		), scheduled AS (
+			SELECT
+				epoch_view.epoch_number,
+				leader_schedule_entry.validator_pkid,
+				COUNT(*) AS scheduled_leader_slots,
+				COUNT(*) FILTER (WHERE block.block_hash IS NULL) AS timeouts
+			FROM epoch_view
+			JOIN leader_schedule_entry ON leader_schedule_entry.snapshot_at_epoch_number = epoch_view.snapshot_at_epoch_number
+				AND leader_schedule_entry.leader_index = epoch_view.leader_index
+			LEFT JOIN block ON block.proposed_in_view = epoch_view.view
+				AND block.height BETWEEN epoch_view.initial_block_height AND epoch_view.final_block_height
+			GROUP BY epoch_view.epoch_number, leader_schedule_entry.validator_pkid
+		), proposed AS (
+			SELECT performance_epoch.epoch_number, block.proposer_pkid AS validator_pkid, COUNT(*) AS blocks_proposed
+			FROM performance_epoch
+			JOIN block ON block.height BETWEEN performance_epoch.initial_block_height AND performance_epoch.final_block_height
+			WHERE block.proposer_pkid IS NOT NULL
+			GROUP BY performance_epoch.epoch_number, block.proposer_pkid
+		), validator_set AS (
+			SELECT performance_epoch.epoch_number, snapshot_validator_entry.validator_pkid
+			FROM performance_epoch
+			JOIN snapshot_validator_entry ON snapshot_validator_entry.snapshot_at_epoch_number = performance_epoch.snapshot_at_epoch_number
+		), certificate AS (
+			SELECT performance_epoch.epoch_number, child.block_hash
+			FROM performance_epoch
+			JOIN block parent ON parent.height BETWEEN performance_epoch.initial_block_height AND performance_epoch.final_block_height
+			JOIN block child ON child.height = parent.height + 1 AND child.prev_block_hash = parent.block_hash
+			WHERE child.block_version >= 2
+		), certificate_count AS (
+			SELECT epoch_number, COUNT(*) AS num_certificates FROM certificate GROUP BY epoch_number
+		), signed AS (
+			SELECT certificate.epoch_number, validator_set.validator_pkid, COUNT(*) AS qc_signatures
+			FROM certificate
+			JOIN block_signer ON block_signer.block_hash = certificate.block_hash
+			JOIN validator_set ON validator_set.epoch_number = certificate.epoch_number
+				AND validator_set.validator_pkid = block_signer.signer_pkid
+			GROUP BY certificate.epoch_number, validator_set.validator_pkid
+		), performance_validator AS (
+			SELECT epoch_number, validator_pkid FROM validator_set
+			UNION SELECT epoch_number, validator_pkid FROM scheduled
+			UNION SELECT epoch_number, validator_pkid FROM proposed
+		), performance AS (
+			SELECT
+				performance_validator.epoch_number,
+				performance_validator.validator_pkid,
+				COALESCE(scheduled.scheduled_leader_slots, 0) AS scheduled_leader_slots,
+				COALESCE(proposed.blocks_proposed, 0) AS blocks_proposed,
+				COALESCE(scheduled.timeouts, 0) AS timeouts,
+				COALESCE(signed.qc_signatures, 0) AS qc_signatures,
+				CASE WHEN validator_set.validator_pkid IS NULL THEN 0 ELSE COALESCE(certificate_count.num_certificates, 0) END AS qc_signatures_expected
+			FROM performance_validator
+			LEFT JOIN scheduled USING (epoch_number, validator_pkid)
+			LEFT JOIN proposed USING (epoch_number, validator_pkid)
+			LEFT JOIN signed USING (epoch_number, validator_pkid)
+			LEFT JOIN validator_set USING (epoch_number, validator_pkid)
+			LEFT JOIN certificate_count USING (epoch_number)
+		)
+		INSERT INTO validator_performance (
+			epoch_number, validator_pkid, scheduled_leader_slots, blocks_proposed, timeouts, qc_signatures,
+			qc_signatures_expected, participation_rate
+		)
+		SELECT
+			epoch_number, validator_pkid, scheduled_leader_slots, blocks_proposed, timeouts, qc_signatures,
+			qc_signatures_expected,
+			CASE WHEN qc_signatures_expected > 0 THEN qc_signatures::float / qc_signatures_expected END
+		FROM performance
+		ON CONFLICT (epoch_number, validator_pkid) DO UPDATE SET
+			scheduled_leader_slots = EXCLUDED.scheduled_leader_slots,
+			blocks_proposed = EXCLUDED.blocks_proposed,
+			timeouts = EXCLUDED.timeouts,
+			qc_signatures = EXCLUDED.qc_signatures,
+			qc_signatures_expected = EXCLUDED.qc_signatures_expected,
+			participation_rate = EXCLUDED.participation_rate`,
+		bun.In(epochNumbers),
+	).Exec(ctx)
+	return err
+}
@@ -0,0 +1,29 @@
+package post_sync_migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		_, err := db.Exec(`
+				comment on table nft_sale is E'@foreignKey (seller_public_key) references account (public_key)|@foreignFieldName nftSales|@fieldName seller\n@foreignKey (buyer_public_key) references account (public_key)|@foreignFieldName nftPurchases|@fieldName buyer\n@foreignKey (creator_public_key) references account (public_key)|@foreignFieldName nftSalesAsCreator|@fieldName creator\n@foreignKey (nft_post_hash) references post_entry (post_hash)|@foreignFieldName nftSales|@fieldName post\n@foreignKey (transaction_hash) references transaction (transaction_hash)|@foreignFieldName nftSale|@fieldName transaction\n@foreignKey (block_hash) references block (block_hash)|@foreignFieldName nftSales|@fieldName block';
+		`)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		_, err := db.Exec(`
+				comment on table nft_sale is NULL;
+		`)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,29 @@
+package post_sync_migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		_, err := db.Exec(`
+				comment on table creator_coin_trade is E'@foreignKey (trader_public_key) references account (public_key)|@foreignFieldName creatorCoinTrades|@fieldName trader\n@foreignKey (creator_public_key) references account (public_key)|@foreignFieldName creatorCoinTradesAsCreator|@fieldName creator\n@foreignKey (transaction_hash) references transaction (transaction_hash)|@foreignFieldName creatorCoinTrade|@fieldName transaction\n@foreignKey (block_hash) references block (block_hash)|@foreignFieldName creatorCoinTrades|@fieldName block';
+		`)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		_, err := db.Exec(`
+				comment on table creator_coin_trade is NULL;
+		`)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
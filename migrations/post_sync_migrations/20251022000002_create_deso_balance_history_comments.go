@@ -0,0 +1,51 @@
+package post_sync_migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		_, err := db.Exec(`
+				comment on table deso_balance_history is E'@foreignKey (public_key) references account (public_key)|@foreignFieldName desoBalanceHistory|@fieldName account\n@foreignKey (txn_hash) references transaction (transaction_hash)|@foreignFieldName desoBalanceHistory|@fieldName transaction\n@foreignKey (block_hash) references block (block_hash)|@foreignFieldName desoBalanceHistory|@fieldName block';
+				comment on table deso_balance_history_00 is E'@omit';
+				comment on table deso_balance_history_01 is E'@omit';
+				comment on table deso_balance_history_02 is E'@omit';
+				comment on table deso_balance_history_03 is E'@omit';
+				comment on table deso_balance_history_04 is E'@omit';
+				comment on table deso_balance_history_05 is E'@omit';
+				comment on table deso_balance_history_06 is E'@omit';
+				comment on table deso_balance_history_07 is E'@omit';
+				comment on table deso_balance_history_08 is E'@omit';
+				comment on table deso_balance_history_09 is E'@omit';
+				comment on table deso_balance_history_default is E'@omit';
+		`)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		_, err := db.Exec(`
+				comment on table deso_balance_history is NULL;
+				comment on table deso_balance_history_00 is NULL;
+				comment on table deso_balance_history_01 is NULL;
+				comment on table deso_balance_history_02 is NULL;
+				comment on table deso_balance_history_03 is NULL;
+				comment on table deso_balance_history_04 is NULL;
+				comment on table deso_balance_history_05 is NULL;
+				comment on table deso_balance_history_06 is NULL;
+				comment on table deso_balance_history_07 is NULL;
+				comment on table deso_balance_history_08 is NULL;
+				comment on table deso_balance_history_09 is NULL;
+				comment on table deso_balance_history_default is NULL;
+		`)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,48 @@
+package post_sync_migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		// Returns every holder of a creator or DAO coin, along with their balance, as of the given block height.
+		// Each holder's balance is their most recent snapshot at or before that height.
+		err := RunMigrationWithRetries(db, `
+			CREATE OR REPLACE FUNCTION get_token_holders_at_height(token_creator_pkid varchar, at_block_height bigint, dao_coin boolean)
+			RETURNS TABLE (hodler_pkid varchar, balance_nanos numeric, has_purchased boolean) AS
+			$BODY$
+				SELECT holders.hodler_pkid, holders.balance_nanos, holders.has_purchased
+				FROM (
+					SELECT DISTINCT ON (bh.hodler_pkid) bh.hodler_pkid, bh.balance_nanos, bh.has_purchased
+					FROM balance_history bh
+					WHERE bh.creator_pkid = token_creator_pkid
+					  AND bh.is_dao_coin = dao_coin
+					  AND bh.block_height <= at_block_height
+					ORDER BY bh.hodler_pkid, bh.block_height DESC
+				) holders
+				WHERE holders.balance_nanos > 0
+				ORDER BY holders.balance_nanos DESC;
+			$BODY$
+			LANGUAGE sql STABLE;
+
+			comment on table balance_history is E'@foreignKey (hodler_pkid) references account (pkid)|@foreignFieldName tokenBalanceHistory|@fieldName holder\n@foreignKey (creator_pkid) references account (pkid)|@foreignFieldName tokenBalanceHistoryAsCreator|@fieldName creator\n@foreignKey (block_hash) references block (block_hash)|@foreignFieldName tokenBalanceHistory|@fieldName block';
+		`)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		_, err := db.Exec(`
+			DROP FUNCTION IF EXISTS get_token_holders_at_height(varchar, bigint, boolean);
+			comment on table balance_history is NULL;
+		`)
+		if err != nil {
+			return err
+		}
+		return nil
+	})
+}
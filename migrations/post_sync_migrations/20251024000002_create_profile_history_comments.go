@@ -0,0 +1,29 @@
+package post_sync_migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		_, err := db.Exec(`
+				comment on table profile_history is E'@foreignKey (public_key) references account (public_key)|@foreignFieldName profileHistory|@fieldName account\n@foreignKey (txn_hash) references transaction (transaction_hash)|@foreignFieldName profileHistory|@fieldName transaction\n@foreignKey (block_hash) references block (block_hash)|@foreignFieldName profileHistory|@fieldName block';
+		`)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		_, err := db.Exec(`
+				comment on table profile_history is NULL;
+		`)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,59 @@
+package post_sync_migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		// The initial sync records follow events before their blocks are stored, so the daily follower counts are
+		// rebuilt from the follow events once it's done. Each event is bucketed by the day of the latest stored block
+		// at or below its height, as the follow batch operation does from then on.
+		_, err := db.Exec(`
+				TRUNCATE follower_count_daily;
+
+				INSERT INTO follower_count_daily (pkid, day, follows, unfollows, follower_count)
+				SELECT
+					daily_follow_events.pkid,
+					daily_follow_events.day,
+					daily_follow_events.follows,
+					daily_follow_events.unfollows,
+					SUM(daily_follow_events.follows - daily_follow_events.unfollows)
+						OVER (PARTITION BY daily_follow_events.pkid ORDER BY daily_follow_events.day)
+				FROM (
+					SELECT
+						follow_event.followed_pkid AS pkid,
+						event_block.day,
+						COUNT(*) FILTER (WHERE NOT follow_event.is_unfollow) AS follows,
+						COUNT(*) FILTER (WHERE follow_event.is_unfollow) AS unfollows
+					FROM follow_event
+					JOIN LATERAL (
+						SELECT block.timestamp::date AS day FROM block
+						WHERE block.height <= follow_event.block_height
+						ORDER BY block.height DESC LIMIT 1
+					) AS event_block ON true
+					GROUP BY 1, 2
+				) AS daily_follow_events;
+
+				comment on table follow_event is E'@foreignKey (follower_pkid) references account (pkid)|@foreignFieldName followEvents|@fieldName follower\n@foreignKey (followed_pkid) references account (pkid)|@foreignFieldName followerEvents|@fieldName followed\n@foreignKey (txn_hash) references transaction (transaction_hash)|@foreignFieldName followEvents|@fieldName transaction\n@foreignKey (block_hash) references block (block_hash)|@foreignFieldName followEvents|@fieldName block';
+				comment on table follower_count_daily is E'@foreignKey (pkid) references account (pkid)|@foreignFieldName followerCountsDaily|@fieldName account';
+		`)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		_, err := db.Exec(`
+				comment on table follow_event is NULL;
+				comment on table follower_count_daily is NULL;
+		`)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,29 @@
+package post_sync_migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		_, err := db.Exec(`
+				comment on table post_revision is E'@foreignKey (post_hash) references post_entry (post_hash)|@foreignFieldName revisions|@fieldName post\n@foreignKey (editor_public_key) references account (public_key)|@foreignFieldName postRevisions|@fieldName editor\n@foreignKey (txn_hash) references transaction (transaction_hash)|@foreignFieldName postRevisions|@fieldName transaction\n@foreignKey (block_hash) references block (block_hash)|@foreignFieldName postRevisions|@fieldName block';
+		`)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		_, err := db.Exec(`
+				comment on table post_revision is NULL;
+		`)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,36 @@
+package post_sync_migrations
+
+import (
+	"context"
+
+	"github.com/deso-protocol/postgres-data-handler/migrations/migration_utils"
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		// The initial sync doesn't maintain the thread structure or the total reply counts, so they're computed once
+		// it's done.
+		if err := migration_utils.BackfillPostThreadStructure(ctx, db); err != nil {
+			return err
+		}
+
+		_, err := db.Exec(`
+				comment on table post_stats is E'@foreignKey (post_hash) references post_entry (post_hash)|@foreignFieldName stats|@fieldName post';
+		`)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		_, err := db.Exec(`
+				comment on table post_stats is NULL;
+		`)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
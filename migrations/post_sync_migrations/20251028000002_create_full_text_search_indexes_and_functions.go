@@ -0,0 +1,82 @@
+package post_sync_migrations
+
+import (
+	"context"
+	"strings"
+
+	"github.com/deso-protocol/postgres-data-handler/migrations/migration_utils"
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		language, err := migration_utils.GetTextSearchLanguage()
+		if err != nil {
+			return err
+		}
+		// The search indexes are built once the initial sync is done, rather than maintained through it.
+		err = RunMigrationWithRetries(db, `
+			CREATE INDEX IF NOT EXISTS post_entry_body_tsv_idx ON post_entry USING GIN (body_tsv);
+			CREATE INDEX IF NOT EXISTS profile_entry_description_tsv_idx ON profile_entry USING GIN (description_tsv);
+			CREATE INDEX IF NOT EXISTS profile_entry_username_tsv_idx ON profile_entry USING GIN (username_tsv);
+		`)
+		if err != nil {
+			return err
+		}
+
+		// Search queries use the same language as the generated search columns, and accept web search syntax such
+		// as quoted phrases and "-" to exclude words. Hidden posts are never returned.
+		err = RunMigrationWithRetries(db, strings.Replace(`
+			CREATE OR REPLACE FUNCTION search_posts(search_query text)
+			RETURNS SETOF post_entry AS
+			$BODY$
+				SELECT post_entry.*
+				FROM post_entry, websearch_to_tsquery('{language}'::regconfig, search_query) AS query
+				WHERE post_entry.body_tsv @@ query
+				  AND NOT COALESCE(post_entry.is_hidden, false)
+				ORDER BY ts_rank(post_entry.body_tsv, query) DESC, post_entry.timestamp DESC;
+			$BODY$
+			LANGUAGE sql STABLE;
+
+			CREATE OR REPLACE FUNCTION search_profiles(search_query text)
+			RETURNS SETOF profile_entry AS
+			$BODY$
+				SELECT profile_entry.*
+				FROM profile_entry,
+					websearch_to_tsquery('{language}'::regconfig, search_query) AS description_query,
+					websearch_to_tsquery('simple'::regconfig, search_query) AS username_query
+				WHERE profile_entry.username_tsv @@ username_query
+				   OR profile_entry.description_tsv @@ description_query
+				ORDER BY
+					ts_rank(setweight(profile_entry.username_tsv, 'A'), username_query) +
+					ts_rank(setweight(profile_entry.description_tsv, 'B'), description_query) DESC,
+					profile_entry.username;
+			$BODY$
+			LANGUAGE sql STABLE;
+
+			comment on column post_entry.body_tsv is E'@omit';
+			comment on column profile_entry.description_tsv is E'@omit';
+			comment on column profile_entry.username_tsv is E'@omit';
+		`, "{language}", language, -1))
+		if err != nil {
+			return err
+		}
+
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		_, err := db.Exec(`
+			DROP FUNCTION IF EXISTS search_posts(text);
+			DROP FUNCTION IF EXISTS search_profiles(text);
+			DROP INDEX IF EXISTS post_entry_body_tsv_idx;
+			DROP INDEX IF EXISTS profile_entry_description_tsv_idx;
+			DROP INDEX IF EXISTS profile_entry_username_tsv_idx;
+			comment on column post_entry.body_tsv is NULL;
+			comment on column profile_entry.description_tsv is NULL;
+			comment on column profile_entry.username_tsv is NULL;
+		`)
+		if err != nil {
+			return err
+		}
+		return nil
+	})
+}
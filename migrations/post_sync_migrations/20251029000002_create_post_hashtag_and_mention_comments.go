@@ -0,0 +1,39 @@
+package post_sync_migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		// Posts can be stored before the profiles they mention during the initial sync, so mentions are resolved
+		// again once all profiles are stored.
+		_, err := db.Exec(`
+				UPDATE post_mention
+				SET mentioned_username = profile_entry.username, mentioned_public_key = profile_entry.public_key
+				FROM profile_entry
+				WHERE post_mention.mentioned_public_key IS NULL
+					AND LOWER(profile_entry.username) = LOWER(post_mention.mentioned_username);
+
+				comment on table post_hashtag is E'@foreignKey (post_hash) references post_entry (post_hash)|@foreignFieldName hashtags|@fieldName post';
+				comment on table post_mention is E'@foreignKey (post_hash) references post_entry (post_hash)|@foreignFieldName mentions|@fieldName post\n@foreignKey (mentioned_public_key) references account (public_key)|@foreignFieldName postMentions|@fieldName mentionedAccount';
+		`)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		_, err := db.Exec(`
+				comment on table post_hashtag is NULL;
+				comment on table post_mention is NULL;
+		`)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,29 @@
+package post_sync_migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		_, err := db.Exec(`
+				comment on table post_media is E'@foreignKey (post_hash) references post_entry (post_hash)|@foreignFieldName media|@fieldName post\n@foreignKey (poster_public_key) references account (public_key)|@foreignFieldName postMedia|@fieldName poster';
+		`)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		_, err := db.Exec(`
+				comment on table post_media is NULL;
+		`)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,29 @@
+package post_sync_migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		_, err := db.Exec(`
+				comment on table message_thread is E'@foreignKey (access_group_owner_public_key) references account (public_key)|@foreignFieldName messageThreads|@fieldName owner\n@foreignKey (party_access_group_owner_public_key) references account (public_key)|@foreignFieldName partyMessageThreads|@fieldName party\n@foreignKey (last_message_sender_public_key) references account (public_key)|@foreignFieldName lastMessageThreads|@fieldName lastMessageSender';
+		`)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		_, err := db.Exec(`
+				comment on table message_thread is NULL;
+		`)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
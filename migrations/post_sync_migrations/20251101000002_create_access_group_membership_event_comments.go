@@ -0,0 +1,31 @@
+package post_sync_migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		_, err := db.Exec(`
+				comment on table access_group_membership_event is E'@foreignKey (access_group_owner_public_key, access_group_key_name) references access_group_entry (access_group_owner_public_key, access_group_key_name)|@foreignFieldName membershipEvents|@fieldName accessGroup\n@foreignKey (access_group_member_public_key) references account (public_key)|@foreignFieldName accessGroupMembershipEvents|@fieldName member\n@foreignKey (actor_public_key) references account (public_key)|@foreignFieldName accessGroupMembershipActions|@fieldName actor\n@foreignKey (txn_hash) references transaction (transaction_hash)|@foreignFieldName accessGroupMembershipEvents|@fieldName transaction\n@foreignKey (block_hash) references block (block_hash)|@foreignFieldName accessGroupMembershipEvents|@fieldName block';
+				comment on table access_group_member_count is E'@foreignKey (access_group_owner_public_key, access_group_key_name) references access_group_entry (access_group_owner_public_key, access_group_key_name)|@foreignFieldName memberCount|@fieldName accessGroup';
+		`)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		_, err := db.Exec(`
+				comment on table access_group_membership_event is NULL;
+				comment on table access_group_member_count is NULL;
+		`)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
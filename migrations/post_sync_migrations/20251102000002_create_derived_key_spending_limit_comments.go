@@ -0,0 +1,47 @@
+package post_sync_migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		_, err := db.Exec(`
+				comment on table derived_key_entry is E'@name derived_key\n@unique owner_public_key,derived_public_key\n@foreignKey (owner_public_key) references account (public_key)|@foreignFieldName derivedKeys|@fieldName owner';
+				comment on table derived_key_txn_count_limit is E'@foreignKey (owner_public_key, derived_public_key) references derived_key_entry (owner_public_key, derived_public_key)|@foreignFieldName txnCountLimits|@fieldName derivedKey';
+				comment on table derived_key_creator_coin_limit is E'@foreignKey (owner_public_key, derived_public_key) references derived_key_entry (owner_public_key, derived_public_key)|@foreignFieldName creatorCoinLimits|@fieldName derivedKey\n@foreignKey (creator_public_key) references account (public_key)|@foreignFieldName derivedKeyCreatorCoinLimits|@fieldName creator';
+				comment on table derived_key_dao_coin_limit is E'@foreignKey (owner_public_key, derived_public_key) references derived_key_entry (owner_public_key, derived_public_key)|@foreignFieldName daoCoinLimits|@fieldName derivedKey\n@foreignKey (creator_public_key) references account (public_key)|@foreignFieldName derivedKeyDaoCoinLimits|@fieldName creator';
+				comment on table derived_key_nft_limit is E'@foreignKey (owner_public_key, derived_public_key) references derived_key_entry (owner_public_key, derived_public_key)|@foreignFieldName nftLimits|@fieldName derivedKey';
+				comment on table derived_key_dao_coin_limit_order_limit is E'@foreignKey (owner_public_key, derived_public_key) references derived_key_entry (owner_public_key, derived_public_key)|@foreignFieldName daoCoinLimitOrderLimits|@fieldName derivedKey';
+				comment on table derived_key_association_limit is E'@foreignKey (owner_public_key, derived_public_key) references derived_key_entry (owner_public_key, derived_public_key)|@foreignFieldName associationLimits|@fieldName derivedKey';
+				comment on table derived_key_access_group_limit is E'@foreignKey (owner_public_key, derived_public_key) references derived_key_entry (owner_public_key, derived_public_key)|@foreignFieldName accessGroupLimits|@fieldName derivedKey';
+				comment on table derived_key_lockup_limit is E'@foreignKey (owner_public_key, derived_public_key) references derived_key_entry (owner_public_key, derived_public_key)|@foreignFieldName lockupLimits|@fieldName derivedKey\n@foreignKey (profile_public_key) references account (public_key)|@foreignFieldName derivedKeyLockupLimits|@fieldName profile';
+				comment on table derived_key_stake_limit is E'@foreignKey (owner_public_key, derived_public_key) references derived_key_entry (owner_public_key, derived_public_key)|@foreignFieldName stakeLimits|@fieldName derivedKey\n@foreignKey (validator_public_key) references account (public_key)|@foreignFieldName derivedKeyStakeLimits|@fieldName validator';
+		`)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		_, err := db.Exec(`
+				comment on table derived_key_entry is E'@name derived_key\n@foreignKey (owner_public_key) references account (public_key)|@foreignFieldName derivedKeys|@fieldName owner';
+				comment on table derived_key_txn_count_limit is NULL;
+				comment on table derived_key_creator_coin_limit is NULL;
+				comment on table derived_key_dao_coin_limit is NULL;
+				comment on table derived_key_nft_limit is NULL;
+				comment on table derived_key_dao_coin_limit_order_limit is NULL;
+				comment on table derived_key_association_limit is NULL;
+				comment on table derived_key_access_group_limit is NULL;
+				comment on table derived_key_lockup_limit is NULL;
+				comment on table derived_key_stake_limit is NULL;
+		`)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
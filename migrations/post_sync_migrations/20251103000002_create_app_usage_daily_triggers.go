@@ -0,0 +1,88 @@
+package post_sync_migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+)
+
+// The app usage counts are maintained by triggers on transaction_partitioned, so that each transaction is counted
+// once no matter how many times it's upserted. The triggers are only created once the initial sync is done, and the
+// counts are backfilled from the transactions synced until then.
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		err := RunMigrationWithRetries(db, `
+			INSERT INTO app_usage_daily (day, derived_public_key, owner_public_key, transaction_count)
+			SELECT timestamp::date, derived_public_key, public_key, COUNT(*)
+			FROM transaction_partitioned
+			WHERE derived_public_key IS NOT NULL AND public_key IS NOT NULL
+			GROUP BY 1, 2, 3
+			ON CONFLICT (day, derived_public_key, owner_public_key) DO UPDATE SET
+				transaction_count = EXCLUDED.transaction_count;
+
+			CREATE OR REPLACE FUNCTION update_app_usage_daily() RETURNS TRIGGER AS $$
+			BEGIN
+				IF TG_OP = 'UPDATE' OR TG_OP = 'DELETE' THEN
+					IF OLD.derived_public_key IS NOT NULL AND OLD.public_key IS NOT NULL THEN
+						UPDATE app_usage_daily
+						SET transaction_count = GREATEST(transaction_count - 1, 0)
+						WHERE day = OLD.timestamp::date
+						AND derived_public_key = OLD.derived_public_key
+						AND owner_public_key = OLD.public_key;
+					END IF;
+				END IF;
+
+				IF TG_OP = 'INSERT' OR TG_OP = 'UPDATE' THEN
+					IF NEW.derived_public_key IS NOT NULL AND NEW.public_key IS NOT NULL THEN
+						INSERT INTO app_usage_daily (day, derived_public_key, owner_public_key, transaction_count)
+						VALUES (NEW.timestamp::date, NEW.derived_public_key, NEW.public_key, 1)
+						ON CONFLICT (day, derived_public_key, owner_public_key) DO UPDATE SET
+							transaction_count = app_usage_daily.transaction_count + 1;
+					END IF;
+				END IF;
+
+				RETURN NULL;
+			END;
+			$$ LANGUAGE plpgsql;
+
+			CREATE TRIGGER transaction_app_usage_daily_insert_delete_trigger
+			AFTER INSERT OR DELETE ON transaction_partitioned
+			FOR EACH ROW EXECUTE FUNCTION update_app_usage_daily();
+
+			-- Upserts only move a transaction's count when it changes day, such as when a mempool transaction is mined.
+			CREATE TRIGGER transaction_app_usage_daily_update_trigger
+			AFTER UPDATE ON transaction_partitioned
+			FOR EACH ROW
+			WHEN (
+				OLD.timestamp::date IS DISTINCT FROM NEW.timestamp::date OR
+				OLD.derived_public_key IS DISTINCT FROM NEW.derived_public_key OR
+				OLD.public_key IS DISTINCT FROM NEW.public_key
+			)
+			EXECUTE FUNCTION update_app_usage_daily();
+		`)
+		if err != nil {
+			return err
+		}
+
+		_, err = db.Exec(`
+				comment on table app_usage_daily is E'@foreignKey (owner_public_key) references account (public_key)|@foreignFieldName appUsageDaily|@fieldName owner';
+		`)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		_, err := db.Exec(`
+			DROP TRIGGER IF EXISTS transaction_app_usage_daily_insert_delete_trigger ON transaction_partitioned;
+			DROP TRIGGER IF EXISTS transaction_app_usage_daily_update_trigger ON transaction_partitioned;
+			DROP FUNCTION IF EXISTS update_app_usage_daily;
+			comment on table app_usage_daily is NULL;
+		`)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,66 @@
+package post_sync_migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		// Classify each derived key against the height of the latest block. A key is expired once the chain reaches
+		// its expiration block, and revoked if its operation type is no longer valid.
+		err := RunMigrationWithRetries(db, `
+			CREATE OR REPLACE VIEW derived_key_status AS
+			SELECT
+				derived_key_entry.owner_public_key,
+				derived_key_entry.derived_public_key,
+				derived_key_entry.expiration_block,
+				derived_key_entry.operation_type,
+				current_block.height AS current_block_height,
+				CASE
+					WHEN derived_key_entry.operation_type = 0 THEN 'revoked'
+					WHEN derived_key_entry.expiration_block <= current_block.height THEN 'expired'
+					ELSE 'active'
+				END AS status,
+				GREATEST(derived_key_entry.expiration_block - current_block.height, 0) AS blocks_until_expiration
+			FROM derived_key_entry
+			CROSS JOIN (SELECT COALESCE(MAX(height), 0) AS height FROM block) AS current_block;
+
+			CREATE OR REPLACE FUNCTION derived_keys_expiring_within(num_blocks bigint)
+			RETURNS SETOF derived_key_status AS
+			$BODY$
+				SELECT derived_key_status.*
+				FROM derived_key_status
+				WHERE derived_key_status.status = 'active'
+				  AND derived_key_status.blocks_until_expiration <= num_blocks
+				ORDER BY derived_key_status.expiration_block;
+			$BODY$
+			LANGUAGE sql STABLE;
+		`)
+		if err != nil {
+			return err
+		}
+
+		_, err = db.Exec(`
+				comment on view derived_key_status is E'@primaryKey owner_public_key,derived_public_key\n@foreignKey (owner_public_key) references account (public_key)|@foreignFieldName derivedKeyStatuses|@fieldName owner\n@foreignKey (owner_public_key, derived_public_key) references derived_key_entry (owner_public_key, derived_public_key)|@foreignFieldName status|@fieldName derivedKey';
+				comment on table derived_key_expiration_event is E'@foreignKey (owner_public_key) references account (public_key)|@foreignFieldName derivedKeyExpirationEvents|@fieldName owner\n@foreignKey (owner_public_key, derived_public_key) references derived_key_entry (owner_public_key, derived_public_key)|@foreignFieldName expirationEvents|@fieldName derivedKey\n@foreignKey (block_hash) references block (block_hash)|@foreignFieldName derivedKeyExpirationEvents|@fieldName block';
+		`)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		_, err := db.Exec(`
+			DROP FUNCTION IF EXISTS derived_keys_expiring_within;
+			DROP VIEW IF EXISTS derived_key_status;
+			comment on table derived_key_expiration_event is NULL;
+		`)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
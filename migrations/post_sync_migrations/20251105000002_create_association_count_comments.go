@@ -0,0 +1,31 @@
+package post_sync_migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		_, err := db.Exec(`
+				comment on table post_association_count is E'@foreignKey (post_hash) references post_entry (post_hash)|@foreignFieldName associationCounts|@fieldName post';
+				comment on table user_association_count is E'@foreignKey (target_user_pkid) references account (pkid)|@foreignFieldName associationCounts|@fieldName target';
+		`)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		_, err := db.Exec(`
+				comment on table post_association_count is NULL;
+				comment on table user_association_count is NULL;
+		`)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,31 @@
+package post_sync_migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		_, err := db.Exec(`
+				comment on table poll is E'@foreignKey (post_hash) references post_entry (post_hash)|@foreignFieldName poll|@fieldName post\n@foreignKey (poster_public_key) references account (public_key)|@foreignFieldName polls|@fieldName poster\n@foreignKey (weight_token_public_key) references account (public_key)|@foreignFieldName weightedPolls|@fieldName weightToken';
+				comment on table poll_option is E'@foreignKey (post_hash) references poll (post_hash)|@foreignFieldName options|@fieldName poll';
+		`)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		_, err := db.Exec(`
+				comment on table poll is NULL;
+				comment on table poll_option is NULL;
+		`)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
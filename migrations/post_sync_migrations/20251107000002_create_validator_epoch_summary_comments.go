@@ -0,0 +1,29 @@
+package post_sync_migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		_, err := db.Exec(`
+				comment on table validator_epoch_summary is E'@foreignKey (validator_pkid) references account (pkid)|@foreignFieldName validatorEpochSummaries|@fieldName validatorAccount\n@foreignKey (validator_pkid) references validator_entry (validator_pkid)|@foreignFieldName epochSummaries|@fieldName validatorEntry\n@foreignKey (epoch_number) references epoch_entry (epoch_number)|@foreignFieldName validatorSummaries|@fieldName epoch';
+		`)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		_, err := db.Exec(`
+				comment on table validator_epoch_summary is NULL;
+		`)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
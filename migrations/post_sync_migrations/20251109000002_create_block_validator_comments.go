@@ -0,0 +1,31 @@
+package post_sync_migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		_, err := db.Exec(`
+				comment on table block is E'@unique block_hash\n@unique height\n@foreignKey (proposer_pkid) references account (pkid)|@foreignFieldName proposedBlocks|@fieldName proposer';
+				comment on table block_signer is E'@foreignKey (block_hash) references block (block_hash)|@foreignFieldName signers|@fieldName block\n@foreignKey (signer_pkid) references account (pkid)|@foreignFieldName signedBlocks|@fieldName signer';
+		`)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		_, err := db.Exec(`
+				comment on table block is E'@unique block_hash\n@unique height';
+				comment on table block_signer is NULL;
+		`)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,29 @@
+package post_sync_migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		_, err := db.Exec(`
+				comment on table validator_key_rotation is E'@foreignKey (validator_pkid) references account (pkid)|@foreignFieldName validatorKeyRotations|@fieldName validatorAccount\n@foreignKey (validator_pkid) references validator_entry (validator_pkid)|@foreignFieldName keyRotations|@fieldName validatorEntry\n@foreignKey (transaction_hash) references transaction (transaction_hash)|@foreignFieldName validatorKeyRotation|@fieldName transaction\n@foreignKey (block_hash) references block (block_hash)|@foreignFieldName validatorKeyRotations|@fieldName block\n@foreignKey (epoch_number) references epoch_entry (epoch_number)|@foreignFieldName validatorKeyRotations|@fieldName epoch';
+		`)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		_, err := db.Exec(`
+				comment on table validator_key_rotation is NULL;
+		`)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
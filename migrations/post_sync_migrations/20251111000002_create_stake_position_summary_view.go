@@ -0,0 +1,100 @@
+package post_sync_migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		_, err := db.Exec(`
+CREATE OR REPLACE VIEW stake_position_summary as
+with stake_positions as (select staker_pkid, validator_pkid
+                         from stake_entry
+                         union
+                         select staker_pkid, validator_pkid
+                         from locked_stake_entry
+                         union
+                         select staker_pkid, validator_pkid
+                         from stake_position_history
+                         union
+                         select staker_pkid, validator_pkid
+                         from stake_reward)
+select stake_positions.staker_pkid                     as staker_pkid,
+       stake_positions.validator_pkid                  as validator_pkid,
+       current_stake.reward_method                     as reward_method,
+       coalesce(current_stake.stake_amount_nanos, 0)   as stake_amount_nanos,
+       coalesce(current_locked_stake.locked_amount_nanos, 0) as locked_stake_amount_nanos,
+       coalesce(history.total_staked_nanos, 0)         as total_staked_nanos,
+       coalesce(history.total_unstaked_nanos, 0)       as total_unstaked_nanos,
+       coalesce(history.total_unlocked_nanos, 0)       as total_unlocked_nanos,
+       coalesce(rewards.total_reward_nanos, 0)         as total_reward_nanos,
+       coalesce(rewards.restaked_reward_nanos, 0)      as restaked_reward_nanos,
+       coalesce(rewards.paid_reward_nanos, 0)          as paid_reward_nanos,
+       history.first_block_height                      as first_block_height,
+       history.last_block_height                       as last_block_height
+from stake_positions
+         left join stake_entry current_stake
+                   on current_stake.staker_pkid = stake_positions.staker_pkid
+                       and current_stake.validator_pkid = stake_positions.validator_pkid
+         left join (select staker_pkid, validator_pkid, sum(locked_amount_nanos) locked_amount_nanos
+                    from locked_stake_entry
+                    group by staker_pkid, validator_pkid) current_locked_stake
+                   on current_locked_stake.staker_pkid = stake_positions.staker_pkid
+                       and current_locked_stake.validator_pkid = stake_positions.validator_pkid
+         -- A stake or unstake in the last block of an epoch shares its history row with the rewards restaked in
+         -- that block, so the restaked rewards are taken out of the row's delta.
+         left join (select stake_position_history.staker_pkid,
+                           stake_position_history.validator_pkid,
+                           sum(stake_position_history.delta_nanos - coalesce(block_restake.restaked_nanos, 0))
+                           filter (where position_type = 'stake' and action = 'stake') total_staked_nanos,
+                           -sum(stake_position_history.delta_nanos - coalesce(block_restake.restaked_nanos, 0))
+                           filter (where position_type = 'stake' and action = 'unstake') total_unstaked_nanos,
+                           -sum(stake_position_history.delta_nanos)
+                           filter (where position_type = 'locked_stake' and action = 'unlock') total_unlocked_nanos,
+                           min(stake_position_history.block_height) first_block_height,
+                           max(stake_position_history.block_height) last_block_height
+                    from stake_position_history
+                             left join (select staker_pkid, validator_pkid, block_hash, sum(reward_nanos) restaked_nanos
+                                        from stake_reward
+                                        where reward_method = 1
+                                        group by staker_pkid, validator_pkid, block_hash) block_restake
+                                       on block_restake.staker_pkid = stake_position_history.staker_pkid
+                                           and block_restake.validator_pkid = stake_position_history.validator_pkid
+                                           and block_restake.block_hash = stake_position_history.block_hash
+                                           and stake_position_history.position_type = 'stake'
+                                           and stake_position_history.action in ('stake', 'unstake')
+                    group by stake_position_history.staker_pkid, stake_position_history.validator_pkid) history
+                   on history.staker_pkid = stake_positions.staker_pkid
+                       and history.validator_pkid = stake_positions.validator_pkid
+         left join (select staker_pkid,
+                           validator_pkid,
+                           sum(reward_nanos) total_reward_nanos,
+                           sum(reward_nanos) filter (where reward_method = 1) restaked_reward_nanos,
+                           sum(reward_nanos) filter (where reward_method = 0) paid_reward_nanos
+                    from stake_reward
+                    group by staker_pkid, validator_pkid) rewards
+                   on rewards.staker_pkid = stake_positions.staker_pkid
+                       and rewards.validator_pkid = stake_positions.validator_pkid;
+
+comment on view stake_position_summary is E'@primaryKey staker_pkid,validator_pkid\n@foreignKey (staker_pkid) references account (pkid)|@foreignFieldName stakePositionSummaries|@fieldName staker\n@foreignKey (validator_pkid) references account (pkid)|@foreignFieldName stakePositionSummariesAsValidator|@fieldName validator';
+comment on table stake_position_history is E'@foreignKey (staker_pkid) references account (pkid)|@foreignFieldName stakePositionHistory|@fieldName staker\n@foreignKey (validator_pkid) references account (pkid)|@foreignFieldName stakePositionHistoryAsValidator|@fieldName validator\n@foreignKey (txn_hash) references transaction (transaction_hash)|@foreignFieldName stakePositionHistory|@fieldName transaction\n@foreignKey (block_hash) references block (block_hash)|@foreignFieldName stakePositionHistory|@fieldName block';
+`)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		_, err := db.Exec(`
+DROP VIEW IF EXISTS stake_position_summary;
+comment on table stake_position_history is NULL;
+`)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
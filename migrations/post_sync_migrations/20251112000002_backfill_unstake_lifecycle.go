@@ -0,0 +1,95 @@
+package post_sync_migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		// Unstakes and unlocks made before the table existed are backfilled from their transactions. An unlock claims
+		// every locked stake entry of the staker with the validator in its epoch range.
+		_, err := db.Exec(`
+				INSERT INTO unstake_lifecycle (
+					unstake_txn_hash, staker_pkid, validator_pkid, locked_at_epoch_number, unstake_amount_nanos, status,
+					unstake_block_hash, unstake_block_height, unstake_timestamp
+				)
+				SELECT
+					transaction.transaction_hash,
+					COALESCE(staker.pkid, transaction.public_key),
+					COALESCE(validator.pkid, transaction.tx_index_metadata ->> 'ValidatorPublicKeyBase58Check'),
+					epoch_entry.epoch_number,
+					hex_to_numeric(transaction.tx_index_metadata ->> 'UnstakeAmountNanos'),
+					'locked',
+					transaction.block_hash,
+					transaction.block_height,
+					transaction.timestamp
+				FROM transaction
+				LEFT JOIN pkid_entry staker ON staker.public_key = transaction.public_key
+				LEFT JOIN pkid_entry validator
+					ON validator.public_key = transaction.tx_index_metadata ->> 'ValidatorPublicKeyBase58Check'
+				LEFT JOIN epoch_entry
+					ON transaction.block_height BETWEEN epoch_entry.initial_block_height AND epoch_entry.final_block_height
+				WHERE transaction.txn_type = 37
+				AND transaction.block_hash IS NOT NULL
+				AND transaction.tx_index_metadata ->> 'ValidatorPublicKeyBase58Check' IS NOT NULL
+				AND transaction.tx_index_metadata ->> 'UnstakeAmountNanos' IS NOT NULL
+				ON CONFLICT (unstake_txn_hash) DO NOTHING;
+
+				UPDATE unstake_lifecycle
+				SET status = 'unlocked',
+					unlock_txn_hash = unlock.transaction_hash,
+					unlock_block_hash = unlock.block_hash,
+					unlock_block_height = unlock.block_height,
+					unlock_timestamp = unlock.timestamp
+				FROM unstake_lifecycle unstake
+				CROSS JOIN LATERAL (
+					SELECT transaction.transaction_hash, transaction.block_hash, transaction.block_height, transaction.timestamp
+					FROM transaction
+					LEFT JOIN pkid_entry staker ON staker.public_key = transaction.public_key
+					LEFT JOIN pkid_entry validator
+						ON validator.public_key = transaction.tx_index_metadata ->> 'ValidatorPublicKeyBase58Check'
+					WHERE transaction.txn_type = 38
+					AND transaction.block_height > unstake.unstake_block_height
+					AND COALESCE(staker.pkid, transaction.public_key) = unstake.staker_pkid
+					AND COALESCE(validator.pkid, transaction.tx_index_metadata ->> 'ValidatorPublicKeyBase58Check') = unstake.validator_pkid
+					AND unstake.locked_at_epoch_number BETWEEN (transaction.tx_index_metadata ->> 'StartEpochNumber')::BIGINT
+						AND (transaction.tx_index_metadata ->> 'EndEpochNumber')::BIGINT
+					ORDER BY transaction.block_height
+					LIMIT 1
+				) AS unlock
+				WHERE unstake_lifecycle.unstake_txn_hash = unstake.unstake_txn_hash
+				AND unstake_lifecycle.unlock_txn_hash IS NULL;
+
+				-- Unlockable epochs and statuses are computed here when global params are stored, and otherwise as
+				-- the next epoch is synced.
+				UPDATE unstake_lifecycle
+				SET unlockable_at_epoch_number = unstake_lifecycle.locked_at_epoch_number + global_params_entry.stake_lockup_epoch_duration
+				FROM (SELECT stake_lockup_epoch_duration FROM global_params_entry LIMIT 1) AS global_params_entry
+				WHERE unstake_lifecycle.locked_at_epoch_number IS NOT NULL
+				AND unstake_lifecycle.unlockable_at_epoch_number IS NULL;
+
+				UPDATE unstake_lifecycle
+				SET status = 'unlockable'
+				WHERE status = 'locked'
+				AND unlockable_at_epoch_number <= (SELECT max(epoch_number) FROM epoch_entry);
+
+				comment on table unstake_lifecycle is E'@foreignKey (staker_pkid) references account (pkid)|@foreignFieldName unstakeLifecycles|@fieldName staker\n@foreignKey (validator_pkid) references account (pkid)|@foreignFieldName unstakeLifecyclesAsValidator|@fieldName validator\n@foreignKey (unstake_txn_hash) references transaction (transaction_hash)|@foreignFieldName unstakeLifecycle|@fieldName unstakeTransaction\n@foreignKey (unlock_txn_hash) references transaction (transaction_hash)|@foreignFieldName unlockedUnstakes|@fieldName unlockTransaction\n@foreignKey (unstake_block_hash) references block (block_hash)|@foreignFieldName unstakeLifecycles|@fieldName unstakeBlock\n@foreignKey (locked_at_epoch_number) references epoch_entry (epoch_number)|@foreignFieldName unstakeLifecycles|@fieldName lockedAtEpoch';
+		`)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		_, err := db.Exec(`
+				comment on table unstake_lifecycle is NULL;
+		`)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+}